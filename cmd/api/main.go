@@ -1,20 +1,26 @@
 package main
 
 import (
+	"context"
 	"log"
 	"net/http"
 	"time"
 
-	"github.com/go-chi/cors"
 	"github.com/redis/go-redis/v9"
 
 	"github.com/go-chi/chi"
 	"github.com/go-chi/chi/middleware"
 
 	"gotasker/internal/ai"
+	"gotasker/internal/archive"
 	"gotasker/internal/auth"
+	"gotasker/internal/blob"
 	"gotasker/internal/handlers"
+	"gotasker/internal/mail"
 	customMiddleware "gotasker/internal/middleware"
+	"gotasker/internal/notifications"
+	"gotasker/internal/oidc"
+	"gotasker/internal/ordering"
 	internalRedis "gotasker/internal/redis"
 
 	"database/sql"
@@ -51,20 +57,40 @@ func main() {
 	aiService := ai.NewOpenAIService()
 	aiWorker := ai.NewWorker(db, aiService)
 
+	// Background compaction of manual-order position keys
+	rebalancer := ordering.NewRebalancer(db, redisClient)
+	go rebalancer.Run(context.Background())
+
+	go archive.NewArchiver(db, redisClient).Run(context.Background())
+
+	// Due-date reminders and notification retention
+	go notifications.NewWorker(db).Run(context.Background())
+
+	// Attachment storage
+	blobStore, err := blob.NewFromEnv()
+	if err != nil {
+		log.Fatal("Cannot initialise blob store: ", err)
+	}
+
+	// Outgoing email (invites)
+	mailer, err := mail.NewFromEnv()
+	if err != nil {
+		log.Fatal("Cannot initialise mailer: ", err)
+	}
+
+	// Single sign-on; nil unless OIDC_ISSUER is set
+	oidcClient, err := oidc.NewFromEnv()
+	if err != nil {
+		log.Fatal("Cannot initialise OIDC client: ", err)
+	}
+
 	r := chi.NewRouter()
 
 	r.Use(middleware.RequestID)
 	r.Use(middleware.Logger)
 	r.Use(middleware.Recoverer)
 	//CORS - Frontend <-> Backend Conection
-	r.Use(cors.Handler(cors.Options{
-		AllowedOrigins:   []string{"http://localhost:5175"},
-		AllowedMethods:   []string{"GET", "POST", "PATCH", "DELETE", "OPTIONS"},
-		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-API-Key"},
-		ExposedHeaders:   []string{"Link"},
-		AllowCredentials: false,
-		MaxAge:           300,
-	}))
+	r.Use(customMiddleware.CORS())
 
 	// custom logging for request
 	r.Use(customMiddleware.LoggingMiddleWare)
@@ -73,22 +99,123 @@ func main() {
 
 	r.Get("/health", handlers.HealthHandler)
 	r.Get("/tasks", handlers.GetTasksHandler)
-	r.Post("/register", handlers.RegisterHandler(db))
+	r.Post("/register", handlers.RegisterHandler(db, mailer))
 	r.Post("/login", handlers.LoginHandler(db))
-
-	// protected routes group
+	r.Post("/login/mfa", handlers.LoginMFAHandler(db))
+	r.Post("/token/refresh", handlers.RefreshTokenHandler(db))
+	r.Post("/password/forgot", handlers.ForgotPasswordHandler(db, mailer))
+	r.Post("/password/reset", handlers.ResetPasswordHandler(db))
+	r.Post("/verify-email", handlers.VerifyEmailHandler(db))
+	r.Post("/verify-email/resend", handlers.ResendVerificationHandler(db, mailer))
+	r.Get("/auth/oidc/login", handlers.OIDCLoginHandler(db, oidcClient))
+	r.Get("/auth/oidc/callback", handlers.OIDCCallbackHandler(db, oidcClient))
+	r.Get("/attachments/{id}/download", handlers.DownloadAttachmentHandler(db, blobStore))
+
+	// task routes: a user token or an API key; keys need tasks:read for
+	// GET and tasks:write for everything else
 	r.Group(func(r chi.Router) {
-		r.Use(auth.JWTMiddleware)
+		r.Use(auth.Authenticate(db, redisClient))
+		r.Use(auth.RequireScopes(auth.ScopeTasksRead, auth.ScopeTasksWrite))
 		r.Get("/tasksdb", handlers.GetTasksHandlerDB(db, redisClient))
 		r.Get("/tasksdb/{id}", handlers.GetTaskbyIDHandlerDB(db))
 		r.Post("/tasksdb", handlers.CreateTaskHandlerDB(db, redisClient, aiWorker))
+		r.Post("/tasksdb/quick", handlers.QuickAddTaskHandlerDB(db, redisClient, aiWorker))
+		r.Get("/tasksdb/export", handlers.ExportTasksHandlerDB(db))
+		r.Post("/tasksdb/import", handlers.ImportTasksHandlerDB(db, redisClient))
 		r.Patch("/tasksdb/{id}", handlers.PatchTaskHandlerDB(db, redisClient))
-		r.Delete("/tasksdb/{id}", handlers.DeleteTaskHandlerDB(db, redisClient))
+		r.Delete("/tasksdb/{id}", handlers.DeleteTaskHandlerDB(db, redisClient, blobStore))
+		r.Get("/tasksdb/assigned", handlers.GetAssignedTasksHandlerDB(db))
+		r.With(auth.RequireVerifiedEmail).Put("/tasksdb/{id}/assignee", handlers.AssignTaskHandlerDB(db, redisClient))
+		r.Get("/tasksdb/{id}/history", handlers.GetTaskHistoryHandler(db))
+		r.Post("/tasksdb/{id}/move", handlers.MoveTaskHandlerDB(db, redisClient, rebalancer))
+		r.Post("/tasksdb/{id}/template", handlers.SaveTaskAsTemplateHandler(db))
+		r.Get("/templates", handlers.ListTemplatesHandler(db))
+		r.Post("/templates", handlers.CreateTemplateHandler(db))
+		r.Get("/templates/{id}", handlers.GetTemplateHandler(db))
+		r.Put("/templates/{id}", handlers.UpdateTemplateHandler(db))
+		r.Delete("/templates/{id}", handlers.DeleteTemplateHandler(db))
+		r.Post("/templates/{id}/instantiate", handlers.InstantiateTemplateHandler(db, redisClient))
+		r.Get("/workflow", handlers.GetWorkflowHandler(db))
+		r.Put("/workflow", handlers.UpdateWorkflowHandler(db, redisClient))
+		r.Get("/board", handlers.GetBoardHandler(db))
+		r.Post("/tasksdb/{id}/timer/start", handlers.StartTimerHandler(db))
+		r.Post("/tasksdb/{id}/timer/stop", handlers.StopTimerHandler(db, redisClient))
+		r.Get("/tasksdb/{id}/time-entries", handlers.ListTimeEntriesHandler(db))
+		r.Post("/tasksdb/{id}/time-entries", handlers.CreateTimeEntryHandler(db, redisClient))
+		r.Patch("/time-entries/{id}", handlers.UpdateTimeEntryHandler(db, redisClient))
+		r.Delete("/time-entries/{id}", handlers.DeleteTimeEntryHandler(db, redisClient))
+		r.Get("/reports/time", handlers.TimeReportHandler(db))
+		r.Get("/stats", handlers.GetStatsHandler(db, redisClient))
+		r.Get("/archive", handlers.GetArchiveHandler(db))
+		r.Post("/tasksdb/{id}/archive", handlers.ArchiveTaskHandlerDB(db, redisClient))
+		r.Post("/tasksdb/{id}/unarchive", handlers.UnarchiveTaskHandlerDB(db, redisClient))
+		r.Get("/tasksdb/{id}/attachments", handlers.ListAttachmentsHandler(db))
+		r.Post("/tasksdb/{id}/attachments", handlers.UploadAttachmentHandler(db, blobStore))
+		r.Get("/tasksdb/{id}/attachments/{attachmentID}/url", handlers.AttachmentURLHandler(db, blobStore))
+		r.Delete("/tasksdb/{id}/attachments/{attachmentID}", handlers.DeleteAttachmentHandler(db, blobStore))
+		r.Get("/tasksdb/{id}/comments", handlers.ListCommentsHandler(db))
+		r.Post("/tasksdb/{id}/comments", handlers.CreateCommentHandler(db, redisClient))
+		r.Patch("/comments/{id}", handlers.UpdateCommentHandler(db))
+		r.Delete("/comments/{id}", handlers.DeleteCommentHandler(db, redisClient))
+
+	})
+
+	// account routes: user tokens only
+	r.Group(func(r chi.Router) {
+		r.Use(auth.JWTMiddleware(db, redisClient))
+		r.Post("/logout", handlers.LogoutHandler(db, redisClient))
+		r.Post("/logout-all", handlers.LogoutAllHandler(db))
+		r.Get("/sessions", handlers.ListSessionsHandler(db))
+		r.Delete("/sessions/{id}", handlers.RevokeSessionHandler(db))
+		r.Get("/mfa", handlers.GetMFAStatusHandler(db))
+		r.Post("/mfa/totp/enroll", handlers.EnrollTOTPHandler(db))
+		r.Post("/mfa/totp/confirm", handlers.ConfirmTOTPHandler(db))
+		r.Post("/mfa/totp/disable", handlers.DisableTOTPHandler(db))
+		r.Post("/mfa/recovery-codes", handlers.RegenerateRecoveryCodesHandler(db))
+		r.Get("/settings", handlers.GetSettingsHandler(db))
+		r.Put("/settings", handlers.UpdateSettingsHandler(db))
+		r.Get("/notifications", handlers.GetNotificationsHandler(db))
+		r.Get("/notifications/unread", handlers.GetUnreadNotificationsHandler(db))
+		r.Post("/notifications/read-all", handlers.MarkAllNotificationsReadHandler(db))
+		r.Post("/notifications/{id}/read", handlers.MarkNotificationReadHandler(db))
+		r.Get("/workspaces", handlers.ListWorkspacesHandler(db))
+		r.Post("/workspaces", handlers.CreateWorkspaceHandler(db))
+		r.Get("/workspaces/{id}", handlers.GetWorkspaceHandler(db))
+		r.Patch("/workspaces/{id}", handlers.UpdateWorkspaceHandler(db))
+		r.Delete("/workspaces/{id}", handlers.DeleteWorkspaceHandler(db, redisClient, blobStore))
+		r.Get("/workspaces/{id}/members", handlers.ListWorkspaceMembersHandler(db))
+		r.With(auth.RequireVerifiedEmail).Post("/workspaces/{id}/members", handlers.AddWorkspaceMemberHandler(db))
+		r.Patch("/workspaces/{id}/members/{userID}", handlers.UpdateWorkspaceMemberHandler(db))
+		r.Delete("/workspaces/{id}/members/{userID}", handlers.RemoveWorkspaceMemberHandler(db, redisClient))
+		r.Get("/shares", handlers.ListSharesHandler(db))
+		r.With(auth.RequireVerifiedEmail).Post("/shares", handlers.CreateShareHandler(db, redisClient))
+		r.Delete("/shares/{id}", handlers.DeleteShareHandler(db, redisClient))
+		r.Get("/invites", handlers.ListInvitesHandler(db))
+		r.With(auth.RequireVerifiedEmail).Post("/invites", handlers.CreateInviteHandler(db, mailer))
+		r.Delete("/invites/{id}", handlers.RevokeInviteHandler(db))
+		r.Post("/invites/{token}/accept", handlers.AcceptInviteHandler(db, redisClient))
 		r.Get("/tasks/{id}", handlers.GetTaskByIDHandler)
 		r.Post("/tasks", handlers.CreateTaskHandler)
 		r.Patch("/tasks/{id}", handlers.PatchTaskHandler)
 		r.Delete("/tasks/{id}", handlers.DeleteTaskHandler)
+		r.Get("/api-keys", handlers.ListAPIKeysHandler(db))
+		r.Post("/api-keys", handlers.CreateAPIKeyHandler(db))
+		r.Delete("/api-keys/{id}", handlers.RevokeAPIKeyHandler(db))
+		r.Post("/auth/oidc/link", handlers.OIDCLinkHandler(db, oidcClient))
+
+	})
 
+	// admin routes
+	r.Route("/admin", func(r chi.Router) {
+		r.Use(auth.JWTMiddleware(db, redisClient))
+		r.Use(auth.RequireRole(auth.RoleAdmin))
+		r.Get("/users", handlers.AdminListUsersHandler(db))
+		r.Get("/users/{id}", handlers.AdminGetUserHandler(db))
+		r.Post("/users/{id}/disable", handlers.AdminDisableUserHandler(db))
+		r.Post("/users/{id}/enable", handlers.AdminEnableUserHandler(db))
+		r.Post("/users/{id}/force-password-reset", handlers.AdminForcePasswordResetHandler(db))
+		r.Post("/users/{id}/impersonate", handlers.AdminImpersonateHandler(db))
+		r.Get("/audit", handlers.AdminAuditHandler(db))
 	})
 
 	// Health Checkup of the API
@@ -0,0 +1,40 @@
+// Command mockoidc runs a local OpenID Connect provider for trying the
+// OIDC login without a real identity provider:
+//
+//	go run ./cmd/mockoidc
+//	OIDC_ISSUER=http://localhost:9999 OIDC_CLIENT_ID=gotasker go run cmd/api/main.go
+//
+// Then open /auth/oidc/login; add ?login_hint=bob@example.com to sign in
+// as someone else.
+package main
+
+import (
+	"log"
+	"net/http"
+	"os"
+
+	"gotasker/internal/oidc/oidctest"
+)
+
+func main() {
+	addr := os.Getenv("MOCK_OIDC_ADDR")
+	if addr == "" {
+		addr = "localhost:9999"
+	}
+	issuer := os.Getenv("MOCK_OIDC_ISSUER")
+	if issuer == "" {
+		issuer = "http://" + addr
+	}
+
+	p, err := oidctest.NewProvider(issuer)
+	if err != nil {
+		log.Fatal(err)
+	}
+	p.ClientSecret = os.Getenv("MOCK_OIDC_CLIENT_SECRET")
+	if email := os.Getenv("MOCK_OIDC_EMAIL"); email != "" {
+		p.DefaultEmail = email
+	}
+
+	log.Printf("mock OIDC provider %s listening on %s", issuer, addr)
+	log.Fatal(http.ListenAndServe(addr, p.Handler()))
+}
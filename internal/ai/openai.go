@@ -3,6 +3,7 @@ package ai
 import (
 	"context"
 	"errors"
+	"strconv"
 )
 
 type OpenAIService struct{}
@@ -21,7 +22,7 @@ func (s *OpenAIService) SummarizeTasks(
 	}
 
 	// TEMP MOCK (replace with real OpenAI call later)
-	summary := "You have " + string(len(input.Titles)+'0') + " tasks. Focus on the most urgent ones."
+	summary := "You have " + strconv.Itoa(len(input.Titles)) + " tasks. Focus on the most urgent ones."
 
 	return TaskSummaryOutput{Summary: summary}, nil
 }
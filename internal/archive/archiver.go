@@ -0,0 +1,125 @@
+// Package archive moves old finished tasks out of users' default lists
+// according to their archive settings.
+package archive
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"time"
+
+	cache "gotasker/internal/redis"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// BatchSize caps how many top-level tasks one statement archives, so
+	// the job never holds locks on a large part of the table.
+	BatchSize = 500
+
+	interval = time.Hour
+)
+
+// ArchiveBatch archives up to BatchSize top-level tasks that have been done,
+// and untouched, for longer than their owner's archive_done_after_days,
+// together with all their subtasks. Unarchiving bumps updated_at, so restored
+// tasks get another full period before they are archived again. It returns
+// the affected user IDs and the number of top-level tasks archived.
+func ArchiveBatch(ctx context.Context, db *sql.DB) ([]int64, int, error) {
+	rows, err := db.QueryContext(ctx, `
+		WITH RECURSIVE batch AS (
+			SELECT t.id
+			FROM tasks t
+			JOIN user_settings s ON s.user_id = t.user_id
+			WHERE s.archive_done_after_days IS NOT NULL
+				AND t.parent_id IS NULL
+				AND t.archived_at IS NULL
+				AND t.done
+				AND GREATEST(t.completed_at, t.updated_at) < NOW() - make_interval(days => s.archive_done_after_days)
+			ORDER BY t.completed_at
+			LIMIT $1
+			FOR UPDATE OF t SKIP LOCKED
+		), subtree AS (
+			SELECT id FROM batch
+			UNION
+			SELECT t.id FROM tasks t JOIN subtree s ON t.parent_id = s.id
+		)
+		UPDATE tasks SET archived_at = NOW()
+		WHERE archived_at IS NULL AND id IN (SELECT id FROM subtree)
+		RETURNING user_id, parent_id IS NULL`, BatchSize)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	seen := map[int64]bool{}
+	var users []int64
+	archived := 0
+	for rows.Next() {
+		var (
+			userID   int64
+			topLevel bool
+		)
+		if err := rows.Scan(&userID, &topLevel); err != nil {
+			return nil, 0, err
+		}
+		if topLevel {
+			archived++
+		}
+		if !seen[userID] {
+			seen[userID] = true
+			users = append(users, userID)
+		}
+	}
+	return users, archived, rows.Err()
+}
+
+// Archiver runs ArchiveBatch periodically until nothing is left to archive.
+type Archiver struct {
+	db  *sql.DB
+	rdb *redis.Client
+}
+
+func NewArchiver(db *sql.DB, rdb *redis.Client) *Archiver {
+	return &Archiver{db: db, rdb: rdb}
+}
+
+// Run archives once at start-up and then every hour until ctx is cancelled.
+func (a *Archiver) Run(ctx context.Context) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		a.runOnce(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (a *Archiver) runOnce(ctx context.Context) {
+	total := 0
+	for ctx.Err() == nil {
+		users, n, err := ArchiveBatch(ctx, a.db)
+		if err != nil {
+			log.Printf("archive batch failed: %v", err)
+			return
+		}
+		for _, userID := range users {
+			if err := cache.DeletTaks(ctx, a.rdb, userID); err != nil {
+				log.Printf("Redis DEL failed: %v", err)
+			}
+		}
+		total += n
+		if n < BatchSize {
+			break
+		}
+	}
+	if total > 0 {
+		log.Printf("archived %d tasks", total)
+	}
+}
@@ -0,0 +1,165 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/redis/go-redis/v9"
+)
+
+// API key scopes. Requests made with a user token have every scope.
+const (
+	ScopeTasksRead  = "tasks:read"
+	ScopeTasksWrite = "tasks:write"
+)
+
+// APIKeyPrefix starts every API key, so leaked keys are easy to spot.
+const APIKeyPrefix = "gtk_"
+
+const (
+	// ScopesContextKey holds the scopes of an API key request; it is not
+	// set for user tokens.
+	ScopesContextKey contextKey = "scopes"
+	// APIKeyContextKey holds the ID of the API key the request used.
+	APIKeyContextKey contextKey = "api_key_id"
+)
+
+// apiKeyTouchInterval throttles last-used updates like sessionTouchInterval.
+const apiKeyTouchInterval = time.Minute
+
+func ValidScope(s string) bool {
+	switch s {
+	case ScopeTasksRead, ScopeTasksWrite:
+		return true
+	default:
+		return false
+	}
+}
+
+// HashAPIKey returns the hex SHA-256 an API key is stored under.
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// Authenticate accepts either a Bearer token, checked like JWTMiddleware
+// does, or an API key in the X-API-Key header. Key requests carry the
+// key's scopes in the context; routes enforce them with RequireScopes.
+func Authenticate(db *sql.DB, rdb *redis.Client) func(http.Handler) http.Handler {
+	jwtMiddleware := JWTMiddleware(db, rdb)
+	return func(next http.Handler) http.Handler {
+		withJWT := jwtMiddleware(next)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get("X-API-Key")
+			if key == "" {
+				withJWT.ServeHTTP(w, r)
+				return
+			}
+			if r.Header.Get("Authorization") != "" {
+				http.Error(w, "send either Authorization or X-API-Key, not both", http.StatusBadRequest)
+				return
+			}
+
+			ctx, status, err := checkAPIKey(r.Context(), db, key)
+			if err != nil {
+				http.Error(w, err.Error(), status)
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// checkAPIKey looks the key up and returns the request context for its
+// user, or the status to answer with. Keys of disabled users, and keys
+// created before an admin forced a password reset, are rejected.
+func checkAPIKey(ctx context.Context, db *sql.DB, key string) (context.Context, int, error) {
+	var (
+		keyID, userID int64
+		scopes        []string
+		lastUsed      *time.Time
+		createdAt     time.Time
+		role          string
+		verified      bool
+		disabled      bool
+		resetRequired *time.Time
+	)
+	err := db.QueryRowContext(ctx, `
+		SELECT k.id, k.user_id, k.scopes, k.last_used_at, k.created_at,
+			u.role, u.email_verified_at IS NOT NULL, u.disabled_at IS NOT NULL, u.password_reset_required_at
+		FROM api_keys k
+		JOIN users u ON u.id = k.user_id
+		WHERE k.key_hash = $1 AND k.revoked_at IS NULL
+		  AND (k.expires_at IS NULL OR k.expires_at > NOW())`, HashAPIKey(key)).
+		Scan(&keyID, &userID, pgtype.NewMap().SQLScanner(&scopes), &lastUsed, &createdAt,
+			&role, &verified, &disabled, &resetRequired)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, http.StatusUnauthorized, errors.New("invalid or expired API key")
+	}
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	if disabled {
+		return nil, http.StatusForbidden, errors.New("account disabled")
+	}
+	if resetRequired != nil && createdAt.Before(*resetRequired) {
+		return nil, http.StatusUnauthorized, errors.New("password reset required")
+	}
+	if !verified && EmailVerificationPolicy == VerificationBlock {
+		return nil, http.StatusForbidden, errors.New("email not verified")
+	}
+
+	if lastUsed == nil || time.Since(*lastUsed) >= apiKeyTouchInterval {
+		if _, err := db.ExecContext(ctx, `
+			UPDATE api_keys SET last_used_at = NOW() WHERE id = $1`, keyID); err != nil {
+			log.Printf("touch api key %d: %v", keyID, err)
+		}
+	}
+
+	if scopes == nil {
+		scopes = []string{}
+	}
+	ctx = context.WithValue(ctx, UserIDContextKey, userID)
+	ctx = context.WithValue(ctx, RoleContextKey, role)
+	ctx = context.WithValue(ctx, EmailVerifiedContextKey, verified)
+	ctx = context.WithValue(ctx, ScopesContextKey, scopes)
+	ctx = context.WithValue(ctx, APIKeyContextKey, keyID)
+	return ctx, 0, nil
+}
+
+// RequireScopes checks API key requests for read on GET and HEAD and for
+// write on every other method. User tokens always pass.
+func RequireScopes(read, write string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			scopes, isKey := r.Context().Value(ScopesContextKey).([]string)
+			if isKey {
+				need := write
+				if r.Method == http.MethodGet || r.Method == http.MethodHead {
+					need = read
+				}
+				if !hasScope(scopes, need) {
+					http.Error(w, "API key lacks scope "+need, http.StatusForbidden)
+					return
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func hasScope(scopes []string, scope string) bool {
+	for _, s := range scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
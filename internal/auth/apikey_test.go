@@ -0,0 +1,65 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+var okHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+})
+
+func TestRequireScopes(t *testing.T) {
+	h := RequireScopes(ScopeTasksRead, ScopeTasksWrite)(okHandler)
+	readOnly := []string{ScopeTasksRead}
+
+	tests := []struct {
+		name   string
+		method string
+		scopes []string // nil: a user token
+		want   int
+	}{
+		{"token GET", http.MethodGet, nil, http.StatusOK},
+		{"token POST", http.MethodPost, nil, http.StatusOK},
+		{"read key GET", http.MethodGet, readOnly, http.StatusOK},
+		{"read key HEAD", http.MethodHead, readOnly, http.StatusOK},
+		{"read key POST", http.MethodPost, readOnly, http.StatusForbidden},
+		{"read key DELETE", http.MethodDelete, readOnly, http.StatusForbidden},
+		{"write key GET", http.MethodGet, []string{ScopeTasksWrite}, http.StatusForbidden},
+		{"write key PATCH", http.MethodPatch, []string{ScopeTasksWrite}, http.StatusOK},
+		{"key without scopes", http.MethodGet, []string{}, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(tt.method, "/tasksdb", nil)
+		if tt.scopes != nil {
+			r = r.WithContext(context.WithValue(r.Context(), ScopesContextKey, tt.scopes))
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, r)
+		if rec.Code != tt.want {
+			t.Errorf("%s: status %d, want %d", tt.name, rec.Code, tt.want)
+		}
+	}
+}
+
+func TestAPIKeysOnlyWhereAccepted(t *testing.T) {
+	// Neither check reaches the database.
+	r := httptest.NewRequest(http.MethodGet, "/tasksdb", nil)
+	r.Header.Set("X-API-Key", APIKeyPrefix+"secret")
+	r.Header.Set("Authorization", "Bearer token")
+	rec := httptest.NewRecorder()
+	Authenticate(nil, nil)(okHandler).ServeHTTP(rec, r)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("key and token: status %d, want 400", rec.Code)
+	}
+
+	r = httptest.NewRequest(http.MethodGet, "/sessions", nil)
+	r.Header.Set("X-API-Key", APIKeyPrefix+"secret")
+	rec = httptest.NewRecorder()
+	JWTMiddleware(nil, nil)(okHandler).ServeHTTP(rec, r)
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("key on an account route: status %d, want 401", rec.Code)
+	}
+}
@@ -1,14 +1,56 @@
 package auth
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"os"
+	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 )
 
+// Token lifetimes. Access tokens are short-lived; clients renew them with
+// the refresh token.
+const (
+	AccessTokenTTL  = 15 * time.Minute
+	RefreshTokenTTL = 30 * 24 * time.Hour
+)
+
+// User roles.
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
+
 type JWTClaims struct {
-	UserID int64 `json:"sub"`
+	UserID int64  `json:"sub"`
+	Role   string `json:"role,omitempty"`
+	// ImpersonatorID is set on tokens an admin obtained to act as UserID.
+	ImpersonatorID *int64 `json:"imp,omitempty"`
+	// SessionID is the login session the token belongs to.
+	SessionID int64 `json:"sid,omitempty"`
+	// Epoch is the user's token epoch at issue time; logging out
+	// everywhere bumps it.
+	Epoch int64 `json:"epoch"`
 	jwt.RegisteredClaims
 }
 
 var JwtSecret = []byte(os.Getenv("JWT_SECRET"))
+
+// DenylistFailClosed rejects requests when the token denylist cannot be
+// checked. By default (TOKEN_DENYLIST_FAIL_MODE=open) they are let through
+// and only logged out tokens slip by until they expire.
+var DenylistFailClosed = os.Getenv("TOKEN_DENYLIST_FAIL_MODE") == "closed"
+
+// SignToken returns the HS256-signed token for claims. A random jti is
+// added when claims has none, so the token can be revoked on its own.
+func SignToken(claims JWTClaims) (string, error) {
+	if claims.ID == "" {
+		b := make([]byte, 16)
+		if _, err := rand.Read(b); err != nil {
+			return "", err
+		}
+		claims.ID = hex.EncodeToString(b)
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(JwtSecret)
+}
@@ -2,63 +2,218 @@ package auth
 
 import (
 	"context"
+	"database/sql"
+	"errors"
+	"log"
 	"net/http"
 	"strings"
+	"time"
+
+	internalRedis "gotasker/internal/redis"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/redis/go-redis/v9"
 )
 
 type contextKey string
 
-const UserIDContextKey contextKey = "user_id"
+const (
+	UserIDContextKey contextKey = "user_id"
+	RoleContextKey   contextKey = "role"
+	// ImpersonatorContextKey holds the admin's user ID on impersonated
+	// requests.
+	ImpersonatorContextKey contextKey = "impersonator_id"
+	// ClaimsContextKey holds the request's *JWTClaims.
+	ClaimsContextKey contextKey = "claims"
+	// EmailVerifiedContextKey holds whether the user verified their email.
+	EmailVerifiedContextKey contextKey = "email_verified"
+)
 
-func JWTMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+// sessionTouchInterval throttles last-seen updates: a session's
+// last_seen_at is written at most this often, not on every request.
+const sessionTouchInterval = 5 * time.Minute
 
-		//1. Read Authorization header
-		authHeader := r.Header.Get("Authorization")
-		if authHeader == "" {
-			http.Error(w, "missing authorization header", http.StatusUnauthorized)
-			return
-		}
+// JWTMiddleware authenticates requests with a Bearer token. Logged out
+// tokens (on the Redis denylist, from a revoked session or from an older
+// token epoch), tokens of disabled or deleted users, tokens issued before
+// an admin forced a password reset and tokens carrying a role the user no
+// longer has are rejected.
+func JWTMiddleware(db *sql.DB, rdb *redis.Client) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 
-		//2. Expect: Bearer <token>
-		parts := strings.SplitN(authHeader, " ", 2)
-		if len(parts) != 2 || parts[0] != "Bearer" {
-			http.Error(w, "invalid authorization header", http.StatusUnauthorized)
-			return
-		}
+			//1. Read Authorization header
+			authHeader := r.Header.Get("Authorization")
+			if authHeader == "" {
+				http.Error(w, "missing authorization header", http.StatusUnauthorized)
+				return
+			}
 
-		tokenString := parts[1]
+			//2. Expect: Bearer <token>
+			parts := strings.SplitN(authHeader, " ", 2)
+			if len(parts) != 2 || parts[0] != "Bearer" {
+				http.Error(w, "invalid authorization header", http.StatusUnauthorized)
+				return
+			}
 
-		//3. Parse & Verify token
-		token, err := jwt.ParseWithClaims(
-			tokenString,
-			&JWTClaims{},
-			func(t *jwt.Token) (interface{}, error) {
-				return JwtSecret, nil
-			},
-		)
+			tokenString := parts[1]
 
-		if err != nil || !token.Valid {
-			http.Error(w, "invalid or expired token", http.StatusUnauthorized)
-			return
-		}
+			//3. Parse & Verify token
+			token, err := jwt.ParseWithClaims(
+				tokenString,
+				&JWTClaims{},
+				func(t *jwt.Token) (interface{}, error) {
+					return JwtSecret, nil
+				},
+				jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+			)
+
+			if err != nil || !token.Valid {
+				http.Error(w, "invalid or expired token", http.StatusUnauthorized)
+				return
+			}
+
+			//4. Extract claims
+			claims, ok := token.Claims.(*JWTClaims)
+			if !ok {
+				http.Error(w, "invalid token claims", http.StatusUnauthorized)
+				return
+			}
+
+			//5. Check the token was not logged out
+			if status, err := checkDenylist(r.Context(), rdb, claims); err != nil {
+				http.Error(w, err.Error(), status)
+				return
+			}
 
-		//4. Extract claims
-		claims, ok := token.Claims.(*JWTClaims)
-		if !ok {
-			http.Error(w, "invalid token claims", http.StatusUnauthorized)
-			return
+			//6. Check the account is still usable
+			verified, status, err := checkAccount(r.Context(), db, claims)
+			if err != nil {
+				http.Error(w, err.Error(), status)
+				return
+			}
+
+			//7. Store user_id, role and claims in context
+			ctx := context.WithValue(r.Context(), UserIDContextKey, claims.UserID)
+			ctx = context.WithValue(ctx, RoleContextKey, claims.Role)
+			ctx = context.WithValue(ctx, ClaimsContextKey, claims)
+			ctx = context.WithValue(ctx, EmailVerifiedContextKey, verified)
+			if claims.ImpersonatorID != nil {
+				ctx = context.WithValue(ctx, ImpersonatorContextKey, *claims.ImpersonatorID)
+			}
+
+			//8. Continue request
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// checkDenylist rejects tokens that were logged out. If Redis cannot be
+// asked, the request fails or passes depending on DenylistFailClosed. It
+// returns the status to answer with.
+func checkDenylist(ctx context.Context, rdb *redis.Client, claims *JWTClaims) (int, error) {
+	if claims.ID == "" {
+		return 0, nil
+	}
+	denied, err := internalRedis.IsTokenDenied(ctx, rdb, claims.ID)
+	if err != nil {
+		if !errors.Is(err, internalRedis.ErrNoClient) {
+			log.Printf("token denylist check failed: %v", err)
 		}
+		if DenylistFailClosed {
+			return http.StatusServiceUnavailable, errors.New("token revocation check unavailable")
+		}
+		return 0, nil
+	}
+	if denied {
+		return http.StatusUnauthorized, errors.New("token revoked")
+	}
+	return 0, nil
+}
 
-		//5. Store user_id in context
-		ctx := context.WithValue(r.Context(), UserIDContextKey, claims.UserID)
+// checkAccount rejects tokens of users who were deleted, disabled or (under
+// VerificationBlock) are unverified, of revoked sessions, and tokens that
+// predate the user's token epoch or a forced password reset or carry a
+// role the user no longer has. It returns whether the user's email is
+// verified, or the status to answer with. The token's session is marked as
+// seen along the way.
+func checkAccount(ctx context.Context, db *sql.DB, claims *JWTClaims) (bool, int, error) {
+	var (
+		verified       bool
+		disabled       bool
+		role           string
+		resetRequired  *time.Time
+		epoch          int64
+		sessionRevoked *bool
+		lastSeen       *time.Time
+	)
+	err := db.QueryRowContext(ctx, `
+		SELECT u.email_verified_at IS NOT NULL, u.disabled_at IS NOT NULL, u.role,
+			u.password_reset_required_at, u.token_epoch,
+			s.revoked_at IS NOT NULL, s.last_seen_at
+		FROM users u
+		LEFT JOIN sessions s ON s.id = $2 AND s.user_id = u.id
+		WHERE u.id = $1`, claims.UserID, claims.SessionID).
+		Scan(&verified, &disabled, &role, &resetRequired, &epoch, &sessionRevoked, &lastSeen)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, http.StatusUnauthorized, errors.New("user no longer exists")
+	}
+	if err != nil {
+		return false, http.StatusInternalServerError, err
+	}
+	if disabled {
+		return false, http.StatusForbidden, errors.New("account disabled")
+	}
+	if !verified && EmailVerificationPolicy == VerificationBlock {
+		return false, http.StatusForbidden, errors.New("email not verified")
+	}
+	if claims.Epoch < epoch {
+		return false, http.StatusUnauthorized, errors.New("token revoked")
+	}
+	// A demoted admin's token must not keep admin rights until it expires;
+	// refreshing issues one with the current role.
+	if claims.Role != role {
+		return false, http.StatusUnauthorized, errors.New("role changed")
+	}
+	if claims.SessionID != 0 && (sessionRevoked == nil || *sessionRevoked) {
+		return false, http.StatusUnauthorized, errors.New("session revoked")
+	}
+	if resetRequired != nil && (claims.IssuedAt == nil || claims.IssuedAt.Before(*resetRequired)) {
+		return false, http.StatusUnauthorized, errors.New("password reset required")
+	}
+	if lastSeen != nil && time.Since(*lastSeen) >= sessionTouchInterval {
+		touchSession(ctx, db, claims.SessionID)
+	}
+	return verified, 0, nil
+}
 
-		//6. Continue request
-		next.ServeHTTP(w, r.WithContext(ctx))
-	})
+// touchSession updates the session's last_seen_at. Failing to do so is
+// not worth failing the request over.
+func touchSession(ctx context.Context, db *sql.DB, sessionID int64) {
+	if _, err := db.ExecContext(ctx, `
+		UPDATE sessions SET last_seen_at = NOW() WHERE id = $1`, sessionID); err != nil {
+		log.Printf("touch session %d: %v", sessionID, err)
+	}
+}
 
+// RequireRole only lets requests through whose token carries role, which
+// JWTMiddleware has checked against the user's current role.
+// Impersonated requests never pass, so an admin acting as someone else
+// cannot reach admin routes.
+func RequireRole(role string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if _, impersonated := r.Context().Value(ImpersonatorContextKey).(int64); impersonated {
+				http.Error(w, "forbidden", http.StatusForbidden)
+				return
+			}
+			if got, _ := r.Context().Value(RoleContextKey).(string); got != role {
+				http.Error(w, "forbidden", http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
 }
 
 func userIDContextKey(ctx context.Context) (int64, bool) {
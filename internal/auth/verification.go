@@ -0,0 +1,46 @@
+package auth
+
+import (
+	"log"
+	"net/http"
+	"os"
+)
+
+// Policies for users who have not verified their email, chosen with
+// EMAIL_VERIFICATION_POLICY.
+const (
+	// VerificationAllow lets unverified users do everything.
+	VerificationAllow = "allow"
+	// VerificationRestricted lets them log in, but routes wrapped in
+	// RequireVerifiedEmail are refused. This is the default.
+	VerificationRestricted = "restricted"
+	// VerificationBlock refuses login until the email is verified.
+	VerificationBlock = "block"
+)
+
+var EmailVerificationPolicy = func() string {
+	switch p := os.Getenv("EMAIL_VERIFICATION_POLICY"); p {
+	case VerificationAllow, VerificationRestricted, VerificationBlock:
+		return p
+	case "":
+		return VerificationRestricted
+	default:
+		log.Printf("unknown EMAIL_VERIFICATION_POLICY %q; using %q", p, VerificationRestricted)
+		return VerificationRestricted
+	}
+}()
+
+// RequireVerifiedEmail refuses requests of users with an unverified email
+// when the policy is VerificationRestricted. It must run after
+// JWTMiddleware.
+func RequireVerifiedEmail(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if EmailVerificationPolicy == VerificationRestricted {
+			if verified, _ := r.Context().Value(EmailVerifiedContextKey).(bool); !verified {
+				http.Error(w, "email not verified", http.StatusForbidden)
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
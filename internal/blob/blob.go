@@ -0,0 +1,58 @@
+// Package blob stores attachment contents outside the database.
+package blob
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+var ErrNotFound = errors.New("blob not found")
+
+// BlobStore keeps opaque objects under string keys.
+type BlobStore interface {
+	// Put stores size bytes from r under key. sha256Hex is the hex SHA-256
+	// of the content, already computed by the caller.
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType, sha256Hex string) error
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete removes key; deleting a missing key is not an error.
+	Delete(ctx context.Context, key string) error
+}
+
+// Presigner is implemented by stores that can hand out time-limited URLs
+// clients download from directly. Stores without it are served through the
+// API's own signed download route.
+type Presigner interface {
+	PresignGet(key, filename string, ttl time.Duration) (string, error)
+}
+
+// NewFromEnv builds the store selected by BLOB_BACKEND: "fs" (default,
+// rooted at BLOB_DIR) or "s3" (S3_ENDPOINT, S3_REGION, S3_BUCKET,
+// S3_ACCESS_KEY, S3_SECRET_KEY).
+func NewFromEnv() (BlobStore, error) {
+	switch backend := os.Getenv("BLOB_BACKEND"); backend {
+	case "", "fs":
+		dir := os.Getenv("BLOB_DIR")
+		if dir == "" {
+			dir = "data/blobs"
+		}
+		return NewFSStore(dir)
+	case "s3":
+		region := os.Getenv("S3_REGION")
+		if region == "" {
+			region = "us-east-1"
+		}
+		return NewS3Store(S3Config{
+			Endpoint:  os.Getenv("S3_ENDPOINT"),
+			Region:    region,
+			Bucket:    os.Getenv("S3_BUCKET"),
+			AccessKey: os.Getenv("S3_ACCESS_KEY"),
+			SecretKey: os.Getenv("S3_SECRET_KEY"),
+		})
+	default:
+		return nil, fmt.Errorf("unknown BLOB_BACKEND %q", backend)
+	}
+}
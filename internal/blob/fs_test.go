@@ -0,0 +1,91 @@
+package blob
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFSStore(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "blobs")
+	s, err := NewFSStore(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	const key = "attachments/7/abc"
+	if err := s.Put(ctx, key, strings.NewReader("first"), 5, "text/plain", ""); err != nil {
+		t.Fatalf("put: %v", err)
+	}
+	// Putting again replaces the content.
+	if err := s.Put(ctx, key, strings.NewReader("second"), 6, "text/plain", ""); err != nil {
+		t.Fatalf("overwrite: %v", err)
+	}
+
+	rc, err := s.Open(ctx, key)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	got, err := io.ReadAll(rc)
+	rc.Close()
+	if err != nil || string(got) != "second" {
+		t.Fatalf("open: got %q, %v", got, err)
+	}
+
+	if err := s.Delete(ctx, key); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if _, err := s.Open(ctx, key); !errors.Is(err, ErrNotFound) {
+		t.Errorf("open after delete: %v, want ErrNotFound", err)
+	}
+	if err := s.Delete(ctx, key); err != nil {
+		t.Errorf("deleting a missing key: %v", err)
+	}
+}
+
+func TestFSStoreSizeMismatchLeavesNothing(t *testing.T) {
+	root := t.TempDir()
+	s, err := NewFSStore(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	if err := s.Put(ctx, "a/b", strings.NewReader("short"), 10, "", ""); err == nil {
+		t.Fatal("put with the wrong size: want error")
+	}
+	if _, err := s.Open(ctx, "a/b"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("open: %v, want ErrNotFound", err)
+	}
+	entries, err := os.ReadDir(filepath.Join(root, "a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("temporary files left behind: %v", entries)
+	}
+}
+
+func TestFSStoreRejectsKeysOutsideRoot(t *testing.T) {
+	s, err := NewFSStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	for _, key := range []string{"", "../escape", "a/../../escape", "/etc/passwd"} {
+		if err := s.Put(ctx, key, strings.NewReader("x"), 1, "", ""); err == nil {
+			t.Errorf("put %q: want error", key)
+		}
+		if _, err := s.Open(ctx, key); err == nil || errors.Is(err, ErrNotFound) {
+			t.Errorf("open %q: %v, want an invalid key error", key, err)
+		}
+		if err := s.Delete(ctx, key); err == nil {
+			t.Errorf("delete %q: want error", key)
+		}
+	}
+}
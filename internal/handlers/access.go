@@ -0,0 +1,292 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"gotasker/internal/models"
+	cache "gotasker/internal/redis"
+	"gotasker/internal/workflow"
+	"gotasker/internal/workspace"
+
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	errNotMember        = errors.New("not a member of this workspace")
+	errInvalidWorkspace = errors.New("invalid workspace ID")
+)
+
+// ensurePersonalWorkspace returns the user's personal workspace, creating it
+// (with the user as owner) if it does not exist yet.
+func ensurePersonalWorkspace(ctx context.Context, q dbtx, userID int64) (int64, error) {
+	var id int64
+	err := q.QueryRowContext(ctx, `
+		SELECT id FROM workspaces WHERE personal AND created_by = $1`, userID).Scan(&id)
+	if err == nil {
+		return id, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return 0, err
+	}
+
+	err = q.QueryRowContext(ctx, `
+		WITH ws AS (
+			INSERT INTO workspaces (name, personal, created_by)
+			VALUES ('Personal', TRUE, $1)
+			ON CONFLICT (created_by) WHERE personal DO UPDATE SET updated_at = workspaces.updated_at
+			RETURNING id
+		), member AS (
+			INSERT INTO workspace_members (workspace_id, user_id, role)
+			SELECT id, $1, 'owner' FROM ws
+			ON CONFLICT DO NOTHING
+		)
+		SELECT id FROM ws`, userID).Scan(&id)
+	return id, err
+}
+
+// workspaceRole returns the user's role in the workspace, or "" if they are
+// not a member.
+func workspaceRole(ctx context.Context, q dbtx, userID, workspaceID int64) (string, error) {
+	var role string
+	err := q.QueryRowContext(ctx, `
+		SELECT role FROM workspace_members WHERE workspace_id = $1 AND user_id = $2`,
+		workspaceID, userID).Scan(&role)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	return role, err
+}
+
+// listShareRole returns the role a share of the workspace's list gives the
+// user, or "" if there is none.
+func listShareRole(ctx context.Context, q dbtx, userID, workspaceID int64) (string, error) {
+	var permission string
+	err := q.QueryRowContext(ctx, `
+		SELECT permission FROM share_grants WHERE workspace_id = $1 AND grantee_id = $2`,
+		workspaceID, userID).Scan(&permission)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return shareRole(permission), nil
+}
+
+// shareRole maps a share permission to the role it acts as.
+func shareRole(permission string) string {
+	if permission == workspace.PermissionEdit {
+		return workspace.RoleMember
+	}
+	return workspace.RoleViewer
+}
+
+// requestWorkspace resolves the workspace a request works in: the
+// X-Workspace-ID header or ?workspace_id=, else the user's personal
+// workspace. It fails with errNotMember if the user is neither a member
+// nor the grantee of a share of the workspace's list.
+func requestWorkspace(r *http.Request, q dbtx, userID int64) (int64, string, error) {
+	ctx := r.Context()
+	raw := strings.TrimSpace(r.Header.Get("X-Workspace-ID"))
+	if raw == "" {
+		raw = strings.TrimSpace(r.URL.Query().Get("workspace_id"))
+	}
+
+	if raw == "" {
+		id, err := ensurePersonalWorkspace(ctx, q, userID)
+		return id, workspace.RoleOwner, err
+	}
+
+	id, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil {
+		return 0, "", errInvalidWorkspace
+	}
+	role, err := workspaceRole(ctx, q, userID, id)
+	if err != nil {
+		return 0, "", err
+	}
+	if role == "" {
+		role, err = listShareRole(ctx, q, userID, id)
+		if err != nil {
+			return 0, "", err
+		}
+	}
+	if role == "" {
+		return 0, "", errNotMember
+	}
+	return id, role, nil
+}
+
+// writeWorkspaceError answers a requestWorkspace failure.
+func writeWorkspaceError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, errInvalidWorkspace):
+		WriteJson(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+	case errors.Is(err, errNotMember):
+		WriteJson(w, http.StatusForbidden, map[string]string{"error": err.Error()})
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// grantCoversTask is the SQL condition under which share grant g covers
+// task t: a grant on its list, on the task itself, or on its parent.
+const grantCoversTask = `(g.workspace_id = t.workspace_id OR g.task_id = t.id OR g.task_id = t.parent_id)`
+
+// taskAccess returns the task's workspace and the user's role on the task:
+// their workspace role, or the role a share grant gives them, whichever is
+// higher. The role is "" if the task does not exist or the user cannot see
+// it.
+func taskAccess(ctx context.Context, q dbtx, userID int64, taskID int) (int64, string, error) {
+	var (
+		workspaceID int64
+		role        sql.NullString
+		shareEdit   sql.NullBool
+	)
+	err := q.QueryRowContext(ctx, `
+		SELECT t.workspace_id, m.role,
+			(SELECT bool_or(g.permission = 'edit') FROM share_grants g
+				WHERE g.grantee_id = $2 AND `+grantCoversTask+`)
+		FROM tasks t
+		LEFT JOIN workspace_members m ON m.workspace_id = t.workspace_id AND m.user_id = $2
+		WHERE t.id = $1`, taskID, userID).Scan(&workspaceID, &role, &shareEdit)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, "", nil
+	}
+	if err != nil {
+		return 0, "", err
+	}
+
+	r := role.String
+	if shareEdit.Valid {
+		shared := shareRole(workspace.PermissionView)
+		if shareEdit.Bool {
+			shared = shareRole(workspace.PermissionEdit)
+		}
+		if !workspace.AtLeast(r, shared) {
+			r = shared
+		}
+	}
+	return workspaceID, r, nil
+}
+
+// canViewTask reports whether userID may read the task.
+func canViewTask(ctx context.Context, q dbtx, userID int64, taskID int) (bool, error) {
+	_, role, err := taskAccess(ctx, q, userID, taskID)
+	return workspace.CanRead(role), err
+}
+
+// canEditTask reports whether userID may change the task.
+func canEditTask(ctx context.Context, q dbtx, userID int64, taskID int) (bool, error) {
+	_, role, err := taskAccess(ctx, q, userID, taskID)
+	return workspace.CanWrite(role), err
+}
+
+// queryIDs runs a query selecting a single BIGINT column.
+func queryIDs(ctx context.Context, q dbtx, query string, args ...any) ([]int64, error) {
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// taskViewerIDs returns every user who can see the task: the workspace's
+// members and the grantees of shares covering it. It is empty if the task
+// does not exist.
+func taskViewerIDs(ctx context.Context, q dbtx, taskID int) ([]int64, error) {
+	return queryIDs(ctx, q, `
+		SELECT m.user_id
+		FROM tasks t
+		JOIN workspace_members m ON m.workspace_id = t.workspace_id
+		WHERE t.id = $1
+		UNION
+		SELECT g.grantee_id
+		FROM tasks t
+		JOIN share_grants g ON `+grantCoversTask+`
+		WHERE t.id = $1
+		ORDER BY 1`, taskID)
+}
+
+// workspaceGranteeIDs returns the users a share grant gives access to the
+// workspace's list or to any task in it.
+func workspaceGranteeIDs(ctx context.Context, q dbtx, workspaceID int64) ([]int64, error) {
+	return queryIDs(ctx, q, `
+		SELECT DISTINCT g.grantee_id
+		FROM share_grants g
+		LEFT JOIN tasks t ON t.id = g.task_id
+		WHERE g.workspace_id = $1 OR t.workspace_id = $1`, workspaceID)
+}
+
+// invalidateUsers drops every cached task list of the given users.
+func invalidateUsers(ctx context.Context, rdb *redis.Client, userIDs ...int64) {
+	for _, id := range userIDs {
+		if err := cache.DeletTaks(ctx, rdb, id); err != nil {
+			log.Printf("Redis DEL failed: %v", err)
+		}
+	}
+}
+
+// invalidateWorkspace drops the cached task lists of every member of the
+// workspace and of everyone its tasks are shared with, and the stats of
+// the task's creator.
+func invalidateWorkspace(ctx context.Context, q dbtx, rdb *redis.Client, workspaceID, creatorID int64) {
+	if err := cache.DeleteWorkspaceTasks(ctx, rdb, workspaceID); err != nil {
+		log.Printf("Redis DEL failed: %v", err)
+	}
+	grantees, err := workspaceGranteeIDs(ctx, q, workspaceID)
+	if err != nil {
+		log.Printf("loading share grantees failed: %v", err)
+	}
+	invalidateUsers(ctx, rdb, append(grantees, creatorID)...)
+}
+
+// invalidateTaskViewers drops the cached task lists of everyone who sees
+// the task, e.g. after its comment count or tracked time changed.
+func invalidateTaskViewers(ctx context.Context, q dbtx, rdb *redis.Client, taskID int) {
+	var workspaceID, creatorID int64
+	err := q.QueryRowContext(ctx, `
+		SELECT workspace_id, user_id FROM tasks WHERE id = $1`, taskID).Scan(&workspaceID, &creatorID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return
+	}
+	if err != nil {
+		log.Printf("loading task workspace failed: %v", err)
+		return
+	}
+	invalidateWorkspace(ctx, q, rdb, workspaceID, creatorID)
+}
+
+// workspaceWorkflow returns the workflow tasks in the workspace follow: that
+// of its longest-standing owner.
+func workspaceWorkflow(ctx context.Context, q dbtx, workspaceID int64) (models.Workflow, error) {
+	var ownerID int64
+	err := q.QueryRowContext(ctx, `
+		SELECT user_id FROM workspace_members
+		WHERE workspace_id = $1 AND role = 'owner'
+		ORDER BY created_at, user_id
+		LIMIT 1`, workspaceID).Scan(&ownerID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return workflow.Default(), nil
+	}
+	if err != nil {
+		return models.Workflow{}, err
+	}
+	return loadWorkflow(ctx, q, ownerID)
+}
@@ -0,0 +1,421 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"gotasker/internal/auth"
+	"gotasker/internal/models"
+
+	"github.com/go-chi/chi"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// impersonationTTL bounds how long an impersonation token is valid.
+const impersonationTTL = time.Hour
+
+// Admin audit actions.
+const (
+	auditDisable     = "disable"
+	auditEnable      = "enable"
+	auditForceReset  = "force_password_reset"
+	auditImpersonate = "impersonate"
+)
+
+// adminUserSelect selects what scanAdminUser expects, task counts included.
+const adminUserSelect = `
+		SELECT u.id, u.email, u.role, u.disabled_at, u.password_reset_required_at IS NOT NULL, u.created_at,
+			COALESCE(c.total, 0), COALESCE(c.open, 0), COALESCE(c.done, 0), COALESCE(c.archived, 0)
+		FROM users u
+		LEFT JOIN LATERAL (
+			SELECT COUNT(*) AS total,
+				COUNT(*) FILTER (WHERE NOT done AND archived_at IS NULL) AS open,
+				COUNT(*) FILTER (WHERE done AND archived_at IS NULL) AS done,
+				COUNT(*) FILTER (WHERE archived_at IS NOT NULL) AS archived
+			FROM tasks WHERE user_id = u.id
+		) c ON TRUE`
+
+func scanAdminUser(s rowScanner) (models.AdminUser, error) {
+	var u models.AdminUser
+	err := s.Scan(&u.ID, &u.Email, &u.Role, &u.DisabledAt, &u.PasswordResetRequired, &u.CreatedAt,
+		&u.Tasks.Total, &u.Tasks.Open, &u.Tasks.Done, &u.Tasks.Archived)
+	return u, err
+}
+
+// recordAudit logs an admin action against targetID.
+func recordAudit(ctx context.Context, q dbtx, r *http.Request, adminID, targetID int64, action string, data any) error {
+	b, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	_, err = q.ExecContext(ctx, `
+		INSERT INTO admin_audit (admin_id, target_user_id, action, data, ip)
+		VALUES ($1, $2, $3, $4::jsonb, $5)`, adminID, targetID, action, string(b), remoteIP(r))
+	return err
+}
+
+// AdminListUsersHandler lists users with their task counts. ?q= searches
+// emails, ?role= and ?disabled= filter; limit/offset paginate.
+func AdminListUsersHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+
+		var (
+			args   []any
+			where  = "WHERE TRUE"
+			argPos = 1
+		)
+		if q := strings.TrimSpace(query.Get("q")); q != "" {
+			where += fmt.Sprintf(" AND u.email ILIKE $%d", argPos)
+			args = append(args, "%"+q+"%")
+			argPos++
+		}
+		if role := strings.TrimSpace(query.Get("role")); role != "" {
+			if role != auth.RoleUser && role != auth.RoleAdmin {
+				WriteJson(w, http.StatusBadRequest, map[string]string{"error": "invalid role"})
+				return
+			}
+			where += fmt.Sprintf(" AND u.role = $%d", argPos)
+			args = append(args, role)
+			argPos++
+		}
+		if v := strings.TrimSpace(query.Get("disabled")); v != "" {
+			disabled, err := strconv.ParseBool(v)
+			if err != nil {
+				WriteJson(w, http.StatusBadRequest, map[string]string{"error": "invalid disabled param"})
+				return
+			}
+			where += fmt.Sprintf(" AND (u.disabled_at IS NOT NULL) = $%d", argPos)
+			args = append(args, disabled)
+			argPos++
+		}
+
+		limit := 20
+		if v := strings.TrimSpace(query.Get("limit")); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n <= 0 || n > 100 {
+				WriteJson(w, http.StatusBadRequest, map[string]string{"error": "invalid limit"})
+				return
+			}
+			limit = n
+		}
+		offset := 0
+		if v := strings.TrimSpace(query.Get("offset")); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				WriteJson(w, http.StatusBadRequest, map[string]string{"error": "invalid offset"})
+				return
+			}
+			offset = n
+		}
+		args = append(args, limit, offset)
+
+		rows, err := db.QueryContext(r.Context(), fmt.Sprintf(`%s
+			%s
+			ORDER BY u.id
+			LIMIT $%d OFFSET $%d`, adminUserSelect, where, argPos, argPos+1), args...)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer rows.Close()
+
+		users := make([]models.AdminUser, 0)
+		for rows.Next() {
+			u, err := scanAdminUser(rows)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			users = append(users, u)
+		}
+		if err := rows.Err(); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		WriteJson(w, http.StatusOK, users)
+	}
+}
+
+// adminTarget parses {id} and loads that user, answering the request itself
+// when that fails.
+func adminTarget(w http.ResponseWriter, r *http.Request, db *sql.DB) (models.AdminUser, bool) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		WriteJson(w, http.StatusBadRequest, map[string]string{"error": "Invalid user ID"})
+		return models.AdminUser{}, false
+	}
+	u, err := scanAdminUser(db.QueryRowContext(r.Context(), adminUserSelect+` WHERE u.id = $1`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		WriteJson(w, http.StatusNotFound, map[string]string{"error": "User not found"})
+		return models.AdminUser{}, false
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return models.AdminUser{}, false
+	}
+	return u, true
+}
+
+// AdminGetUserHandler returns one user with their task counts.
+func AdminGetUserHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		u, ok := adminTarget(w, r, db)
+		if !ok {
+			return
+		}
+		WriteJson(w, http.StatusOK, u)
+	}
+}
+
+// AdminDisableUserHandler and AdminEnableUserHandler lock a user out or let
+// them back in. A disabled user's tokens stop working immediately.
+func AdminDisableUserHandler(db *sql.DB) http.HandlerFunc {
+	return setUserDisabled(db, true)
+}
+
+func AdminEnableUserHandler(db *sql.DB) http.HandlerFunc {
+	return setUserDisabled(db, false)
+}
+
+func setUserDisabled(db *sql.DB, disabled bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		adminID, ok := currentUserID(r)
+		if !ok {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		u, ok := adminTarget(w, r, db)
+		if !ok {
+			return
+		}
+		if disabled && u.ID == adminID {
+			WriteJson(w, http.StatusBadRequest, map[string]string{"error": "cannot disable yourself"})
+			return
+		}
+
+		ctx := r.Context()
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer tx.Rollback()
+
+		action := auditEnable
+		if disabled {
+			action = auditDisable
+		}
+		if err := tx.QueryRowContext(ctx, `
+			UPDATE users SET
+				disabled_at = CASE WHEN $1 THEN COALESCE(disabled_at, NOW()) END,
+				updated_at = NOW()
+			WHERE id = $2
+			RETURNING disabled_at`, disabled, u.ID).Scan(&u.DisabledAt); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if err := recordAudit(ctx, tx, r, adminID, u.ID, action, struct{}{}); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if err := tx.Commit(); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		WriteJson(w, http.StatusOK, u)
+	}
+}
+
+// AdminForcePasswordResetHandler invalidates the user's tokens and refuses
+// password login until they reset their password.
+func AdminForcePasswordResetHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		adminID, ok := currentUserID(r)
+		if !ok {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		u, ok := adminTarget(w, r, db)
+		if !ok {
+			return
+		}
+
+		ctx := r.Context()
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer tx.Rollback()
+
+		if _, err := tx.ExecContext(ctx, `
+			UPDATE users SET password_reset_required_at = NOW(), updated_at = NOW()
+			WHERE id = $1`, u.ID); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if err := recordAudit(ctx, tx, r, adminID, u.ID, auditForceReset, struct{}{}); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if err := tx.Commit(); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		u.PasswordResetRequired = true
+
+		WriteJson(w, http.StatusOK, u)
+	}
+}
+
+// AdminImpersonateHandler issues a short-lived token to act as the user.
+// The token names the admin, cannot reach admin routes, and its issue is
+// written to the audit log together with the given reason.
+func AdminImpersonateHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req models.ImpersonateRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			WriteJson(w, http.StatusBadRequest, map[string]string{"error": "Invalid Json"})
+			return
+		}
+		req.Reason = strings.TrimSpace(req.Reason)
+		if req.Reason == "" {
+			WriteJson(w, http.StatusBadRequest, map[string]string{"error": "reason is required"})
+			return
+		}
+
+		adminID, ok := currentUserID(r)
+		if !ok {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		u, ok := adminTarget(w, r, db)
+		if !ok {
+			return
+		}
+		if u.ID == adminID {
+			WriteJson(w, http.StatusBadRequest, map[string]string{"error": "cannot impersonate yourself"})
+			return
+		}
+		if u.DisabledAt != nil {
+			WriteJson(w, http.StatusConflict, map[string]string{"error": "account disabled"})
+			return
+		}
+
+		epoch, err := tokenEpoch(r.Context(), db, u.ID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		now := time.Now()
+		expiresAt := now.Add(impersonationTTL)
+		token, err := auth.SignToken(auth.JWTClaims{
+			UserID:         u.ID,
+			Role:           u.Role,
+			ImpersonatorID: &adminID,
+			Epoch:          epoch,
+			RegisteredClaims: jwt.RegisteredClaims{
+				ExpiresAt: jwt.NewNumericDate(expiresAt),
+				IssuedAt:  jwt.NewNumericDate(now),
+			},
+		})
+		if err != nil {
+			WriteJson(w, http.StatusInternalServerError, map[string]string{"error": "failed to generate token"})
+			return
+		}
+
+		// The token is only handed out once its issue is on record.
+		if err := recordAudit(r.Context(), db, r, adminID, u.ID, auditImpersonate, map[string]any{
+			"reason":     req.Reason,
+			"expires_at": expiresAt.UTC(),
+		}); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		WriteJson(w, http.StatusOK, map[string]any{
+			"token":      token,
+			"expires_at": expiresAt.UTC(),
+		})
+	}
+}
+
+// AdminAuditHandler lists administrative actions, newest first. ?user_id=
+// narrows it to one target user.
+func AdminAuditHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+
+		var target *int64
+		if v := strings.TrimSpace(query.Get("user_id")); v != "" {
+			id, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				WriteJson(w, http.StatusBadRequest, map[string]string{"error": "invalid user_id"})
+				return
+			}
+			target = &id
+		}
+
+		limit := 20
+		if v := strings.TrimSpace(query.Get("limit")); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n <= 0 || n > 100 {
+				WriteJson(w, http.StatusBadRequest, map[string]string{"error": "invalid limit"})
+				return
+			}
+			limit = n
+		}
+		offset := 0
+		if v := strings.TrimSpace(query.Get("offset")); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				WriteJson(w, http.StatusBadRequest, map[string]string{"error": "invalid offset"})
+				return
+			}
+			offset = n
+		}
+
+		rows, err := db.QueryContext(r.Context(), `
+			SELECT id, admin_id, target_user_id, action, data, ip, created_at
+			FROM admin_audit
+			WHERE $1::bigint IS NULL OR target_user_id = $1
+			ORDER BY created_at DESC, id DESC
+			LIMIT $2 OFFSET $3`, target, limit, offset)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer rows.Close()
+
+		entries := make([]models.AdminAuditEntry, 0)
+		for rows.Next() {
+			var (
+				e    models.AdminAuditEntry
+				data []byte
+			)
+			if err := rows.Scan(&e.ID, &e.AdminID, &e.TargetUserID, &e.Action, &data, &e.IP, &e.CreatedAt); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			e.Data = data
+			entries = append(entries, e)
+		}
+		if err := rows.Err(); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		WriteJson(w, http.StatusOK, entries)
+	}
+}
@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"gotasker/internal/auth"
+)
+
+func TestDemotedAdminLosesAdminRoutes(t *testing.T) {
+	db := testDB(t)
+	adminID := createTestUser(t, db, "admin@example.com")
+	if _, err := db.Exec(`UPDATE users SET role = 'admin' WHERE id = $1`, adminID); err != nil {
+		t.Fatal(err)
+	}
+	tokens := loginTestUser(t, db, adminID)
+	adminOnly := func(h http.Handler) http.Handler {
+		return auth.JWTMiddleware(db, nil)(auth.RequireRole(auth.RoleAdmin)(h))
+	}
+
+	if code := serveAuthed(adminOnly, okHandler, withBearer(http.MethodGet, "/admin/users", tokens.Token)); code != http.StatusOK {
+		t.Fatalf("as admin: status %d", code)
+	}
+	if _, err := db.Exec(`UPDATE users SET role = 'user' WHERE id = $1`, adminID); err != nil {
+		t.Fatal(err)
+	}
+	// The token still says admin, but the account no longer is.
+	if code := serveAuthed(adminOnly, okHandler, withBearer(http.MethodGet, "/admin/users", tokens.Token)); code != http.StatusUnauthorized {
+		t.Fatalf("after demotion: status %d, want 401", code)
+	}
+
+	// A refreshed token carries the new role.
+	code, refreshed := refreshTokens(t, db, tokens.RefreshToken)
+	if code != http.StatusOK {
+		t.Fatalf("refresh: status %d", code)
+	}
+	if code := serveAuthed(adminOnly, okHandler, withBearer(http.MethodGet, "/admin/users", refreshed.Token)); code != http.StatusForbidden {
+		t.Errorf("refreshed token: status %d, want 403", code)
+	}
+}
+
+func TestAuditRecordsTheClientIP(t *testing.T) {
+	db := testDB(t)
+	adminID := createTestUser(t, db, "admin@example.com")
+	targetID := createTestUser(t, db, "target@example.com")
+
+	r := httptest.NewRequest(http.MethodPost, "/admin/users/1/disable", nil)
+	r.RemoteAddr = "192.0.2.7:54321"
+	if err := recordAudit(context.Background(), db, r, adminID, targetID, auditDisable, nil); err != nil {
+		t.Fatal(err)
+	}
+	var ip string
+	if err := db.QueryRow(`SELECT ip FROM admin_audit WHERE target_user_id = $1`, targetID).Scan(&ip); err != nil {
+		t.Fatal(err)
+	}
+	if ip != "192.0.2.7" {
+		t.Errorf("ip %q, want 192.0.2.7", ip)
+	}
+}
@@ -0,0 +1,180 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"gotasker/internal/auth"
+	"gotasker/internal/models"
+
+	"github.com/go-chi/chi"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const (
+	maxAPIKeyNameLen = 100
+	maxAPIKeys       = 25
+	// apiKeyPrefixLen is how much of a key is kept in the clear.
+	apiKeyPrefixLen = len(auth.APIKeyPrefix) + 8
+)
+
+const apiKeySelect = `
+	SELECT id, name, prefix, scopes, expires_at, last_used_at, created_at
+	FROM api_keys`
+
+func scanAPIKey(s rowScanner) (models.APIKey, error) {
+	var k models.APIKey
+	err := s.Scan(&k.ID, &k.Name, &k.Prefix, pgtype.NewMap().SQLScanner(&k.Scopes), &k.ExpiresAt, &k.LastUsedAt, &k.CreatedAt)
+	if k.Scopes == nil {
+		k.Scopes = []string{}
+	}
+	return k, err
+}
+
+// ListAPIKeysHandler lists the user's keys that were not revoked, expired
+// ones included.
+func ListAPIKeysHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := currentUserID(r)
+		if !ok {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		rows, err := db.QueryContext(r.Context(), apiKeySelect+`
+			WHERE user_id = $1 AND revoked_at IS NULL
+			ORDER BY created_at DESC, id DESC`, userID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer rows.Close()
+
+		keys := make([]models.APIKey, 0)
+		for rows.Next() {
+			k, err := scanAPIKey(rows)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			keys = append(keys, k)
+		}
+		if err := rows.Err(); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		WriteJson(w, http.StatusOK, keys)
+	}
+}
+
+// CreateAPIKeyHandler creates a key with the given scopes and optional
+// expiry. The key is in this response only.
+func CreateAPIKeyHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req models.APIKeyRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			WriteJson(w, http.StatusBadRequest, map[string]string{"error": "Invalid JSON"})
+			return
+		}
+		req.Name = strings.TrimSpace(req.Name)
+		if req.Name == "" || len(req.Name) > maxAPIKeyNameLen {
+			WriteJson(w, http.StatusBadRequest, map[string]string{"error": "name is required (max 100 characters)"})
+			return
+		}
+		req.Scopes = uniqueStrings(req.Scopes)
+		if len(req.Scopes) == 0 {
+			WriteJson(w, http.StatusBadRequest, map[string]string{"error": "at least one scope is required"})
+			return
+		}
+		for _, s := range req.Scopes {
+			if !auth.ValidScope(s) {
+				WriteJson(w, http.StatusBadRequest, map[string]string{"error": "unknown scope " + s})
+				return
+			}
+		}
+		if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+			WriteJson(w, http.StatusBadRequest, map[string]string{"error": "expires_at must be in the future"})
+			return
+		}
+
+		userID, ok := currentUserID(r)
+		if !ok {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if impersonating(r) {
+			WriteJson(w, http.StatusForbidden, map[string]string{"error": "not allowed while impersonating"})
+			return
+		}
+
+		ctx := r.Context()
+		var count int
+		if err := db.QueryRowContext(ctx, `
+			SELECT COUNT(*) FROM api_keys WHERE user_id = $1 AND revoked_at IS NULL`, userID).Scan(&count); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if count >= maxAPIKeys {
+			WriteJson(w, http.StatusConflict, map[string]string{"error": "too many API keys; revoke one first"})
+			return
+		}
+
+		secret, err := newOpaqueToken()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		created := models.CreatedAPIKey{Key: auth.APIKeyPrefix + secret}
+		created.APIKey, err = scanAPIKey(db.QueryRowContext(ctx, `
+			INSERT INTO api_keys (user_id, name, prefix, key_hash, scopes, expires_at)
+			VALUES ($1, $2, $3, $4, $5, $6)
+			RETURNING id, name, prefix, scopes, expires_at, last_used_at, created_at`,
+			userID, req.Name, created.Key[:apiKeyPrefixLen], auth.HashAPIKey(created.Key), req.Scopes, req.ExpiresAt))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		WriteJson(w, http.StatusCreated, created)
+	}
+}
+
+// RevokeAPIKeyHandler revokes one of the user's keys; it stops working at
+// once.
+func RevokeAPIKeyHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+		if err != nil {
+			WriteJson(w, http.StatusBadRequest, map[string]string{"error": "Invalid API key ID"})
+			return
+		}
+
+		userID, ok := currentUserID(r)
+		if !ok {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		res, err := db.ExecContext(r.Context(), `
+			UPDATE api_keys SET revoked_at = NOW()
+			WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL`, id, userID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if n, err := res.RowsAffected(); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		} else if n == 0 {
+			WriteJson(w, http.StatusNotFound, map[string]string{"error": "API key not found"})
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"gotasker/internal/auth"
+	"gotasker/internal/models"
+)
+
+// createTestAPIKey creates a key for userID with scopes and returns it.
+func createTestAPIKey(t *testing.T, db *sql.DB, userID int64, scopes ...string) models.CreatedAPIKey {
+	t.Helper()
+	body, err := json.Marshal(models.APIKeyRequest{Name: "test", Scopes: scopes})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := httptest.NewRecorder()
+	CreateAPIKeyHandler(db)(rec, asUser(httptest.NewRequest(http.MethodPost, "/api-keys", strings.NewReader(string(body))), userID))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create key: status %d: %s", rec.Code, rec.Body)
+	}
+	var key models.CreatedAPIKey
+	if err := json.Unmarshal(rec.Body.Bytes(), &key); err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func withAPIKey(method, path, key string) *http.Request {
+	r := httptest.NewRequest(method, path, nil)
+	r.Header.Set("X-API-Key", key)
+	return r
+}
+
+func TestAPIKeyScopesAndRevocation(t *testing.T) {
+	db := testDB(t)
+	userID := createTestUser(t, db, "keys@example.com")
+	taskRoutes := func(h http.Handler) http.Handler {
+		return auth.Authenticate(db, nil)(auth.RequireScopes(auth.ScopeTasksRead, auth.ScopeTasksWrite)(h))
+	}
+
+	read := createTestAPIKey(t, db, userID, auth.ScopeTasksRead)
+	if code := serveAuthed(taskRoutes, okHandler, withAPIKey(http.MethodGet, "/tasksdb", read.Key)); code != http.StatusOK {
+		t.Errorf("read key GET: status %d, want 200", code)
+	}
+	if code := serveAuthed(taskRoutes, okHandler, withAPIKey(http.MethodPost, "/tasksdb", read.Key)); code != http.StatusForbidden {
+		t.Errorf("read key POST: status %d, want 403", code)
+	}
+	// Keys are for task routes only.
+	if code := serveAuthed(auth.JWTMiddleware(db, nil), okHandler, withAPIKey(http.MethodGet, "/sessions", read.Key)); code != http.StatusUnauthorized {
+		t.Errorf("key on an account route: status %d, want 401", code)
+	}
+	// User tokens have every scope.
+	tokens := loginTestUser(t, db, userID)
+	for _, method := range []string{http.MethodGet, http.MethodPost} {
+		if code := serveAuthed(taskRoutes, okHandler, withBearer(method, "/tasksdb", tokens.Token)); code != http.StatusOK {
+			t.Errorf("token %s: status %d, want 200", method, code)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	RevokeAPIKeyHandler(db)(rec, withTaskID(asUser(httptest.NewRequest(http.MethodDelete, "/api-keys/x", nil), userID), read.ID))
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("revoke: status %d: %s", rec.Code, rec.Body)
+	}
+	if code := serveAuthed(taskRoutes, okHandler, withAPIKey(http.MethodGet, "/tasksdb", read.Key)); code != http.StatusUnauthorized {
+		t.Errorf("revoked key: status %d, want 401", code)
+	}
+
+	expired := createTestAPIKey(t, db, userID, auth.ScopeTasksRead, auth.ScopeTasksWrite)
+	if _, err := db.Exec(`UPDATE api_keys SET expires_at = NOW() - INTERVAL '1 second' WHERE id = $1`, expired.ID); err != nil {
+		t.Fatal(err)
+	}
+	if code := serveAuthed(taskRoutes, okHandler, withAPIKey(http.MethodGet, "/tasksdb", expired.Key)); code != http.StatusUnauthorized {
+		t.Errorf("expired key: status %d, want 401", code)
+	}
+	if code := serveAuthed(taskRoutes, okHandler, withAPIKey(http.MethodGet, "/tasksdb", auth.APIKeyPrefix+"unknown")); code != http.StatusUnauthorized {
+		t.Errorf("unknown key: status %d, want 401", code)
+	}
+}
@@ -0,0 +1,169 @@
+package handlers
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"gotasker/internal/models"
+	"gotasker/internal/workspace"
+
+	"github.com/go-chi/chi"
+	"github.com/redis/go-redis/v9"
+)
+
+// GetArchiveHandler lists the archived tasks of the request's workspace, most
+// recently archived first. ?q= searches titles and tags; limit/offset
+// paginate as on /tasksdb.
+func GetArchiveHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		q := strings.TrimSpace(query.Get("q"))
+
+		limit := 20
+		if v := strings.TrimSpace(query.Get("limit")); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n <= 0 || n > 100 {
+				WriteJson(w, http.StatusBadRequest, map[string]string{"error": "invalid limit"})
+				return
+			}
+			limit = n
+		}
+		offset := 0
+		if v := strings.TrimSpace(query.Get("offset")); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				WriteJson(w, http.StatusBadRequest, map[string]string{"error": "invalid offset"})
+				return
+			}
+			offset = n
+		}
+
+		userID, ok := currentUserID(r)
+		if !ok {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		workspaceID, _, err := requestWorkspace(r, db, userID)
+		if err != nil {
+			writeWorkspaceError(w, err)
+			return
+		}
+
+		rows, err := db.QueryContext(r.Context(), `
+			SELECT `+taskColumns+`
+			FROM tasks
+			WHERE workspace_id = $1 AND archived_at IS NOT NULL
+				AND ($2 = '' OR LOWER(title) LIKE '%' || LOWER($2) || '%'
+					OR EXISTS (SELECT 1 FROM unnest(tags) AS tag WHERE LOWER(tag) = LOWER($2)))
+			ORDER BY archived_at DESC, id DESC
+			LIMIT $3 OFFSET $4`, workspaceID, q, limit, offset)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer rows.Close()
+
+		tasks := make([]models.Task, 0)
+		for rows.Next() {
+			t, err := scanTask(rows)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			tasks = append(tasks, t)
+		}
+
+		WriteJson(w, http.StatusOK, tasks)
+	}
+}
+
+// ArchiveTaskHandlerDB archives a task and all its subtasks right away.
+func ArchiveTaskHandlerDB(db *sql.DB, rdb *redis.Client) http.HandlerFunc {
+	return setArchived(db, rdb, true)
+}
+
+// UnarchiveTaskHandlerDB restores a task, all its subtasks and, for a
+// subtask, its parents, so the task shows up in the default list again.
+func UnarchiveTaskHandlerDB(db *sql.DB, rdb *redis.Client) http.HandlerFunc {
+	return setArchived(db, rdb, false)
+}
+
+// subtreeCTE selects task $1 and every task below it. UNION rather than
+// UNION ALL stops at a parent_id cycle.
+const subtreeCTE = `
+	WITH RECURSIVE subtree AS (
+		SELECT id FROM tasks WHERE id = $1
+		UNION
+		SELECT t.id FROM tasks t JOIN subtree s ON t.parent_id = s.id
+	)`
+
+func setArchived(db *sql.DB, rdb *redis.Client, archived bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			WriteJson(w, http.StatusBadRequest, map[string]string{"error": "Invalid task ID"})
+			return
+		}
+
+		userID, ok := currentUserID(r)
+		if !ok {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		ctx := r.Context()
+		_, role, err := taskAccess(ctx, db, userID, id)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if !workspace.CanRead(role) {
+			WriteJson(w, http.StatusNotFound, map[string]string{"error": "Task not found"})
+			return
+		}
+		if !workspace.CanWrite(role) {
+			WriteJson(w, http.StatusForbidden, map[string]string{"error": "insufficient role"})
+			return
+		}
+
+		var res sql.Result
+		if archived {
+			res, err = db.ExecContext(ctx, subtreeCTE+`
+				UPDATE tasks SET archived_at = COALESCE(archived_at, NOW())
+				WHERE id IN (SELECT id FROM subtree)`, id)
+		} else {
+			res, err = db.ExecContext(ctx, subtreeCTE+`,
+				ancestors AS (
+					SELECT parent_id AS id FROM tasks
+					WHERE id = $1 AND parent_id IS NOT NULL
+					UNION
+					SELECT t.parent_id FROM tasks t JOIN ancestors a ON a.id = t.id
+					WHERE t.parent_id IS NOT NULL
+				)
+				UPDATE tasks SET archived_at = NULL, updated_at = NOW()
+				WHERE id IN (SELECT id FROM subtree UNION SELECT id FROM ancestors)`, id)
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			WriteJson(w, http.StatusNotFound, map[string]string{"error": "Task not found"})
+			return
+		}
+
+		invalidateTaskViewers(ctx, db, rdb, id)
+
+		t, err := scanTask(db.QueryRowContext(ctx, `
+			SELECT `+taskColumns+` FROM tasks WHERE id = $1`, id))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		WriteJson(w, http.StatusOK, t)
+	}
+}
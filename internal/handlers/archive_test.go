@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"gotasker/internal/models"
+
+	"github.com/go-chi/chi"
+)
+
+// withTaskID returns r with the {id} route parameter chi would have set.
+func withTaskID(r *http.Request, id int64) *http.Request {
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("id", strconv.FormatInt(id, 10))
+	return r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, rctx))
+}
+
+// insertTestTask inserts a task for userID in their personal workspace.
+func insertTestTask(t *testing.T, db *sql.DB, userID int64, title string, parentID *int64, tags []string) int64 {
+	t.Helper()
+	var id int64
+	if err := db.QueryRowContext(context.Background(), `
+		INSERT INTO tasks (user_id, workspace_id, title, parent_id, tags)
+		SELECT $1, id, $2, $3, $4 FROM workspaces WHERE personal AND created_by = $1
+		RETURNING id`, userID, title, parentID, tags).Scan(&id); err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
+
+func archivedIDs(t *testing.T, db *sql.DB) map[int64]bool {
+	t.Helper()
+	rows, err := db.Query(`SELECT id FROM tasks WHERE archived_at IS NOT NULL`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	ids := map[int64]bool{}
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			t.Fatal(err)
+		}
+		ids[id] = true
+	}
+	return ids
+}
+
+func TestArchiveSubtree(t *testing.T) {
+	db := testDB(t)
+	userID := createTestUser(t, db, "archiver@example.com")
+
+	root := insertTestTask(t, db, userID, "Move house", nil, []string{})
+	child := insertTestTask(t, db, userID, "Pack", &root, []string{})
+	grandchild := insertTestTask(t, db, userID, "Kitchen", &child, []string{})
+	other := insertTestTask(t, db, userID, "Unrelated", nil, []string{})
+
+	req := httptest.NewRequest(http.MethodPost, "/tasksdb/archive", nil)
+	rec := httptest.NewRecorder()
+	ArchiveTaskHandlerDB(db, nil)(rec, withTaskID(asUser(req, userID), root))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("archive: status %d: %s", rec.Code, rec.Body)
+	}
+	got := archivedIDs(t, db)
+	if !got[root] || !got[child] || !got[grandchild] || got[other] {
+		t.Fatalf("archived %v, want %d, %d and %d", got, root, child, grandchild)
+	}
+
+	// Restoring the grandchild brings back its whole ancestor chain.
+	req = httptest.NewRequest(http.MethodPost, "/tasksdb/unarchive", nil)
+	rec = httptest.NewRecorder()
+	UnarchiveTaskHandlerDB(db, nil)(rec, withTaskID(asUser(req, userID), grandchild))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("unarchive: status %d: %s", rec.Code, rec.Body)
+	}
+	if got := archivedIDs(t, db); len(got) != 0 {
+		t.Fatalf("still archived: %v", got)
+	}
+}
+
+func TestGetArchiveMatchesTagsCaseInsensitively(t *testing.T) {
+	db := testDB(t)
+	userID := createTestUser(t, db, "tags@example.com")
+
+	id := insertTestTask(t, db, userID, "Quarterly report", nil, []string{"ClientA"})
+	insertTestTask(t, db, userID, "Other", nil, []string{"clientb"})
+	if _, err := db.Exec(`UPDATE tasks SET archived_at = NOW()`); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, q := range []string{"clienta", "CLIENTA", "ClientA"} {
+		req := httptest.NewRequest(http.MethodGet, "/archive?q="+q, nil)
+		rec := httptest.NewRecorder()
+		GetArchiveHandler(db)(rec, asUser(req, userID))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("q=%s: status %d: %s", q, rec.Code, rec.Body)
+		}
+		var tasks []models.Task
+		if err := json.Unmarshal(rec.Body.Bytes(), &tasks); err != nil {
+			t.Fatal(err)
+		}
+		if len(tasks) != 1 || int64(tasks[0].ID) != id {
+			t.Errorf("q=%s: got %+v, want only task %d", q, tasks, id)
+		}
+	}
+}
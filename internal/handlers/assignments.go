@@ -0,0 +1,232 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"gotasker/internal/models"
+	"gotasker/internal/notifications"
+	"gotasker/internal/workspace"
+
+	"github.com/go-chi/chi"
+	"github.com/redis/go-redis/v9"
+)
+
+// AssignTaskHandlerDB sets or clears a task's assignee. The assignee must be
+// able to see the task; the change is recorded in the task's history and
+// the new assignee is notified.
+func AssignTaskHandlerDB(db *sql.DB, rdb *redis.Client) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			WriteJson(w, http.StatusBadRequest, map[string]string{"error": "Invalid task ID"})
+			return
+		}
+
+		var req models.AssignTaskRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			WriteJson(w, http.StatusBadRequest, map[string]string{"error": "Invalid Json"})
+			return
+		}
+
+		userID, ok := currentUserID(r)
+		if !ok {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		ctx := r.Context()
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer tx.Rollback()
+
+		workspaceID, role, err := taskAccess(ctx, tx, userID, id)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if !workspace.CanRead(role) {
+			WriteJson(w, http.StatusNotFound, map[string]string{"error": "Task not found"})
+			return
+		}
+		if !workspace.CanWrite(role) {
+			WriteJson(w, http.StatusForbidden, map[string]string{"error": "insufficient role"})
+			return
+		}
+
+		if req.AssigneeID != nil {
+			_, assigneeRole, err := taskAccess(ctx, tx, *req.AssigneeID, id)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			if !workspace.CanRead(assigneeRole) {
+				WriteJson(w, http.StatusBadRequest, map[string]string{"error": "assignee cannot see this task"})
+				return
+			}
+		}
+
+		var (
+			previous  *int64
+			creatorID int64
+		)
+		err = tx.QueryRowContext(ctx, `
+			SELECT assignee_id, user_id FROM tasks WHERE id = $1 FOR UPDATE`, id).Scan(&previous, &creatorID)
+		if errors.Is(err, sql.ErrNoRows) {
+			WriteJson(w, http.StatusNotFound, map[string]string{"error": "Task not found"})
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		t, err := scanTask(tx.QueryRowContext(ctx, `
+			UPDATE tasks SET assignee_id = $1, updated_at = NOW()
+			WHERE id = $2
+			RETURNING `+taskColumns, req.AssigneeID, id))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		changed := (previous == nil) != (req.AssigneeID == nil) ||
+			(previous != nil && *previous != *req.AssigneeID)
+		if changed {
+			if err := recordTaskEvent(ctx, tx, id, userID, eventAssigned, map[string]*int64{
+				"from": previous,
+				"to":   req.AssigneeID,
+			}); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+
+			if req.AssigneeID != nil && *req.AssigneeID != userID {
+				var actorEmail string
+				if err := tx.QueryRowContext(ctx, `SELECT email FROM users WHERE id = $1`, userID).Scan(&actorEmail); err != nil {
+					http.Error(w, err.Error(), http.StatusInternalServerError)
+					return
+				}
+				if err := notifications.Notify(ctx, tx, models.Notification{
+					UserID:  *req.AssigneeID,
+					Type:    notifications.TypeAssignment,
+					TaskID:  &t.ID,
+					ActorID: &userID,
+					Title:   actorEmail + " assigned " + strconv.Quote(t.Title) + " to you",
+				}); err != nil {
+					http.Error(w, err.Error(), http.StatusInternalServerError)
+					return
+				}
+			}
+		}
+
+		if err := tx.Commit(); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		invalidateWorkspace(ctx, db, rdb, workspaceID, creatorID)
+
+		WriteJson(w, http.StatusOK, t)
+	}
+}
+
+// GetAssignedTasksHandlerDB lists the open tasks assigned to the user across
+// every workspace and share they can see. ?done= filters as on /tasksdb;
+// limit/offset paginate.
+func GetAssignedTasksHandlerDB(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+
+		var done *bool
+		if v := strings.TrimSpace(query.Get("done")); v != "" {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				WriteJson(w, http.StatusBadRequest, map[string]string{"error": "invalid done param"})
+				return
+			}
+			done = &b
+		}
+
+		limit := 20
+		if v := strings.TrimSpace(query.Get("limit")); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n <= 0 || n > 100 {
+				WriteJson(w, http.StatusBadRequest, map[string]string{"error": "invalid limit"})
+				return
+			}
+			limit = n
+		}
+		offset := 0
+		if v := strings.TrimSpace(query.Get("offset")); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				WriteJson(w, http.StatusBadRequest, map[string]string{"error": "invalid offset"})
+				return
+			}
+			offset = n
+		}
+
+		userID, ok := currentUserID(r)
+		if !ok {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		ctx := r.Context()
+		personalID, err := ensurePersonalWorkspace(ctx, db, userID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		// Assignments outlive membership, so visibility is checked again.
+		rows, err := db.QueryContext(ctx, `
+			SELECT `+taskColumns+`, user_id,
+				(SELECT email FROM users WHERE id = t.user_id)
+			FROM tasks t
+			WHERE assignee_id = $1 AND archived_at IS NULL
+				AND ($2::boolean IS NULL OR done = $2)
+				AND (
+					EXISTS (SELECT 1 FROM workspace_members m WHERE m.workspace_id = t.workspace_id AND m.user_id = $1)
+					OR EXISTS (SELECT 1 FROM share_grants g WHERE g.grantee_id = $1 AND `+grantCoversTask+`)
+				)
+			ORDER BY due_at NULLS LAST, created_at DESC
+			LIMIT $3 OFFSET $4`, userID, done, limit, offset)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer rows.Close()
+
+		tasks := make([]models.Task, 0)
+		for rows.Next() {
+			var (
+				ownerID    int64
+				ownerEmail string
+			)
+			t, err := scanTask(scanWithExtra(rows, &ownerID, &ownerEmail))
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			if t.WorkspaceID != personalID {
+				t.Owner = &models.UserRef{ID: ownerID, Email: ownerEmail}
+			}
+			tasks = append(tasks, t)
+		}
+		if err := rows.Err(); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		WriteJson(w, http.StatusOK, tasks)
+	}
+}
@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"testing"
+
+	"gotasker/internal/models"
+)
+
+func TestAssignTaskAccess(t *testing.T) {
+	db := testDB(t)
+	owner := createTestUser(t, db, "owner@example.com")
+	member := createTestUser(t, db, "member@example.com")
+	viewer := createTestUser(t, db, "viewer@example.com")
+	outsider := createTestUser(t, db, "outsider@example.com")
+	wsID := sharedWorkspace(t, db, owner, map[int64]string{member: "member", viewer: "viewer"})
+
+	var taskID int64
+	if err := db.QueryRow(`
+		INSERT INTO tasks (user_id, workspace_id, title) VALUES ($1, $2, 'Shared')
+		RETURNING id`, owner, wsID).Scan(&taskID); err != nil {
+		t.Fatal(err)
+	}
+
+	assign := func(userID int64, assignee string) int {
+		t.Helper()
+		rec := serve(AssignTaskHandlerDB(db, nil), withTaskID(asUser(newJSONRequest(http.MethodPut, "/",
+			`{"assignee_id":`+assignee+`}`), userID), taskID))
+		return rec.Code
+	}
+	history := func(userID int64) (int, []models.TaskEvent) {
+		t.Helper()
+		rec := serve(GetTaskHistoryHandler(db), withTaskID(asUser(newJSONRequest(http.MethodGet, "/", ""), userID), taskID))
+		var events []models.TaskEvent
+		if rec.Code == http.StatusOK {
+			if err := json.Unmarshal(rec.Body.Bytes(), &events); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return rec.Code, events
+	}
+	id := func(userID int64) string { return strconv.FormatInt(userID, 10) }
+
+	if code := assign(member, id(outsider)); code != http.StatusBadRequest {
+		t.Errorf("assign to someone who cannot see the task: status %d, want 400", code)
+	}
+	if code := assign(viewer, id(viewer)); code != http.StatusForbidden {
+		t.Errorf("assign as viewer: status %d, want 403", code)
+	}
+	if code := assign(outsider, id(member)); code != http.StatusNotFound {
+		t.Errorf("assign as outsider: status %d, want 404", code)
+	}
+	if code, events := history(member); code != http.StatusOK || len(events) != 0 {
+		t.Errorf("after refused assignments: status %d, %d history events", code, len(events))
+	}
+
+	if code := assign(member, id(viewer)); code != http.StatusOK {
+		t.Fatalf("assign to viewer: status %d", code)
+	}
+	// Assigning the same person again is not a change.
+	if code := assign(member, id(viewer)); code != http.StatusOK {
+		t.Fatalf("reassign: status %d", code)
+	}
+	if code := assign(owner, "null"); code != http.StatusOK {
+		t.Fatalf("unassign: status %d", code)
+	}
+
+	_, events := history(viewer)
+	if len(events) != 2 || events[0].Type != eventAssigned || *events[1].ActorID != member {
+		t.Errorf("history %+v, want the assignment and the unassignment", events)
+	}
+	if code, _ := history(outsider); code != http.StatusNotFound {
+		t.Errorf("history as outsider: status %d, want 404", code)
+	}
+
+	var notified int
+	if err := db.QueryRow(`
+		SELECT COUNT(*) FROM notifications WHERE user_id = $1 AND type = 'assignment'`, viewer).Scan(&notified); err != nil {
+		t.Fatal(err)
+	}
+	if notified != 1 {
+		t.Errorf("viewer got %d assignment notifications, want 1", notified)
+	}
+}
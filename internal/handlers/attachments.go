@@ -0,0 +1,462 @@
+package handlers
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"gotasker/internal/auth"
+	"gotasker/internal/blob"
+	"gotasker/internal/models"
+
+	"github.com/go-chi/chi"
+)
+
+const (
+	maxAttachmentBytes = 10 << 20
+	// attachmentURLTTL is how long a download link stays valid.
+	attachmentURLTTL = 15 * time.Minute
+)
+
+// allowedAttachmentTypes lists the sniffed content types accepted for
+// upload. SVG and HTML are left out because browsers execute scripts in them.
+var allowedAttachmentTypes = map[string]bool{
+	"image/png":       true,
+	"image/jpeg":      true,
+	"image/gif":       true,
+	"image/webp":      true,
+	"application/pdf": true,
+}
+
+// attachmentSigningKey signs download links served by the API itself.
+var attachmentSigningKey = func() []byte {
+	if k := os.Getenv("ATTACHMENT_SIGNING_KEY"); k != "" {
+		return []byte(k)
+	}
+	return auth.JwtSecret
+}()
+
+const attachmentColumns = `id, task_id, filename, content_type, size_bytes, sha256, created_at`
+
+func scanAttachment(s rowScanner) (models.Attachment, error) {
+	var a models.Attachment
+	err := s.Scan(&a.ID, &a.TaskID, &a.Filename, &a.ContentType, &a.SizeBytes, &a.SHA256, &a.CreatedAt)
+	return a, err
+}
+
+func attachmentSignature(id int64, expires int64) string {
+	m := hmac.New(sha256.New, attachmentSigningKey)
+	fmt.Fprintf(m, "attachment:%d:%d", id, expires)
+	return hex.EncodeToString(m.Sum(nil))
+}
+
+// cleanAttachmentName keeps the base name of an uploaded file.
+func cleanAttachmentName(name string) string {
+	name = strings.TrimSpace(filepath.Base(strings.ReplaceAll(name, `\`, "/")))
+	if name == "" || name == "." || name == "/" {
+		return "attachment"
+	}
+	if len(name) > 255 {
+		name = name[:255]
+	}
+	return name
+}
+
+// sniffAndHash detects the content type of f and returns it with the hex
+// SHA-256 of the whole file, leaving f rewound.
+func sniffAndHash(f multipart.File) (string, string, error) {
+	head := make([]byte, 512)
+	n, err := io.ReadFull(f, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return "", "", err
+	}
+	contentType, _, _ := strings.Cut(http.DetectContentType(head[:n]), ";")
+
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return "", "", err
+	}
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", "", err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return "", "", err
+	}
+	return strings.TrimSpace(contentType), hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// UploadAttachmentHandler stores the multipart "file" field as an attachment
+// of the task. The type is sniffed from the content rather than trusted from
+// the client.
+func UploadAttachmentHandler(db *sql.DB, store blob.BlobStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		taskID, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			WriteJson(w, http.StatusBadRequest, map[string]string{"error": "Invalid task ID"})
+			return
+		}
+
+		userID, ok := currentUserID(r)
+		if !ok {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		ctx := r.Context()
+		owned, err := canEditTask(ctx, db, userID, taskID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if !owned {
+			WriteJson(w, http.StatusNotFound, map[string]string{"error": "Task not found"})
+			return
+		}
+
+		// Leave room for the multipart framing around the file.
+		r.Body = http.MaxBytesReader(w, r.Body, maxAttachmentBytes+1<<20)
+		if err := r.ParseMultipartForm(8 << 20); err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				WriteJson(w, http.StatusRequestEntityTooLarge, map[string]string{"error": "file is too large"})
+				return
+			}
+			WriteJson(w, http.StatusBadRequest, map[string]string{"error": "expected multipart form with a file field"})
+			return
+		}
+		defer r.MultipartForm.RemoveAll()
+
+		file, header, err := r.FormFile("file")
+		if err != nil {
+			WriteJson(w, http.StatusBadRequest, map[string]string{"error": "file field is required"})
+			return
+		}
+		defer file.Close()
+
+		if header.Size > maxAttachmentBytes {
+			WriteJson(w, http.StatusRequestEntityTooLarge, map[string]string{"error": "file is too large"})
+			return
+		}
+		if header.Size == 0 {
+			WriteJson(w, http.StatusBadRequest, map[string]string{"error": "file is empty"})
+			return
+		}
+
+		contentType, sum, err := sniffAndHash(file)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if !allowedAttachmentTypes[contentType] {
+			WriteJson(w, http.StatusUnsupportedMediaType, map[string]string{"error": "unsupported file type " + contentType})
+			return
+		}
+
+		random := make([]byte, 16)
+		if _, err := rand.Read(random); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		key := fmt.Sprintf("tasks/%d/%s", taskID, hex.EncodeToString(random))
+
+		if err := store.Put(ctx, key, file, header.Size, contentType, sum); err != nil {
+			log.Printf("blob put failed: %v", err)
+			WriteJson(w, http.StatusBadGateway, map[string]string{"error": "failed to store file"})
+			return
+		}
+
+		a, err := scanAttachment(db.QueryRowContext(ctx, `
+			INSERT INTO attachments (user_id, task_id, filename, content_type, size_bytes, sha256, storage_key)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)
+			RETURNING `+attachmentColumns,
+			userID, taskID, cleanAttachmentName(header.Filename), contentType, header.Size, sum, key))
+		if err != nil {
+			deleteBlobs(ctx, store, []string{key})
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		WriteJson(w, http.StatusCreated, a)
+	}
+}
+
+func ListAttachmentsHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		taskID, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			WriteJson(w, http.StatusBadRequest, map[string]string{"error": "Invalid task ID"})
+			return
+		}
+
+		userID, ok := currentUserID(r)
+		if !ok {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		ctx := r.Context()
+		owned, err := canViewTask(ctx, db, userID, taskID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if !owned {
+			WriteJson(w, http.StatusNotFound, map[string]string{"error": "Task not found"})
+			return
+		}
+
+		rows, err := db.QueryContext(ctx, `
+			SELECT `+attachmentColumns+`
+			FROM attachments
+			WHERE task_id = $1
+			ORDER BY created_at, id`, taskID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer rows.Close()
+
+		attachments := make([]models.Attachment, 0)
+		for rows.Next() {
+			a, err := scanAttachment(rows)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			attachments = append(attachments, a)
+		}
+
+		WriteJson(w, http.StatusOK, attachments)
+	}
+}
+
+// lookupAttachment returns an attachment of a task the user can see
+// together with its storage key.
+func lookupAttachment(ctx context.Context, q dbtx, userID int64, taskID int, id int64) (models.Attachment, string, error) {
+	ok, err := canViewTask(ctx, q, userID, taskID)
+	if err != nil {
+		return models.Attachment{}, "", err
+	}
+	if !ok {
+		return models.Attachment{}, "", sql.ErrNoRows
+	}
+
+	var key string
+	a, err := scanAttachment(scanWithExtra(q.QueryRowContext(ctx, `
+		SELECT `+attachmentColumns+`, storage_key
+		FROM attachments
+		WHERE id = $1 AND task_id = $2`, id, taskID), &key))
+	return a, key, err
+}
+
+// AttachmentURLHandler returns a download link valid for attachmentURLTTL.
+// Stores that can presign (S3) link straight to the object; otherwise the
+// link points at DownloadAttachmentHandler with an HMAC signature.
+func AttachmentURLHandler(db *sql.DB, store blob.BlobStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		taskID, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			WriteJson(w, http.StatusBadRequest, map[string]string{"error": "Invalid task ID"})
+			return
+		}
+		id, err := strconv.ParseInt(chi.URLParam(r, "attachmentID"), 10, 64)
+		if err != nil {
+			WriteJson(w, http.StatusBadRequest, map[string]string{"error": "Invalid attachment ID"})
+			return
+		}
+
+		userID, ok := currentUserID(r)
+		if !ok {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		a, key, err := lookupAttachment(r.Context(), db, userID, taskID, id)
+		if errors.Is(err, sql.ErrNoRows) {
+			WriteJson(w, http.StatusNotFound, map[string]string{"error": "Attachment not found"})
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		expiresAt := time.Now().Add(attachmentURLTTL).Truncate(time.Second)
+		var link string
+		if p, ok := store.(blob.Presigner); ok {
+			link, err = p.PresignGet(key, a.Filename, attachmentURLTTL)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		} else {
+			expires := expiresAt.Unix()
+			link = fmt.Sprintf("%s/attachments/%d/download?expires=%d&sig=%s",
+				strings.TrimRight(os.Getenv("PUBLIC_BASE_URL"), "/"), a.ID, expires, attachmentSignature(a.ID, expires))
+		}
+
+		WriteJson(w, http.StatusOK, models.AttachmentURL{URL: link, ExpiresAt: expiresAt})
+	}
+}
+
+// DownloadAttachmentHandler serves attachment content for links produced by
+// AttachmentURLHandler. It is a public route; the signature is the
+// authorisation.
+func DownloadAttachmentHandler(db *sql.DB, store blob.BlobStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+		if err != nil {
+			WriteJson(w, http.StatusBadRequest, map[string]string{"error": "Invalid attachment ID"})
+			return
+		}
+		expires, err := strconv.ParseInt(r.URL.Query().Get("expires"), 10, 64)
+		sig := r.URL.Query().Get("sig")
+		if err != nil || !hmac.Equal([]byte(sig), []byte(attachmentSignature(id, expires))) {
+			WriteJson(w, http.StatusForbidden, map[string]string{"error": "invalid signature"})
+			return
+		}
+		if time.Now().Unix() > expires {
+			WriteJson(w, http.StatusForbidden, map[string]string{"error": "link expired"})
+			return
+		}
+
+		ctx := r.Context()
+		var key string
+		a, err := scanAttachment(scanWithExtra(db.QueryRowContext(ctx, `
+			SELECT `+attachmentColumns+`, storage_key
+			FROM attachments WHERE id = $1`, id), &key))
+		if errors.Is(err, sql.ErrNoRows) {
+			WriteJson(w, http.StatusNotFound, map[string]string{"error": "Attachment not found"})
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		body, err := store.Open(ctx, key)
+		if errors.Is(err, blob.ErrNotFound) {
+			WriteJson(w, http.StatusNotFound, map[string]string{"error": "Attachment not found"})
+			return
+		}
+		if err != nil {
+			log.Printf("blob open failed: %v", err)
+			WriteJson(w, http.StatusBadGateway, map[string]string{"error": "failed to read file"})
+			return
+		}
+		defer body.Close()
+
+		w.Header().Set("Content-Type", a.ContentType)
+		w.Header().Set("Content-Length", strconv.FormatInt(a.SizeBytes, 10))
+		w.Header().Set("Content-Disposition", blob.ContentDisposition(a.Filename))
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		w.Header().Set("Cache-Control", "private, max-age=0")
+		w.WriteHeader(http.StatusOK)
+		if _, err := io.Copy(w, body); err != nil {
+			log.Printf("attachment download interrupted: %v", err)
+		}
+	}
+}
+
+func DeleteAttachmentHandler(db *sql.DB, store blob.BlobStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		taskID, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			WriteJson(w, http.StatusBadRequest, map[string]string{"error": "Invalid task ID"})
+			return
+		}
+		id, err := strconv.ParseInt(chi.URLParam(r, "attachmentID"), 10, 64)
+		if err != nil {
+			WriteJson(w, http.StatusBadRequest, map[string]string{"error": "Invalid attachment ID"})
+			return
+		}
+
+		userID, ok := currentUserID(r)
+		if !ok {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		ctx := r.Context()
+		canEdit, err := canEditTask(ctx, db, userID, taskID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if !canEdit {
+			WriteJson(w, http.StatusNotFound, map[string]string{"error": "Attachment not found"})
+			return
+		}
+
+		var key string
+		err = db.QueryRowContext(ctx, `
+			DELETE FROM attachments
+			WHERE id = $1 AND task_id = $2
+			RETURNING storage_key`, id, taskID).Scan(&key)
+		if errors.Is(err, sql.ErrNoRows) {
+			WriteJson(w, http.StatusNotFound, map[string]string{"error": "Attachment not found"})
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		deleteBlobs(ctx, store, []string{key})
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// taskAttachmentKeys returns the storage keys of attachments on the task and
+// all of its subtasks, which go away with it.
+func taskAttachmentKeys(ctx context.Context, q dbtx, taskID int) ([]string, error) {
+	rows, err := q.QueryContext(ctx, `
+		WITH RECURSIVE tree AS (
+			SELECT id FROM tasks WHERE id = $1
+			UNION ALL
+			SELECT t.id FROM tasks t JOIN tree ON t.parent_id = tree.id
+		)
+		SELECT a.storage_key
+		FROM attachments a
+		JOIN tree ON tree.id = a.task_id`, taskID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []string
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+// deleteBlobs removes blobs whose rows are already gone. Failures only leave
+// orphaned objects behind, so they are logged rather than returned.
+func deleteBlobs(ctx context.Context, store blob.BlobStore, keys []string) {
+	ctx = context.WithoutCancel(ctx)
+	for _, key := range keys {
+		if err := store.Delete(ctx, key); err != nil {
+			log.Printf("blob delete %s failed: %v", key, err)
+		}
+	}
+}
@@ -3,14 +3,14 @@ package handlers
 import (
 	"database/sql"
 	"encoding/json"
+	"log"
 	"net/http"
+	netmail "net/mail"
 	"strings"
-	"time"
 
 	"gotasker/internal/auth"
+	"gotasker/internal/mail"
 	"gotasker/internal/models"
-
-	"github.com/golang-jwt/jwt/v5"
 )
 
 //step 1 - Convert the incoming JSON into GO - Done
@@ -20,7 +20,16 @@ import (
 //step 5 - check dulipcate email and failed to create user - Done
 //step 6 - writeJson to client - Done
 
-func RegisterHandler(db *sql.DB) http.HandlerFunc {
+// validEmail reports whether email is a bare address such as
+// "name@example.com".
+func validEmail(email string) bool {
+	addr, err := netmail.ParseAddress(email)
+	return err == nil && addr.Address == email && strings.Contains(email[strings.LastIndex(email, "@")+1:], ".")
+}
+
+// RegisterHandler creates an account and mails a verification link to its
+// address; accepting an invite sent to that address verifies it at once.
+func RegisterHandler(db *sql.DB, mailer mail.Mailer) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		var req models.RegisterRequest
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -38,6 +47,13 @@ func RegisterHandler(db *sql.DB) http.HandlerFunc {
 			return
 		}
 
+		if !validEmail(req.Email) {
+			WriteJson(w, http.StatusBadRequest, map[string]string{
+				"error": "invalid email address",
+			})
+			return
+		}
+
 		if len(req.Password) < 8 {
 			WriteJson(w, http.StatusBadRequest, map[string]string{
 				"error": "The must contain more than 8 characters",
@@ -53,8 +69,15 @@ func RegisterHandler(db *sql.DB) http.HandlerFunc {
 			return
 		}
 
+		tx, err := db.BeginTx(r.Context(), nil)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer tx.Rollback()
+
 		var user models.UserResponse
-		err = db.QueryRow(`
+		err = tx.QueryRow(`
 			INSERT INTO users (email, password_hash)
 			VALUES ($1, $2)
 			RETURNING id, email
@@ -73,6 +96,37 @@ func RegisterHandler(db *sql.DB) http.HandlerFunc {
 			return
 		}
 
+		// Every user starts out with a personal workspace.
+		if _, err := ensurePersonalWorkspace(r.Context(), tx, user.ID); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if req.InviteToken != "" {
+			if _, err := acceptInvite(r.Context(), tx, req.InviteToken, user.ID, user.Email); err != nil {
+				writeInviteError(w, err)
+				return
+			}
+			// The invite was mailed to this address, which proves it.
+			if _, err := tx.ExecContext(r.Context(), `
+				UPDATE users SET email_verified_at = NOW() WHERE id = $1`, user.ID); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			user.EmailVerified = true
+		}
+		if err := tx.Commit(); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		// The account exists either way; a failed send can be retried
+		// with /verify-email/resend.
+		if !user.EmailVerified {
+			if err := sendEmailVerification(r.Context(), db, mailer, user.ID, user.Email); err != nil {
+				log.Printf("verification email for user %d: %v", user.ID, err)
+			}
+		}
+
 		WriteJson(w, http.StatusCreated, user)
 	}
 }
@@ -98,10 +152,12 @@ func LoginHandler(db *sql.DB) http.HandlerFunc {
 
 		var user models.LoginAuth
 		err := db.QueryRow(`
-			SELECT id, email, password_hash
+			SELECT id, email, password_hash, role, disabled_at IS NOT NULL, password_reset_required_at IS NOT NULL,
+				email_verified_at IS NOT NULL, totp_enabled_at IS NOT NULL
 			FROM users
 			WHERE email = $1
-		`, req.Email).Scan(&user.ID, &user.Email, &user.PasswordHash)
+		`, req.Email).Scan(&user.ID, &user.Email, &user.PasswordHash, &user.Role, &user.Disabled, &user.ResetRequired,
+			&user.EmailVerified, &user.MFAEnabled)
 
 		if err != nil {
 			WriteJson(w, http.StatusUnauthorized, map[string]string{
@@ -118,29 +174,52 @@ func LoginHandler(db *sql.DB) http.HandlerFunc {
 			return
 		}
 
-		//w.WriteHeader(http.StatusCreated)
-
-		//============== JWT CREATION ==============
-		claims := auth.JWTClaims{
-			UserID: user.ID,
-			RegisteredClaims: jwt.RegisteredClaims{
-				ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour)),
-				IssuedAt:  jwt.NewNumericDate(time.Now()),
-			},
-		}
+		finishLogin(w, r, db, user)
+	}
+}
 
-		token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+// finishLogin answers a login whose credentials checked out: it refuses
+// disabled accounts, forced resets and (under VerificationBlock)
+// unverified emails, asks for the second factor if 2FA is on, and
+// otherwise starts a session.
+func finishLogin(w http.ResponseWriter, r *http.Request, db *sql.DB, user models.LoginAuth) {
+	if user.Disabled {
+		WriteJson(w, http.StatusForbidden, map[string]string{
+			"error": "account disabled",
+		})
+		return
+	}
+	if user.ResetRequired {
+		WriteJson(w, http.StatusForbidden, map[string]string{
+			"error": "password reset required",
+		})
+		return
+	}
+	if !user.EmailVerified && auth.EmailVerificationPolicy == auth.VerificationBlock {
+		WriteJson(w, http.StatusForbidden, map[string]string{
+			"error": "email not verified",
+		})
+		return
+	}
 
-		tonkenstring, err := token.SignedString(auth.JwtSecret)
+	// With 2FA on, the first factor only earns a challenge for /login/mfa.
+	if user.MFAEnabled {
+		challenge, err := startMFAChallenge(r.Context(), db, user.ID)
 		if err != nil {
-			WriteJson(w, http.StatusInternalServerError, map[string]string{
-				"error": "failed to generate token",
-			})
+			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
-		WriteJson(w, http.StatusOK, map[string]string{
-			"token": tonkenstring,
-		})
+		WriteJson(w, http.StatusOK, challenge)
+		return
+	}
 
+	//============== JWT CREATION ==============
+	resp, err := startLogin(r.Context(), db, r, user.ID, user.Role)
+	if err != nil {
+		WriteJson(w, http.StatusInternalServerError, map[string]string{
+			"error": "failed to generate token",
+		})
+		return
 	}
+	WriteJson(w, http.StatusOK, resp)
 }
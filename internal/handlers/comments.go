@@ -0,0 +1,453 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"gotasker/internal/markdown"
+	"gotasker/internal/models"
+	"gotasker/internal/notifications"
+
+	"github.com/go-chi/chi"
+	"github.com/redis/go-redis/v9"
+)
+
+const maxCommentLength = 10000
+
+const commentColumns = `c.id, c.task_id, c.author_id, u.email, c.body, c.edited_at, c.created_at, c.updated_at`
+
+func scanComment(s rowScanner) (models.Comment, error) {
+	var c models.Comment
+	err := s.Scan(&c.ID, &c.TaskID, &c.AuthorID, &c.AuthorEmail, &c.Body, &c.EditedAt, &c.CreatedAt, &c.UpdatedAt)
+	c.BodyHTML = markdown.ToHTML(c.Body)
+	c.Edited = c.EditedAt != nil
+	c.Mentions = []models.CommentMention{}
+	return c, err
+}
+
+// saveMentions replaces the comment's mentions with the "@email" mentions in
+// body that belong to users who can see the task. Unknown addresses and
+// users without access are ignored. It returns the resolved mentions and the
+// IDs that were not mentioned before.
+func saveMentions(ctx context.Context, tx *sql.Tx, commentID int64, taskID int, body string) ([]models.CommentMention, []int64, error) {
+	previous := map[int64]bool{}
+	rows, err := tx.QueryContext(ctx, `
+		DELETE FROM comment_mentions WHERE comment_id = $1 RETURNING user_id`, commentID)
+	if err != nil {
+		return nil, nil, err
+	}
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, nil, err
+		}
+		previous[id] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	mentions := []models.CommentMention{}
+	emails := markdown.Mentions(body)
+	if len(emails) == 0 {
+		return mentions, nil, nil
+	}
+	viewers, err := taskViewerIDs(ctx, tx, taskID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	rows, err = tx.QueryContext(ctx, `
+		WITH inserted AS (
+			INSERT INTO comment_mentions (comment_id, user_id)
+			SELECT $1, id FROM users
+			WHERE email = ANY($2) AND id = ANY($3)
+			RETURNING user_id
+		)
+		SELECT u.id, u.email
+		FROM inserted i
+		JOIN users u ON u.id = i.user_id
+		ORDER BY u.email`,
+		commentID, emails, viewers)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	var added []int64
+	for rows.Next() {
+		var m models.CommentMention
+		if err := rows.Scan(&m.UserID, &m.Email); err != nil {
+			return nil, nil, err
+		}
+		mentions = append(mentions, m)
+		if !previous[m.UserID] {
+			added = append(added, m.UserID)
+		}
+	}
+	return mentions, added, rows.Err()
+}
+
+// notifyMentions tells newly mentioned users about the comment. Authors
+// mentioning themselves are skipped.
+func notifyMentions(ctx context.Context, tx *sql.Tx, c models.Comment, userIDs []int64) error {
+	if len(userIDs) == 0 {
+		return nil
+	}
+	var title string
+	if err := tx.QueryRowContext(ctx, `SELECT title FROM tasks WHERE id = $1`, c.TaskID).Scan(&title); err != nil {
+		return err
+	}
+
+	snippet := []rune(c.Body)
+	if len(snippet) > 140 {
+		snippet = append(snippet[:139], '…')
+	}
+	data, err := json.Marshal(map[string]int64{"comment_id": c.ID})
+	if err != nil {
+		return err
+	}
+
+	var ns []models.Notification
+	for _, id := range userIDs {
+		if id == c.AuthorID {
+			continue
+		}
+		ns = append(ns, models.Notification{
+			UserID:  id,
+			Type:    notifications.TypeMention,
+			TaskID:  &c.TaskID,
+			ActorID: &c.AuthorID,
+			Title:   c.AuthorEmail + " mentioned you on " + strconv.Quote(title),
+			Body:    string(snippet),
+			Data:    data,
+		})
+	}
+	return notifications.Notify(ctx, tx, ns...)
+}
+
+// attachMentions fills in Mentions for a page of comments with one query.
+func attachMentions(ctx context.Context, q dbtx, comments []models.Comment) error {
+	if len(comments) == 0 {
+		return nil
+	}
+	ids := make([]int64, len(comments))
+	index := make(map[int64]int, len(comments))
+	for i, c := range comments {
+		ids[i] = c.ID
+		index[c.ID] = i
+	}
+
+	rows, err := q.QueryContext(ctx, `
+		SELECT m.comment_id, m.user_id, u.email
+		FROM comment_mentions m
+		JOIN users u ON u.id = m.user_id
+		WHERE m.comment_id = ANY($1)
+		ORDER BY u.email`, ids)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			commentID int64
+			m         models.CommentMention
+		)
+		if err := rows.Scan(&commentID, &m.UserID, &m.Email); err != nil {
+			return err
+		}
+		c := &comments[index[commentID]]
+		c.Mentions = append(c.Mentions, m)
+	}
+	return rows.Err()
+}
+
+func validateCommentBody(body string) (string, string) {
+	body = strings.TrimSpace(body)
+	switch {
+	case body == "":
+		return "", "body is required"
+	case utf8.RuneCountInString(body) > maxCommentLength:
+		return "", "body is too long"
+	}
+	return body, ""
+}
+
+// ListCommentsHandler returns the task's comments oldest first, paginated
+// with limit/offset like /tasksdb.
+func ListCommentsHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		taskID, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			WriteJson(w, http.StatusBadRequest, map[string]string{"error": "Invalid task ID"})
+			return
+		}
+
+		limit := 50
+		if v := strings.TrimSpace(r.URL.Query().Get("limit")); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n <= 0 || n > 100 {
+				WriteJson(w, http.StatusBadRequest, map[string]string{"error": "invalid limit"})
+				return
+			}
+			limit = n
+		}
+		offset := 0
+		if v := strings.TrimSpace(r.URL.Query().Get("offset")); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				WriteJson(w, http.StatusBadRequest, map[string]string{"error": "invalid offset"})
+				return
+			}
+			offset = n
+		}
+
+		userID, ok := currentUserID(r)
+		if !ok {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		ctx := r.Context()
+		visible, err := canViewTask(ctx, db, userID, taskID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if !visible {
+			WriteJson(w, http.StatusNotFound, map[string]string{"error": "Task not found"})
+			return
+		}
+
+		rows, err := db.QueryContext(ctx, `
+			SELECT `+commentColumns+`
+			FROM task_comments c
+			JOIN users u ON u.id = c.author_id
+			WHERE c.task_id = $1
+			ORDER BY c.created_at, c.id
+			LIMIT $2 OFFSET $3`, taskID, limit, offset)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer rows.Close()
+
+		comments := make([]models.Comment, 0)
+		for rows.Next() {
+			c, err := scanComment(rows)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			comments = append(comments, c)
+		}
+		if err := rows.Err(); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		if err := attachMentions(ctx, db, comments); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		WriteJson(w, http.StatusOK, comments)
+	}
+}
+
+func CreateCommentHandler(db *sql.DB, rdb *redis.Client) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		taskID, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			WriteJson(w, http.StatusBadRequest, map[string]string{"error": "Invalid task ID"})
+			return
+		}
+
+		var req models.CommentRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			WriteJson(w, http.StatusBadRequest, map[string]string{"error": "Invalid Json"})
+			return
+		}
+		body, msg := validateCommentBody(req.Body)
+		if msg != "" {
+			WriteJson(w, http.StatusBadRequest, map[string]string{"error": msg})
+			return
+		}
+
+		userID, ok := currentUserID(r)
+		if !ok {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		ctx := r.Context()
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer tx.Rollback()
+
+		visible, err := canViewTask(ctx, tx, userID, taskID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if !visible {
+			WriteJson(w, http.StatusNotFound, map[string]string{"error": "Task not found"})
+			return
+		}
+
+		c, err := scanComment(tx.QueryRowContext(ctx, `
+			WITH c AS (
+				INSERT INTO task_comments (task_id, author_id, body)
+				VALUES ($1, $2, $3)
+				RETURNING *
+			)
+			SELECT `+commentColumns+`
+			FROM c JOIN users u ON u.id = c.author_id`, taskID, userID, body))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		var mentioned []int64
+		if c.Mentions, mentioned, err = saveMentions(ctx, tx, c.ID, taskID, body); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if err := notifyMentions(ctx, tx, c, mentioned); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		if err := tx.Commit(); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		invalidateTaskViewers(ctx, db, rdb, taskID)
+
+		WriteJson(w, http.StatusCreated, c)
+	}
+}
+
+// UpdateCommentHandler lets the author rewrite a comment; the comment is
+// marked as edited.
+func UpdateCommentHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+		if err != nil {
+			WriteJson(w, http.StatusBadRequest, map[string]string{"error": "Invalid comment ID"})
+			return
+		}
+
+		var req models.CommentRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			WriteJson(w, http.StatusBadRequest, map[string]string{"error": "Invalid Json"})
+			return
+		}
+		body, msg := validateCommentBody(req.Body)
+		if msg != "" {
+			WriteJson(w, http.StatusBadRequest, map[string]string{"error": msg})
+			return
+		}
+
+		userID, ok := currentUserID(r)
+		if !ok {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		ctx := r.Context()
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer tx.Rollback()
+
+		c, err := scanComment(tx.QueryRowContext(ctx, `
+			WITH c AS (
+				UPDATE task_comments
+				SET body = $1, edited_at = NOW(), updated_at = NOW()
+				WHERE id = $2 AND author_id = $3
+				RETURNING *
+			)
+			SELECT `+commentColumns+`
+			FROM c JOIN users u ON u.id = c.author_id`, body, id, userID))
+		if errors.Is(err, sql.ErrNoRows) {
+			WriteJson(w, http.StatusNotFound, map[string]string{"error": "Comment not found"})
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		var mentioned []int64
+		if c.Mentions, mentioned, err = saveMentions(ctx, tx, c.ID, c.TaskID, body); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if err := notifyMentions(ctx, tx, c, mentioned); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		if err := tx.Commit(); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		WriteJson(w, http.StatusOK, c)
+	}
+}
+
+// DeleteCommentHandler removes a comment. Authors can delete their own
+// comments and task owners any comment on their task.
+func DeleteCommentHandler(db *sql.DB, rdb *redis.Client) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+		if err != nil {
+			WriteJson(w, http.StatusBadRequest, map[string]string{"error": "Invalid comment ID"})
+			return
+		}
+
+		userID, ok := currentUserID(r)
+		if !ok {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		ctx := r.Context()
+		var taskID int
+		err = db.QueryRowContext(ctx, `
+			DELETE FROM task_comments c
+			USING tasks t
+			WHERE c.id = $1 AND t.id = c.task_id
+				AND (c.author_id = $2 OR t.user_id = $2)
+			RETURNING c.task_id`, id, userID).Scan(&taskID)
+		if errors.Is(err, sql.ErrNoRows) {
+			WriteJson(w, http.StatusNotFound, map[string]string{"error": "Comment not found"})
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		invalidateTaskViewers(ctx, db, rdb, taskID)
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
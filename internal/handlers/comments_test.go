@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"gotasker/internal/models"
+)
+
+func TestValidateCommentBody(t *testing.T) {
+	if body, msg := validateCommentBody("  hi  "); body != "hi" || msg != "" {
+		t.Errorf("got %q, %q", body, msg)
+	}
+	if _, msg := validateCommentBody(" \n "); msg == "" {
+		t.Error("blank body accepted")
+	}
+	if _, msg := validateCommentBody(strings.Repeat("é", maxCommentLength+1)); msg == "" {
+		t.Error("over-long body accepted")
+	}
+	if _, msg := validateCommentBody(strings.Repeat("é", maxCommentLength)); msg != "" {
+		t.Errorf("body at the limit refused: %s", msg)
+	}
+}
+
+func TestCommentAccess(t *testing.T) {
+	db := testDB(t)
+	owner := createTestUser(t, db, "owner@example.com")
+	member := createTestUser(t, db, "member@example.com")
+	outsider := createTestUser(t, db, "outsider@example.com")
+	wsID := sharedWorkspace(t, db, owner, map[int64]string{member: "member"})
+
+	var taskID int64
+	if err := db.QueryRow(`
+		INSERT INTO tasks (user_id, workspace_id, title) VALUES ($1, $2, 'Shared')
+		RETURNING id`, owner, wsID).Scan(&taskID); err != nil {
+		t.Fatal(err)
+	}
+
+	// Someone who cannot see the task can neither read nor write comments.
+	rec := serve(ListCommentsHandler(db), withTaskID(asUser(newJSONRequest(http.MethodGet, "/", ""), outsider), taskID))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("list as outsider: status %d, want 404", rec.Code)
+	}
+	rec = serve(CreateCommentHandler(db, nil), withTaskID(asUser(newJSONRequest(http.MethodPost, "/", `{"body":"hi"}`), outsider), taskID))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("create as outsider: status %d, want 404", rec.Code)
+	}
+
+	// Only people who can see the task are recorded as mentioned.
+	rec = serve(CreateCommentHandler(db, nil), withTaskID(asUser(newJSONRequest(http.MethodPost, "/",
+		`{"body":"ping @owner@example.com and @outsider@example.com"}`), member), taskID))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create as member: status %d: %s", rec.Code, rec.Body)
+	}
+	var c models.Comment
+	if err := json.Unmarshal(rec.Body.Bytes(), &c); err != nil {
+		t.Fatal(err)
+	}
+	if len(c.Mentions) != 1 || c.Mentions[0].UserID != owner {
+		t.Errorf("mentions %+v, want only the owner", c.Mentions)
+	}
+	var notified int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM notifications WHERE user_id = $1`, outsider).Scan(&notified); err != nil {
+		t.Fatal(err)
+	}
+	if notified != 0 {
+		t.Errorf("outsider got %d notifications", notified)
+	}
+
+	// Only the author may edit.
+	for _, userID := range []int64{owner, outsider} {
+		rec = serve(UpdateCommentHandler(db), withTaskID(asUser(newJSONRequest(http.MethodPatch, "/", `{"body":"rewritten"}`), userID), c.ID))
+		if rec.Code != http.StatusNotFound {
+			t.Errorf("edit as user %d: status %d, want 404", userID, rec.Code)
+		}
+	}
+	rec = serve(UpdateCommentHandler(db), withTaskID(asUser(newJSONRequest(http.MethodPatch, "/", `{"body":"rewritten"}`), member), c.ID))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"edited":true`) {
+		t.Errorf("edit as author: status %d: %s", rec.Code, rec.Body)
+	}
+
+	// The author or the task owner may delete; nobody else.
+	rec = serve(DeleteCommentHandler(db, nil), withTaskID(asUser(newJSONRequest(http.MethodDelete, "/", ""), outsider), c.ID))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("delete as outsider: status %d, want 404", rec.Code)
+	}
+	rec = serve(DeleteCommentHandler(db, nil), withTaskID(asUser(newJSONRequest(http.MethodDelete, "/", ""), owner), c.ID))
+	if rec.Code != http.StatusNoContent {
+		t.Errorf("delete as task owner: status %d: %s", rec.Code, rec.Body)
+	}
+}
@@ -3,6 +3,8 @@ package handlers
 import (
 	"encoding/json"
 	"net/http"
+
+	"gotasker/internal/auth"
 )
 
 func WriteJson(w http.ResponseWriter, status int, v any) {
@@ -20,3 +22,44 @@ func NextID() int {
 	}
 	return max + 1
 }
+
+// currentUserID returns the user ID stored in the request context by
+// auth.JWTMiddleware or auth.Authenticate.
+func currentUserID(r *http.Request) (int64, bool) {
+	userID, ok := r.Context().Value(auth.UserIDContextKey).(int64)
+	return userID, ok
+}
+
+// currentClaims returns the token claims stored in the request context by
+// auth.JWTMiddleware.
+func currentClaims(r *http.Request) (*auth.JWTClaims, bool) {
+	claims, ok := r.Context().Value(auth.ClaimsContextKey).(*auth.JWTClaims)
+	return claims, ok
+}
+
+// impersonating reports whether the request was made with an admin's
+// impersonation token.
+func impersonating(r *http.Request) bool {
+	_, ok := r.Context().Value(auth.ImpersonatorContextKey).(int64)
+	return ok
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// uniqueStrings returns list without duplicates, keeping first occurrences.
+func uniqueStrings(list []string) []string {
+	out := make([]string, 0, len(list))
+	for _, v := range list {
+		if !containsString(out, v) {
+			out = append(out, v)
+		}
+	}
+	return out
+}
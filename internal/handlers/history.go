@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"gotasker/internal/models"
+
+	"github.com/go-chi/chi"
+)
+
+// Task history event types.
+const (
+	eventAssigned = "assigned"
+)
+
+// recordTaskEvent appends an entry to the task's history. data is
+// marshalled to JSON.
+func recordTaskEvent(ctx context.Context, q dbtx, taskID int, actorID int64, eventType string, data any) error {
+	b, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	_, err = q.ExecContext(ctx, `
+		INSERT INTO task_history (task_id, actor_id, type, data)
+		VALUES ($1, $2, $3, $4::jsonb)`, taskID, actorID, eventType, string(b))
+	return err
+}
+
+// GetTaskHistoryHandler lists a task's history, newest first, paginated
+// with limit/offset as on /tasksdb.
+func GetTaskHistoryHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		taskID, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			WriteJson(w, http.StatusBadRequest, map[string]string{"error": "Invalid task ID"})
+			return
+		}
+
+		query := r.URL.Query()
+		limit := 20
+		if v := strings.TrimSpace(query.Get("limit")); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n <= 0 || n > 100 {
+				WriteJson(w, http.StatusBadRequest, map[string]string{"error": "invalid limit"})
+				return
+			}
+			limit = n
+		}
+		offset := 0
+		if v := strings.TrimSpace(query.Get("offset")); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				WriteJson(w, http.StatusBadRequest, map[string]string{"error": "invalid offset"})
+				return
+			}
+			offset = n
+		}
+
+		userID, ok := currentUserID(r)
+		if !ok {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		ctx := r.Context()
+		visible, err := canViewTask(ctx, db, userID, taskID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if !visible {
+			WriteJson(w, http.StatusNotFound, map[string]string{"error": "Task not found"})
+			return
+		}
+
+		rows, err := db.QueryContext(ctx, `
+			SELECT id, task_id, actor_id, type, data, created_at
+			FROM task_history
+			WHERE task_id = $1
+			ORDER BY created_at DESC, id DESC
+			LIMIT $2 OFFSET $3`, taskID, limit, offset)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer rows.Close()
+
+		events := make([]models.TaskEvent, 0)
+		for rows.Next() {
+			var (
+				e    models.TaskEvent
+				data []byte
+			)
+			if err := rows.Scan(&e.ID, &e.TaskID, &e.ActorID, &e.Type, &data, &e.CreatedAt); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			e.Data = data
+			events = append(events, e)
+		}
+		if err := rows.Err(); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		WriteJson(w, http.StatusOK, events)
+	}
+}
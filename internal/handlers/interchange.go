@@ -0,0 +1,184 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+
+	"gotasker/internal/interchange"
+	"gotasker/internal/models"
+	cache "gotasker/internal/redis"
+
+	"database/sql"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const maxImportBytes = 10 << 20
+
+var exportContentTypes = map[string]string{
+	interchange.FormatTodoTxtName:  "text/plain; charset=utf-8",
+	interchange.FormatMarkdownName: "text/markdown; charset=utf-8",
+}
+
+var exportFileNames = map[string]string{
+	interchange.FormatTodoTxtName:  "todo.txt",
+	interchange.FormatMarkdownName: "tasks.md",
+}
+
+// ExportTasksHandlerDB writes all of the user's tasks in the format given by
+// ?format=todotxt|markdown.
+func ExportTasksHandlerDB(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		format := strings.TrimSpace(r.URL.Query().Get("format"))
+		contentType, ok := exportContentTypes[format]
+		if !ok {
+			WriteJson(w, http.StatusBadRequest, map[string]string{"error": "format must be todotxt or markdown"})
+			return
+		}
+
+		userID, ok := currentUserID(r)
+		if !ok {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		rows, err := db.QueryContext(r.Context(), `
+			SELECT `+taskColumns+`
+			FROM tasks
+			WHERE user_id = $1
+			ORDER BY created_at ASC, id ASC`, userID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer rows.Close()
+
+		tasks := make([]models.Task, 0)
+		for rows.Next() {
+			t, err := scanTask(rows)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			tasks = append(tasks, t)
+		}
+		if err := rows.Err(); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		var buf bytes.Buffer
+		if err := interchange.Format(format, &buf, tasks); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Content-Disposition", `attachment; filename="`+exportFileNames[format]+`"`)
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write(buf.Bytes())
+	}
+}
+
+// ImportTasksHandlerDB creates tasks from an uploaded file in the format given
+// by ?format=todotxt|markdown|todoist|trello|github. The file is read from the
+// multipart field "file" or, for any other content type, from the raw request
+// body. All tasks are inserted in one transaction, and the response reports
+// source fields that could not be mapped onto tasks.
+func ImportTasksHandlerDB(db *sql.DB, rdb *redis.Client) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		format := strings.TrimSpace(r.URL.Query().Get("format"))
+
+		userID, ok := currentUserID(r)
+		if !ok {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		body, err := readImportBody(w, r)
+		if err != nil {
+			WriteJson(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+
+		result, err := interchange.Import(format, bytes.NewReader(body))
+		if errors.Is(err, interchange.ErrUnknownFormat) {
+			WriteJson(w, http.StatusBadRequest, map[string]string{
+				"error": "format must be one of todotxt, markdown, todoist, trello, github",
+			})
+			return
+		}
+		if err != nil {
+			WriteJson(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+
+		ctx := r.Context()
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer tx.Rollback()
+
+		// Records arrive parents first, so every ParentRef is already mapped.
+		ids := make(map[string]int, len(result.Records))
+		created := make([]models.Task, 0, len(result.Records))
+		for _, rec := range result.Records {
+			t := rec.Task
+			if rec.ParentRef != "" {
+				parentID := ids[rec.ParentRef]
+				t.ParentID = &parentID
+			}
+			stored, err := insertTask(ctx, tx, userID, t)
+			if err != nil {
+				WriteJson(w, http.StatusInternalServerError, map[string]string{"error": "failed to import tasks"})
+				return
+			}
+			if rec.Ref != "" {
+				ids[rec.Ref] = stored.ID
+			}
+			created = append(created, stored)
+		}
+
+		if err := tx.Commit(); err != nil {
+			WriteJson(w, http.StatusInternalServerError, map[string]string{"error": "failed to import tasks"})
+			return
+		}
+
+		if err := cache.DeletTaks(ctx, rdb, userID); err != nil {
+			log.Printf("Redis DEL failed: %v", err)
+		}
+
+		WriteJson(w, http.StatusCreated, models.ImportResponse{
+			Imported: len(created),
+			Tasks:    created,
+			Unmapped: result.Unmapped,
+		})
+	}
+}
+
+// readImportBody returns the uploaded file, either from the multipart field
+// "file" or from the raw body.
+func readImportBody(w http.ResponseWriter, r *http.Request) ([]byte, error) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxImportBytes)
+
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		file, _, err := r.FormFile("file")
+		if err != nil {
+			return nil, errors.New("missing file field")
+		}
+		defer file.Close()
+		return io.ReadAll(file)
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, errors.New("request body too large or unreadable")
+	}
+	return body, nil
+}
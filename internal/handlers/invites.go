@@ -0,0 +1,442 @@
+package handlers
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"gotasker/internal/auth"
+	"gotasker/internal/mail"
+	"gotasker/internal/middleware"
+	"gotasker/internal/models"
+	"gotasker/internal/workspace"
+
+	"github.com/go-chi/chi"
+	"github.com/redis/go-redis/v9"
+)
+
+// inviteTTL is how long an invite can be accepted.
+const inviteTTL = 7 * 24 * time.Hour
+
+var (
+	errInvalidInvite = errors.New("invite is invalid, expired or already used")
+	errInviteEmail   = errors.New("invite was sent to a different email address")
+)
+
+// inviteSigningKey signs invite tokens.
+var inviteSigningKey = func() []byte {
+	if k := os.Getenv("INVITE_SIGNING_KEY"); k != "" {
+		return []byte(k)
+	}
+	return auth.JwtSecret
+}()
+
+func inviteSignature(nonce string) string {
+	m := hmac.New(sha256.New, inviteSigningKey)
+	fmt.Fprintf(m, "invite:%s", nonce)
+	return hex.EncodeToString(m.Sum(nil))
+}
+
+// newInviteToken returns a token of the form <nonce>.<signature>.
+func newInviteToken() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	nonce := hex.EncodeToString(b)
+	return nonce + "." + inviteSignature(nonce), nil
+}
+
+// inviteTokenHash checks the token's signature and returns the hash it is
+// stored under.
+func inviteTokenHash(token string) (string, bool) {
+	nonce, sig, ok := strings.Cut(token, ".")
+	if !ok || !hmac.Equal([]byte(sig), []byte(inviteSignature(nonce))) {
+		return "", false
+	}
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:]), true
+}
+
+const inviteSelect = `
+	SELECT i.id, i.type, i.email, i.workspace_id, i.role, u.id, u.email,
+		CASE
+			WHEN i.accepted_at IS NOT NULL THEN 'accepted'
+			WHEN i.revoked_at IS NOT NULL THEN 'revoked'
+			WHEN i.expires_at <= NOW() THEN 'expired'
+			ELSE 'pending'
+		END,
+		i.expires_at, i.accepted_at, i.created_at
+	FROM invites i
+	JOIN users u ON u.id = i.inviter_id`
+
+func scanInvite(s rowScanner) (models.Invite, error) {
+	var inv models.Invite
+	err := s.Scan(&inv.ID, &inv.Type, &inv.Email, &inv.WorkspaceID, &inv.Role,
+		&inv.Inviter.ID, &inv.Inviter.Email, &inv.Status, &inv.ExpiresAt, &inv.AcceptedAt, &inv.CreatedAt)
+	return inv, err
+}
+
+func loadInvite(ctx context.Context, q dbtx, id int64) (models.Invite, error) {
+	return scanInvite(q.QueryRowContext(ctx, inviteSelect+` WHERE i.id = $1`, id))
+}
+
+// acceptInvite redeems the token for the user with the given email: it
+// adds them to the workspace (keeping any role they already have) or
+// shares the inviter's list with them. The invite can be used only once.
+func acceptInvite(ctx context.Context, tx *sql.Tx, token string, userID int64, email string) (models.Invite, error) {
+	hash, ok := inviteTokenHash(token)
+	if !ok {
+		return models.Invite{}, errInvalidInvite
+	}
+
+	var (
+		id          int64
+		inviteType  string
+		inviteEmail string
+		workspaceID int64
+		role        string
+		inviterID   int64
+	)
+	err := tx.QueryRowContext(ctx, `
+		UPDATE invites SET accepted_at = NOW(), accepted_by = $2
+		WHERE token_hash = $1 AND accepted_at IS NULL AND revoked_at IS NULL AND expires_at > NOW()
+		RETURNING id, type, email, workspace_id, role, inviter_id`, hash, userID).
+		Scan(&id, &inviteType, &inviteEmail, &workspaceID, &role, &inviterID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.Invite{}, errInvalidInvite
+	}
+	if err != nil {
+		return models.Invite{}, err
+	}
+	if !strings.EqualFold(inviteEmail, email) {
+		return models.Invite{}, errInviteEmail
+	}
+
+	switch inviteType {
+	case models.InviteWorkspace:
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO workspace_members (workspace_id, user_id, role)
+			VALUES ($1, $2, $3)
+			ON CONFLICT DO NOTHING`, workspaceID, userID, role)
+	case models.InviteList:
+		if inviterID == userID {
+			return models.Invite{}, errInvalidInvite
+		}
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO share_grants (owner_id, grantee_id, workspace_id, permission)
+			VALUES ($1, $2, $3, $4)
+			ON CONFLICT (workspace_id, grantee_id) WHERE workspace_id IS NOT NULL
+			DO UPDATE SET permission = EXCLUDED.permission`, inviterID, userID, workspaceID, role)
+	}
+	if err != nil {
+		return models.Invite{}, err
+	}
+
+	return loadInvite(ctx, tx, id)
+}
+
+// writeInviteError answers an acceptInvite failure.
+func writeInviteError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, errInvalidInvite):
+		WriteJson(w, http.StatusGone, map[string]string{"error": err.Error()})
+	case errors.Is(err, errInviteEmail):
+		WriteJson(w, http.StatusForbidden, map[string]string{"error": err.Error()})
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// CreateInviteHandler invites an email address to a workspace (the user
+// needs the rights to add a member with that role) or to the user's list.
+// The token is only ever sent through mailer.
+func CreateInviteHandler(db *sql.DB, mailer mail.Mailer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req models.InviteRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			WriteJson(w, http.StatusBadRequest, map[string]string{"error": "Invalid Json"})
+			return
+		}
+		req.Email = strings.TrimSpace(strings.ToLower(req.Email))
+		if !validEmail(req.Email) {
+			WriteJson(w, http.StatusBadRequest, map[string]string{"error": "valid email is required"})
+			return
+		}
+
+		userID, ok := currentUserID(r)
+		if !ok {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		ctx := r.Context()
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer tx.Rollback()
+
+		var (
+			workspaceID int64
+			target      string
+		)
+		switch req.Type {
+		case models.InviteWorkspace:
+			if req.Role == "" {
+				req.Role = workspace.RoleMember
+			}
+			if !workspace.ValidRole(req.Role) {
+				WriteJson(w, http.StatusBadRequest, map[string]string{"error": "invalid role"})
+				return
+			}
+			if req.WorkspaceID == nil {
+				WriteJson(w, http.StatusBadRequest, map[string]string{"error": "workspace_id is required"})
+				return
+			}
+			ws, err := loadWorkspace(ctx, tx, userID, *req.WorkspaceID)
+			if errors.Is(err, sql.ErrNoRows) {
+				WriteJson(w, http.StatusNotFound, map[string]string{"error": "Workspace not found"})
+				return
+			}
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			if ws.Personal {
+				WriteJson(w, http.StatusBadRequest, map[string]string{"error": "personal workspace cannot have members"})
+				return
+			}
+			if !workspace.CanAssign(ws.Role, "", req.Role) {
+				WriteJson(w, http.StatusForbidden, map[string]string{"error": "insufficient role"})
+				return
+			}
+			workspaceID = ws.ID
+			target = "the workspace " + strconv.Quote(ws.Name)
+		case models.InviteList:
+			if req.Role == "" {
+				req.Role = workspace.PermissionView
+			}
+			if !workspace.ValidPermission(req.Role) {
+				WriteJson(w, http.StatusBadRequest, map[string]string{"error": "invalid permission"})
+				return
+			}
+			workspaceID, err = ensurePersonalWorkspace(ctx, tx, userID)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			target = "their task list"
+		default:
+			WriteJson(w, http.StatusBadRequest, map[string]string{"error": "type must be workspace or list"})
+			return
+		}
+
+		token, err := newInviteToken()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		hash, _ := inviteTokenHash(token)
+
+		var id int64
+		if err := tx.QueryRowContext(ctx, `
+			INSERT INTO invites (inviter_id, email, type, workspace_id, role, token_hash, expires_at)
+			VALUES ($1, $2, $3, $4, $5, $6, NOW() + $7 * INTERVAL '1 second')
+			RETURNING id`, userID, req.Email, req.Type, workspaceID, req.Role, hash,
+			int64(inviteTTL/time.Second)).Scan(&id); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		inv, err := loadInvite(ctx, tx, id)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		// Send before committing so a failed delivery leaves no invite behind.
+		if err := mailer.Send(ctx, mail.Message{
+			To:      inv.Email,
+			Subject: "You have been invited to GoTasker",
+			Body: fmt.Sprintf("%s invited you to %s as %s.\n\n"+
+				"Accept the invite at %s/invites/accept?token=%s or sign up with invite token:\n%s\n\n"+
+				"The invite expires on %s.\n",
+				inv.Inviter.Email, target, inv.Role,
+				middleware.FrontendOrigin, url.QueryEscape(token), token,
+				inv.ExpiresAt.UTC().Format(time.RFC1123)),
+		}); err != nil {
+			WriteJson(w, http.StatusBadGateway, map[string]string{"error": "failed to send invite"})
+			return
+		}
+
+		if err := tx.Commit(); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		WriteJson(w, http.StatusCreated, inv)
+	}
+}
+
+// ListInvitesHandler lists the invites the user sent and those of the
+// workspaces they manage. ?status= filters by status.
+func ListInvitesHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		status := strings.TrimSpace(r.URL.Query().Get("status"))
+		switch status {
+		case "", models.InvitePending, models.InviteAccepted, models.InviteRevoked, models.InviteExpired:
+		default:
+			WriteJson(w, http.StatusBadRequest, map[string]string{"error": "invalid status"})
+			return
+		}
+
+		userID, ok := currentUserID(r)
+		if !ok {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		rows, err := db.QueryContext(r.Context(), `
+			SELECT * FROM (`+inviteSelect+`
+				WHERE i.inviter_id = $1 OR i.workspace_id IN (
+					SELECT workspace_id FROM workspace_members
+					WHERE user_id = $1 AND role IN ('owner', 'admin')
+				)
+			) invites (id, type, email, workspace_id, role, inviter_id, inviter_email, status, expires_at, accepted_at, created_at)
+			WHERE $2 = '' OR status = $2
+			ORDER BY created_at DESC, id DESC`, userID, status)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer rows.Close()
+
+		invites := make([]models.Invite, 0)
+		for rows.Next() {
+			inv, err := scanInvite(rows)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			invites = append(invites, inv)
+		}
+		if err := rows.Err(); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		WriteJson(w, http.StatusOK, invites)
+	}
+}
+
+// RevokeInviteHandler revokes a pending invite. The inviter and the
+// workspace's admins may revoke it.
+func RevokeInviteHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+		if err != nil {
+			WriteJson(w, http.StatusBadRequest, map[string]string{"error": "Invalid invite ID"})
+			return
+		}
+
+		userID, ok := currentUserID(r)
+		if !ok {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		ctx := r.Context()
+		inv, err := loadInvite(ctx, db, id)
+		if errors.Is(err, sql.ErrNoRows) {
+			WriteJson(w, http.StatusNotFound, map[string]string{"error": "Invite not found"})
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		allowed := inv.Inviter.ID == userID
+		if !allowed && inv.Type == models.InviteWorkspace {
+			role, err := workspaceRole(ctx, db, userID, inv.WorkspaceID)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			allowed = workspace.CanManage(role)
+		}
+		if !allowed {
+			WriteJson(w, http.StatusNotFound, map[string]string{"error": "Invite not found"})
+			return
+		}
+
+		res, err := db.ExecContext(ctx, `
+			UPDATE invites SET revoked_at = NOW()
+			WHERE id = $1 AND accepted_at IS NULL AND revoked_at IS NULL AND expires_at > NOW()`, id)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			WriteJson(w, http.StatusConflict, map[string]string{"error": "invite is no longer pending"})
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// AcceptInviteHandler redeems an invite for the logged-in user, whose email
+// must match the invite's.
+func AcceptInviteHandler(db *sql.DB, rdb *redis.Client) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token := chi.URLParam(r, "token")
+
+		userID, ok := currentUserID(r)
+		if !ok {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		ctx := r.Context()
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer tx.Rollback()
+
+		var email string
+		if err := tx.QueryRowContext(ctx, `SELECT email FROM users WHERE id = $1`, userID).Scan(&email); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		inv, err := acceptInvite(ctx, tx, token, userID, email)
+		if err != nil {
+			writeInviteError(w, err)
+			return
+		}
+		if err := tx.Commit(); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		invalidateUsers(ctx, rdb, userID)
+
+		WriteJson(w, http.StatusOK, inv)
+	}
+}
@@ -0,0 +1,41 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"gotasker/internal/middleware"
+)
+
+func TestCreateInviteRejectsInvalidEmails(t *testing.T) {
+	mailer := &recordingMailer{}
+	for _, email := range []string{"", "@", "bob@", "@example.com", "bob@localhost", "Bob <bob@example.com>", "bob@@example.com"} {
+		body := `{"email":` + strconv.Quote(email) + `,"type":"list"}`
+		rec := httptest.NewRecorder()
+		// Rejected before the database is touched.
+		CreateInviteHandler(nil, mailer)(rec, asUser(httptest.NewRequest(http.MethodPost, "/invites", strings.NewReader(body)), 1))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%q: status %d, want 400", email, rec.Code)
+		}
+	}
+}
+
+func TestInviteEmailLinksToTheApp(t *testing.T) {
+	db := testDB(t)
+	userID := createTestUser(t, db, "inviter@example.com")
+	mailer := &recordingMailer{}
+
+	rec := httptest.NewRecorder()
+	CreateInviteHandler(db, mailer)(rec, asUser(httptest.NewRequest(http.MethodPost, "/invites",
+		strings.NewReader(`{"email":"friend@example.com","type":"list"}`)), userID))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("invite: status %d: %s", rec.Code, rec.Body)
+	}
+	mailer.waitForMail(t, 1)
+	if body := mailer.sent[0].Body; !strings.Contains(body, middleware.FrontendOrigin+"/invites/accept?token=") {
+		t.Errorf("invite email does not link to the app's accept page:\n%s", body)
+	}
+}
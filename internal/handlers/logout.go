@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"gotasker/internal/auth"
+	internalRedis "gotasker/internal/redis"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// LogoutHandler revokes the access token the request was made with and the
+// session it belongs to, so its refresh token stops working too. The access
+// token also goes on the Redis denylist until it would have expired, which
+// is what revokes tokens without a session.
+func LogoutHandler(db *sql.DB, rdb *redis.Client) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		claims, ok := currentClaims(r)
+		if !ok {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		ctx := r.Context()
+		if claims.SessionID != 0 {
+			if err := revokeSession(ctx, db, claims.SessionID); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+
+		if claims.ID != "" && claims.ExpiresAt != nil {
+			if err := internalRedis.DenyToken(ctx, rdb, claims.ID, time.Until(claims.ExpiresAt.Time)); err != nil {
+				if !errors.Is(err, internalRedis.ErrNoClient) {
+					log.Printf("logout: deny token for user %d: %v", claims.UserID, err)
+				}
+				// A revoked session already rejects the token. Without a
+				// session only the denylist does, so this fails or passes
+				// the way auth.JWTMiddleware's denylist check does.
+				if claims.SessionID == 0 && auth.DenylistFailClosed {
+					WriteJson(w, http.StatusServiceUnavailable, map[string]string{
+						"error": "access token could not be revoked; it stays valid until it expires",
+					})
+					return
+				}
+			}
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// LogoutAllHandler signs the user out everywhere: it bumps their token
+// epoch, which invalidates every access token issued so far, and revokes
+// all of their refresh tokens. An impersonating admin may not do this.
+func LogoutAllHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := currentUserID(r)
+		if !ok {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if impersonating(r) {
+			WriteJson(w, http.StatusForbidden, map[string]string{"error": "not allowed while impersonating"})
+			return
+		}
+
+		ctx := r.Context()
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer tx.Rollback()
+
+		if _, err := tx.ExecContext(ctx, `
+			UPDATE users SET token_epoch = token_epoch + 1, updated_at = NOW()
+			WHERE id = $1`, userID); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if err := revokeUserSessions(ctx, tx, userID); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if err := tx.Commit(); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
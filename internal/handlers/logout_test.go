@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"gotasker/internal/auth"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestLogoutWithoutRedis(t *testing.T) {
+	db := testDB(t)
+	userID := createTestUser(t, db, "logout@example.com")
+	tokens := loginTestUser(t, db, userID)
+	mw := auth.JWTMiddleware(db, nil)
+
+	if code := serveAuthed(mw, okHandler, withBearer(http.MethodGet, "/settings", tokens.Token)); code != http.StatusOK {
+		t.Fatalf("before logout: status %d", code)
+	}
+	// Without Redis nothing goes on the denylist, but the session is
+	// revoked and that is enough.
+	if code := serveAuthed(mw, LogoutHandler(db, nil), withBearer(http.MethodPost, "/logout", tokens.Token)); code != http.StatusNoContent {
+		t.Fatalf("logout: status %d, want 204", code)
+	}
+	if code := serveAuthed(mw, okHandler, withBearer(http.MethodGet, "/settings", tokens.Token)); code != http.StatusUnauthorized {
+		t.Errorf("after logout: status %d, want 401", code)
+	}
+}
+
+func TestLogoutSessionlessTokenFollowsFailMode(t *testing.T) {
+	defer func(old bool) { auth.DenylistFailClosed = old }(auth.DenylistFailClosed)
+
+	// Impersonation tokens have no session; only the denylist revokes
+	// them, and without Redis it cannot.
+	claims := &auth.JWTClaims{
+		UserID: 7,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        "jti",
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+		},
+	}
+	for _, tt := range []struct {
+		failClosed bool
+		want       int
+	}{
+		{false, http.StatusNoContent},
+		{true, http.StatusServiceUnavailable},
+	} {
+		auth.DenylistFailClosed = tt.failClosed
+		req := httptest.NewRequest(http.MethodPost, "/logout", nil)
+		req = req.WithContext(context.WithValue(req.Context(), auth.ClaimsContextKey, claims))
+		rec := httptest.NewRecorder()
+		LogoutHandler(nil, nil)(rec, req)
+		if rec.Code != tt.want {
+			t.Errorf("fail closed %v: status %d, want %d", tt.failClosed, rec.Code, tt.want)
+		}
+	}
+}
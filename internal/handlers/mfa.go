@@ -0,0 +1,592 @@
+package handlers
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"gotasker/internal/auth"
+	"gotasker/internal/models"
+)
+
+const (
+	totpIssuer = "GoTasker"
+	// mfaChallengeTTL is how long the second login step may take.
+	mfaChallengeTTL = 5 * time.Minute
+	// maxMFAAttempts is how many wrong codes a challenge survives.
+	maxMFAAttempts = 5
+	// maxMFAFailures is how many wrong codes in a row, across challenges
+	// and the 2FA settings routes, lock a user's second factor for
+	// mfaLockout. Past it every wrong code renews the lock, until a code
+	// is accepted.
+	maxMFAFailures    = 10
+	mfaLockout        = 15 * time.Minute
+	recoveryCodeCount = 10
+)
+
+var (
+	errMFANotEnabled = errors.New("two-factor authentication is not enabled")
+	errInvalidMFA    = errors.New("invalid code")
+	errMFALocked     = errors.New("too many invalid codes; try again later")
+)
+
+// mfaKey encrypts TOTP secrets at rest.
+var mfaKey = func() []byte {
+	k := []byte(os.Getenv("MFA_ENCRYPTION_KEY"))
+	if len(k) == 0 {
+		k = auth.JwtSecret
+	}
+	sum := sha256.Sum256(k)
+	return sum[:]
+}()
+
+// sealTOTPSecret encrypts secret with AES-GCM for storage.
+func sealTOTPSecret(secret string) (string, error) {
+	block, err := aes.NewCipher(mfaKey)
+	if err != nil {
+		return "", err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(gcm.Seal(nonce, nonce, []byte(secret), nil)), nil
+}
+
+func openTOTPSecret(sealed string) (string, error) {
+	b, err := base64.StdEncoding.DecodeString(sealed)
+	if err != nil {
+		return "", err
+	}
+	block, err := aes.NewCipher(mfaKey)
+	if err != nil {
+		return "", err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return "", err
+	}
+	if len(b) < gcm.NonceSize() {
+		return "", errors.New("totp secret too short")
+	}
+	plain, err := gcm.Open(nil, b[:gcm.NonceSize()], b[gcm.NonceSize():], nil)
+	return string(plain), err
+}
+
+// normalizeRecoveryCode makes "ABCDE-fghij" and "abcdefghij" the same code.
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
+
+// replaceRecoveryCodes drops the user's recovery codes and returns a fresh
+// set, formatted as xxxxx-xxxxx.
+func replaceRecoveryCodes(ctx context.Context, q dbtx, userID int64) ([]string, error) {
+	if _, err := q.ExecContext(ctx, `DELETE FROM mfa_recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return nil, err
+	}
+
+	enc := base32.StdEncoding.WithPadding(base32.NoPadding)
+	codes := make([]string, 0, recoveryCodeCount)
+	for len(codes) < recoveryCodeCount {
+		b := make([]byte, 7)
+		if _, err := rand.Read(b); err != nil {
+			return nil, err
+		}
+		raw := strings.ToLower(enc.EncodeToString(b))[:10]
+		res, err := q.ExecContext(ctx, `
+			INSERT INTO mfa_recovery_codes (user_id, code_hash) VALUES ($1, $2)
+			ON CONFLICT DO NOTHING`, userID, hashToken(raw))
+		if err != nil {
+			return nil, err
+		}
+		if n, err := res.RowsAffected(); err != nil {
+			return nil, err
+		} else if n == 1 {
+			codes = append(codes, raw[:5]+"-"+raw[5:])
+		}
+	}
+	return codes, nil
+}
+
+// checkSecondFactor verifies a TOTP code or, failing that, uses up a
+// recovery code of the user. It must run in a transaction: the user row
+// is locked so the same TOTP code cannot be accepted twice. While the
+// user is locked out no code is checked and errMFALocked is returned. A
+// wrong code is counted in tx, so callers commit on errInvalidMFA.
+func checkSecondFactor(ctx context.Context, tx *sql.Tx, userID int64, code, recoveryCode string) error {
+	var (
+		sealed    *string
+		enabledAt *time.Time
+		lastStep  int64
+		locked    bool
+	)
+	if err := tx.QueryRowContext(ctx, `
+		SELECT totp_secret, totp_enabled_at, totp_last_step, COALESCE(mfa_locked_until > NOW(), FALSE)
+		FROM users WHERE id = $1
+		FOR UPDATE`, userID).Scan(&sealed, &enabledAt, &lastStep, &locked); err != nil {
+		return err
+	}
+	if sealed == nil || enabledAt == nil {
+		return errMFANotEnabled
+	}
+	if locked {
+		return errMFALocked
+	}
+
+	err := verifySecondFactor(ctx, tx, userID, *sealed, lastStep, code, recoveryCode)
+	if errors.Is(err, errInvalidMFA) {
+		if _, err := tx.ExecContext(ctx, `
+			UPDATE users SET
+				mfa_failed_attempts = mfa_failed_attempts + 1,
+				mfa_locked_until = CASE WHEN mfa_failed_attempts + 1 >= $2
+					THEN NOW() + $3 * INTERVAL '1 second' END
+			WHERE id = $1`, userID, maxMFAFailures, int64(mfaLockout/time.Second)); err != nil {
+			return err
+		}
+		return errInvalidMFA
+	}
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, `
+		UPDATE users SET mfa_failed_attempts = 0, mfa_locked_until = NULL
+		WHERE id = $1 AND mfa_failed_attempts > 0`, userID)
+	return err
+}
+
+// verifySecondFactor does the checking for checkSecondFactor.
+func verifySecondFactor(ctx context.Context, tx *sql.Tx, userID int64, sealed string, lastStep int64, code, recoveryCode string) error {
+
+	if strings.TrimSpace(code) != "" {
+		secret, err := openTOTPSecret(sealed)
+		if err != nil {
+			return err
+		}
+		step, ok := auth.ValidateTOTP(secret, code, time.Now(), lastStep)
+		if !ok {
+			return errInvalidMFA
+		}
+		_, err = tx.ExecContext(ctx, `UPDATE users SET totp_last_step = $2 WHERE id = $1`, userID, step)
+		return err
+	}
+
+	if recoveryCode = normalizeRecoveryCode(recoveryCode); recoveryCode != "" {
+		res, err := tx.ExecContext(ctx, `
+			UPDATE mfa_recovery_codes SET used_at = NOW()
+			WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL`, userID, hashToken(recoveryCode))
+		if err != nil {
+			return err
+		}
+		if n, err := res.RowsAffected(); err != nil {
+			return err
+		} else if n == 0 {
+			return errInvalidMFA
+		}
+		return nil
+	}
+	return errInvalidMFA
+}
+
+// startMFAChallenge stores a challenge for a user who passed the password
+// step of login.
+func startMFAChallenge(ctx context.Context, db *sql.DB, userID int64) (models.MFAChallenge, error) {
+	token, err := newOpaqueToken()
+	if err != nil {
+		return models.MFAChallenge{}, err
+	}
+	c := models.MFAChallenge{MFARequired: true, MFAToken: token}
+	err = db.QueryRowContext(ctx, `
+		INSERT INTO mfa_challenges (user_id, token_hash, expires_at)
+		VALUES ($1, $2, NOW() + $3 * INTERVAL '1 second')
+		RETURNING expires_at`, userID, hashToken(token), int64(mfaChallengeTTL/time.Second)).Scan(&c.ExpiresAt)
+	return c, err
+}
+
+// LoginMFAHandler is the second login step: it exchanges an mfa_token and
+// a TOTP or recovery code for a token pair. A challenge works once and is
+// dropped after maxMFAAttempts wrong codes; wrong codes across challenges
+// lock the user out as described at maxMFAFailures.
+func LoginMFAHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req models.MFALoginRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			WriteJson(w, http.StatusBadRequest, map[string]string{"error": "Invalid JSON"})
+			return
+		}
+		req.MFAToken = strings.TrimSpace(req.MFAToken)
+		if req.MFAToken == "" || (strings.TrimSpace(req.Code) == "" && strings.TrimSpace(req.RecoveryCode) == "") {
+			WriteJson(w, http.StatusBadRequest, map[string]string{"error": "mfa_token and code or recovery_code are required"})
+			return
+		}
+
+		ctx := r.Context()
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer tx.Rollback()
+
+		var challengeID, userID int64
+		err = tx.QueryRowContext(ctx, `
+			SELECT id, user_id FROM mfa_challenges
+			WHERE token_hash = $1 AND used_at IS NULL AND expires_at > NOW() AND attempts < $2
+			FOR UPDATE`, hashToken(req.MFAToken), maxMFAAttempts).Scan(&challengeID, &userID)
+		if errors.Is(err, sql.ErrNoRows) {
+			WriteJson(w, http.StatusUnauthorized, map[string]string{"error": "invalid or expired mfa_token; log in again"})
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		err = checkSecondFactor(ctx, tx, userID, req.Code, req.RecoveryCode)
+		if errors.Is(err, errMFALocked) {
+			WriteJson(w, http.StatusTooManyRequests, map[string]string{"error": err.Error()})
+			return
+		}
+		if errors.Is(err, errInvalidMFA) || errors.Is(err, errMFANotEnabled) {
+			// Count the failure even though the request fails.
+			if _, err := tx.ExecContext(ctx, `
+				UPDATE mfa_challenges SET attempts = attempts + 1 WHERE id = $1`, challengeID); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			if err := tx.Commit(); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			WriteJson(w, http.StatusUnauthorized, map[string]string{"error": errInvalidMFA.Error()})
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		if _, err := tx.ExecContext(ctx, `
+			UPDATE mfa_challenges SET used_at = NOW() WHERE id = $1`, challengeID); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		var role string
+		if err := tx.QueryRowContext(ctx, `SELECT role FROM users WHERE id = $1`, userID).Scan(&role); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if err := tx.Commit(); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		resp, err := startLogin(ctx, db, r, userID, role)
+		if err != nil {
+			WriteJson(w, http.StatusInternalServerError, map[string]string{"error": "failed to generate token"})
+			return
+		}
+		WriteJson(w, http.StatusOK, resp)
+	}
+}
+
+// GetMFAStatusHandler reports whether 2FA is on and how many recovery
+// codes are left.
+func GetMFAStatusHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := currentUserID(r)
+		if !ok {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		var status models.MFAStatus
+		err := db.QueryRowContext(r.Context(), `
+			SELECT totp_enabled_at,
+				(SELECT COUNT(*) FROM mfa_recovery_codes WHERE user_id = u.id AND used_at IS NULL)
+			FROM users u WHERE u.id = $1`, userID).Scan(&status.EnabledAt, &status.RecoveryCodesRemaining)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		status.Enabled = status.EnabledAt != nil
+
+		WriteJson(w, http.StatusOK, status)
+	}
+}
+
+// EnrollTOTPHandler generates a new TOTP secret. 2FA is only switched on
+// once a code from it is confirmed; enrolling again replaces a pending
+// secret.
+func EnrollTOTPHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := currentUserID(r)
+		if !ok {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if impersonating(r) {
+			WriteJson(w, http.StatusForbidden, map[string]string{"error": "not allowed while impersonating"})
+			return
+		}
+
+		secret, err := auth.NewTOTPSecret()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		sealed, err := sealTOTPSecret(secret)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		var email string
+		err = db.QueryRowContext(r.Context(), `
+			UPDATE users SET totp_secret = $2, totp_last_step = 0
+			WHERE id = $1 AND totp_enabled_at IS NULL
+			RETURNING email`, userID, sealed).Scan(&email)
+		if errors.Is(err, sql.ErrNoRows) {
+			WriteJson(w, http.StatusConflict, map[string]string{"error": "two-factor authentication is already enabled"})
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		WriteJson(w, http.StatusOK, models.TOTPEnrollment{
+			Secret:     secret,
+			OTPAuthURI: auth.TOTPURI(totpIssuer, email, secret),
+		})
+	}
+}
+
+// ConfirmTOTPHandler turns 2FA on with a code from the enrolled secret and
+// returns the recovery codes, which are shown only this once.
+func ConfirmTOTPHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req models.MFACodeRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			WriteJson(w, http.StatusBadRequest, map[string]string{"error": "Invalid JSON"})
+			return
+		}
+
+		userID, ok := currentUserID(r)
+		if !ok {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if impersonating(r) {
+			WriteJson(w, http.StatusForbidden, map[string]string{"error": "not allowed while impersonating"})
+			return
+		}
+
+		ctx := r.Context()
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer tx.Rollback()
+
+		var (
+			sealed    *string
+			enabledAt *time.Time
+		)
+		if err := tx.QueryRowContext(ctx, `
+			SELECT totp_secret, totp_enabled_at FROM users WHERE id = $1
+			FOR UPDATE`, userID).Scan(&sealed, &enabledAt); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if enabledAt != nil {
+			WriteJson(w, http.StatusConflict, map[string]string{"error": "two-factor authentication is already enabled"})
+			return
+		}
+		if sealed == nil {
+			WriteJson(w, http.StatusConflict, map[string]string{"error": "enroll first"})
+			return
+		}
+		secret, err := openTOTPSecret(*sealed)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		step, ok := auth.ValidateTOTP(secret, req.Code, time.Now(), 0)
+		if !ok {
+			WriteJson(w, http.StatusBadRequest, map[string]string{"error": errInvalidMFA.Error()})
+			return
+		}
+
+		if _, err := tx.ExecContext(ctx, `
+			UPDATE users SET totp_enabled_at = NOW(), totp_last_step = $2, updated_at = NOW()
+			WHERE id = $1`, userID, step); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		codes, err := replaceRecoveryCodes(ctx, tx, userID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if err := tx.Commit(); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		WriteJson(w, http.StatusOK, models.RecoveryCodes{RecoveryCodes: codes})
+	}
+}
+
+// DisableTOTPHandler turns 2FA off. Having a token is not enough: the
+// password and a current code (or a recovery code) are required again.
+func DisableTOTPHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req models.MFADisableRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			WriteJson(w, http.StatusBadRequest, map[string]string{"error": "Invalid JSON"})
+			return
+		}
+		if strings.TrimSpace(req.Password) == "" {
+			WriteJson(w, http.StatusBadRequest, map[string]string{"error": "password is required"})
+			return
+		}
+
+		userID, ok := currentUserID(r)
+		if !ok {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if impersonating(r) {
+			WriteJson(w, http.StatusForbidden, map[string]string{"error": "not allowed while impersonating"})
+			return
+		}
+
+		ctx := r.Context()
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer tx.Rollback()
+
+		var passwordHash string
+		if err := tx.QueryRowContext(ctx, `SELECT password_hash FROM users WHERE id = $1`, userID).Scan(&passwordHash); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if err := auth.ComparePassword(passwordHash, strings.TrimSpace(req.Password)); err != nil {
+			WriteJson(w, http.StatusUnauthorized, map[string]string{"error": "invalid credentials"})
+			return
+		}
+		if !writeSecondFactorError(w, tx, checkSecondFactor(ctx, tx, userID, req.Code, req.RecoveryCode)) {
+			return
+		}
+
+		if _, err := tx.ExecContext(ctx, `
+			UPDATE users SET totp_secret = NULL, totp_enabled_at = NULL, totp_last_step = 0, updated_at = NOW()
+			WHERE id = $1`, userID); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if _, err := tx.ExecContext(ctx, `DELETE FROM mfa_recovery_codes WHERE user_id = $1`, userID); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if err := tx.Commit(); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// RegenerateRecoveryCodesHandler replaces all recovery codes after checking
+// a current TOTP code.
+func RegenerateRecoveryCodesHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req models.MFACodeRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			WriteJson(w, http.StatusBadRequest, map[string]string{"error": "Invalid JSON"})
+			return
+		}
+		if strings.TrimSpace(req.Code) == "" {
+			WriteJson(w, http.StatusBadRequest, map[string]string{"error": "code is required"})
+			return
+		}
+
+		userID, ok := currentUserID(r)
+		if !ok {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if impersonating(r) {
+			WriteJson(w, http.StatusForbidden, map[string]string{"error": "not allowed while impersonating"})
+			return
+		}
+
+		ctx := r.Context()
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer tx.Rollback()
+
+		if !writeSecondFactorError(w, tx, checkSecondFactor(ctx, tx, userID, req.Code, "")) {
+			return
+		}
+		codes, err := replaceRecoveryCodes(ctx, tx, userID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if err := tx.Commit(); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		WriteJson(w, http.StatusOK, models.RecoveryCodes{RecoveryCodes: codes})
+	}
+}
+
+// writeSecondFactorError answers for a failed checkSecondFactor and
+// reports whether err was nil. For a wrong code it commits tx first, so
+// the failure counts towards the lockout.
+func writeSecondFactorError(w http.ResponseWriter, tx *sql.Tx, err error) bool {
+	if errors.Is(err, errInvalidMFA) {
+		if commitErr := tx.Commit(); commitErr != nil {
+			err = commitErr
+		}
+	}
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, errMFANotEnabled):
+		WriteJson(w, http.StatusConflict, map[string]string{"error": err.Error()})
+	case errors.Is(err, errMFALocked):
+		WriteJson(w, http.StatusTooManyRequests, map[string]string{"error": err.Error()})
+	case errors.Is(err, errInvalidMFA):
+		WriteJson(w, http.StatusUnauthorized, map[string]string{"error": err.Error()})
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+	return false
+}
@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"database/sql"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"gotasker/internal/auth"
+)
+
+// testTOTPCode computes the current RFC 6238 code for a base32 secret.
+func testTOTPCode(t *testing.T, secret string) string {
+	t.Helper()
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(auth.TOTPStep(time.Now())))
+	m := hmac.New(sha1.New, key)
+	m.Write(msg[:])
+	sum := m.Sum(nil)
+	offset := sum[len(sum)-1] & 0x0f
+	return fmt.Sprintf("%06d", (binary.BigEndian.Uint32(sum[offset:offset+4])&0x7fffffff)%1000000)
+}
+
+// enableTestTOTP turns 2FA on for userID and returns the secret.
+func enableTestTOTP(t *testing.T, db *sql.DB, userID int64) string {
+	t.Helper()
+	secret, err := auth.NewTOTPSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sealed, err := sealTOTPSecret(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`
+		UPDATE users SET totp_secret = $2, totp_enabled_at = NOW() WHERE id = $1`, userID, sealed); err != nil {
+		t.Fatal(err)
+	}
+	return secret
+}
+
+func TestMFALockoutSpansChallenges(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	userID := createTestUser(t, db, "mfa@example.com")
+	secret := enableTestTOTP(t, db, userID)
+
+	valid := testTOTPCode(t, secret)
+	wrong := "000000"
+	if wrong == valid {
+		wrong = "111111"
+	}
+
+	loginMFA := func(token, code string) int {
+		body := fmt.Sprintf(`{"mfa_token":%q,"code":%q}`, token, code)
+		rec := httptest.NewRecorder()
+		LoginMFAHandler(db)(rec, httptest.NewRequest(http.MethodPost, "/login/mfa", strings.NewReader(body)))
+		return rec.Code
+	}
+	challenge := func() string {
+		c, err := startMFAChallenge(ctx, db, userID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return c.MFAToken
+	}
+
+	// A fresh challenge per batch of attempts does not reset the count.
+	var token string
+	for i := 0; i < maxMFAFailures; i++ {
+		if i%maxMFAAttempts == 0 {
+			token = challenge()
+		}
+		if code := loginMFA(token, wrong); code != http.StatusUnauthorized {
+			t.Fatalf("attempt %d: status %d, want 401", i+1, code)
+		}
+	}
+
+	// Locked: even the right code is refused, on login and in settings.
+	if code := loginMFA(challenge(), valid); code != http.StatusTooManyRequests {
+		t.Fatalf("locked login: status %d, want 429", code)
+	}
+	req := httptest.NewRequest(http.MethodPost, "/mfa/recovery-codes", strings.NewReader(`{"code":"`+valid+`"}`))
+	rec := httptest.NewRecorder()
+	RegenerateRecoveryCodesHandler(db)(rec, asUser(req, userID))
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("locked regenerate: status %d, want 429", rec.Code)
+	}
+
+	// Once the lock runs out a right code works and clears the count.
+	if _, err := db.Exec(`UPDATE users SET mfa_locked_until = NOW() - INTERVAL '1 second' WHERE id = $1`, userID); err != nil {
+		t.Fatal(err)
+	}
+	if code := loginMFA(challenge(), valid); code != http.StatusOK {
+		t.Fatalf("after lock: status %d, want 200", code)
+	}
+	var failures int
+	if err := db.QueryRow(`SELECT mfa_failed_attempts FROM users WHERE id = $1`, userID).Scan(&failures); err != nil {
+		t.Fatal(err)
+	}
+	if failures != 0 {
+		t.Errorf("mfa_failed_attempts %d after success, want 0", failures)
+	}
+
+	// Wrong codes on the settings routes count too.
+	req = httptest.NewRequest(http.MethodPost, "/mfa/recovery-codes", strings.NewReader(`{"code":"`+wrong+`"}`))
+	rec = httptest.NewRecorder()
+	RegenerateRecoveryCodesHandler(db)(rec, asUser(req, userID))
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("wrong regenerate: status %d, want 401", rec.Code)
+	}
+	if err := db.QueryRow(`SELECT mfa_failed_attempts FROM users WHERE id = $1`, userID).Scan(&failures); err != nil {
+		t.Fatal(err)
+	}
+	if failures != 1 {
+		t.Errorf("mfa_failed_attempts %d, want 1", failures)
+	}
+}
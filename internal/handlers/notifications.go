@@ -0,0 +1,220 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"gotasker/internal/models"
+	"gotasker/internal/notifications"
+
+	"github.com/go-chi/chi"
+)
+
+// parseNotificationTypes reads ?type=mention,reminder. An empty result means
+// no filter; it is never nil so it binds as an empty array, not NULL.
+func parseNotificationTypes(r *http.Request) ([]string, error) {
+	types := []string{}
+	for _, v := range r.URL.Query()["type"] {
+		for _, t := range strings.Split(v, ",") {
+			t = strings.TrimSpace(t)
+			if t == "" {
+				continue
+			}
+			if !containsString(notifications.Types, t) {
+				return nil, errors.New("unknown notification type " + strconv.Quote(t))
+			}
+			types = append(types, t)
+		}
+	}
+	return types, nil
+}
+
+// GetNotificationsHandler lists the user's notifications, newest first.
+// ?type= filters by type (comma-separated or repeated), ?unread=true hides
+// read ones; limit/offset paginate as on /tasksdb.
+func GetNotificationsHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+
+		types, err := parseNotificationTypes(r)
+		if err != nil {
+			WriteJson(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+
+		unreadOnly := false
+		if v := strings.TrimSpace(query.Get("unread")); v != "" {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				WriteJson(w, http.StatusBadRequest, map[string]string{"error": "invalid unread param"})
+				return
+			}
+			unreadOnly = b
+		}
+
+		limit := 20
+		if v := strings.TrimSpace(query.Get("limit")); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n <= 0 || n > 100 {
+				WriteJson(w, http.StatusBadRequest, map[string]string{"error": "invalid limit"})
+				return
+			}
+			limit = n
+		}
+		offset := 0
+		if v := strings.TrimSpace(query.Get("offset")); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				WriteJson(w, http.StatusBadRequest, map[string]string{"error": "invalid offset"})
+				return
+			}
+			offset = n
+		}
+
+		userID, ok := currentUserID(r)
+		if !ok {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		rows, err := db.QueryContext(r.Context(), `
+			SELECT id, type, task_id, actor_id, title, body, data, read_at, created_at
+			FROM notifications
+			WHERE user_id = $1
+				AND (cardinality($2::text[]) = 0 OR type = ANY($2))
+				AND (NOT $3 OR read_at IS NULL)
+			ORDER BY created_at DESC, id DESC
+			LIMIT $4 OFFSET $5`, userID, types, unreadOnly, limit, offset)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer rows.Close()
+
+		list := make([]models.Notification, 0)
+		for rows.Next() {
+			var n models.Notification
+			var data []byte
+			if err := rows.Scan(&n.ID, &n.Type, &n.TaskID, &n.ActorID, &n.Title, &n.Body, &data, &n.ReadAt, &n.CreatedAt); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			n.Data = data
+			list = append(list, n)
+		}
+		if err := rows.Err(); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		WriteJson(w, http.StatusOK, list)
+	}
+}
+
+// GetUnreadNotificationsHandler returns unread counts, in total and per type.
+func GetUnreadNotificationsHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := currentUserID(r)
+		if !ok {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		rows, err := db.QueryContext(r.Context(), `
+			SELECT type, COUNT(*)
+			FROM notifications
+			WHERE user_id = $1 AND read_at IS NULL
+			GROUP BY type`, userID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer rows.Close()
+
+		unread := models.UnreadNotifications{ByType: map[string]int{}}
+		for _, t := range notifications.Types {
+			unread.ByType[t] = 0
+		}
+		for rows.Next() {
+			var (
+				t string
+				n int
+			)
+			if err := rows.Scan(&t, &n); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			unread.ByType[t] = n
+			unread.Total += n
+		}
+		if err := rows.Err(); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		WriteJson(w, http.StatusOK, unread)
+	}
+}
+
+func MarkNotificationReadHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+		if err != nil {
+			WriteJson(w, http.StatusBadRequest, map[string]string{"error": "Invalid notification ID"})
+			return
+		}
+
+		userID, ok := currentUserID(r)
+		if !ok {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		res, err := db.ExecContext(r.Context(), `
+			UPDATE notifications SET read_at = COALESCE(read_at, NOW())
+			WHERE id = $1 AND user_id = $2`, id, userID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			WriteJson(w, http.StatusNotFound, map[string]string{"error": "Notification not found"})
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// MarkAllNotificationsReadHandler marks every unread notification as read,
+// optionally only those matching ?type=.
+func MarkAllNotificationsReadHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		types, err := parseNotificationTypes(r)
+		if err != nil {
+			WriteJson(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+
+		userID, ok := currentUserID(r)
+		if !ok {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		res, err := db.ExecContext(r.Context(), `
+			UPDATE notifications SET read_at = NOW()
+			WHERE user_id = $1 AND read_at IS NULL
+				AND (cardinality($2::text[]) = 0 OR type = ANY($2))`, userID, types)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		n, _ := res.RowsAffected()
+
+		WriteJson(w, http.StatusOK, map[string]int64{"marked": n})
+	}
+}
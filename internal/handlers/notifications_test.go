@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"gotasker/internal/models"
+	"gotasker/internal/notifications"
+)
+
+func TestParseNotificationTypes(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/notifications?type=mention,+reminder&type=share&type=", nil)
+	got, err := parseNotificationTypes(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"mention", "reminder", "share"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	got, err = parseNotificationTypes(httptest.NewRequest(http.MethodGet, "/notifications", nil))
+	if err != nil || got == nil || len(got) != 0 {
+		t.Errorf("no filter: got %#v, %v; want an empty, non-nil slice", got, err)
+	}
+
+	if _, err := parseNotificationTypes(httptest.NewRequest(http.MethodGet, "/notifications?type=spam", nil)); err == nil {
+		t.Error("unknown type accepted")
+	}
+}
+
+func TestNotificationsBelongToTheirUser(t *testing.T) {
+	db := testDB(t)
+	alice := createTestUser(t, db, "alice@example.com")
+	bob := createTestUser(t, db, "bob@example.com")
+
+	if err := notifications.Notify(context.Background(), db,
+		models.Notification{UserID: alice, Type: notifications.TypeMention, Title: "one"},
+		models.Notification{UserID: alice, Type: notifications.TypeShare, Title: "two"},
+		models.Notification{UserID: bob, Type: notifications.TypeMention, Title: "bob's"},
+	); err != nil {
+		t.Fatal(err)
+	}
+
+	list := func(userID int64, query string) []models.Notification {
+		t.Helper()
+		rec := serve(GetNotificationsHandler(db), asUser(newJSONRequest(http.MethodGet, "/notifications"+query, ""), userID))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("list: status %d: %s", rec.Code, rec.Body)
+		}
+		var ns []models.Notification
+		if err := json.Unmarshal(rec.Body.Bytes(), &ns); err != nil {
+			t.Fatal(err)
+		}
+		return ns
+	}
+
+	ns := list(alice, "")
+	if len(ns) != 2 {
+		t.Fatalf("alice sees %d notifications, want 2", len(ns))
+	}
+	bobs := list(bob, "")
+	if len(bobs) != 1 {
+		t.Fatalf("bob sees %d notifications, want 1", len(bobs))
+	}
+
+	// Marking someone else's notification read looks like it doesn't exist.
+	rec := serve(MarkNotificationReadHandler(db), withTaskID(asUser(newJSONRequest(http.MethodPost, "/", ""), alice), bobs[0].ID))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("mark bob's as alice: status %d, want 404", rec.Code)
+	}
+	if got := list(bob, "?unread=true"); len(got) != 1 {
+		t.Errorf("bob's notification was marked read by alice")
+	}
+
+	rec = serve(MarkNotificationReadHandler(db), withTaskID(asUser(newJSONRequest(http.MethodPost, "/", ""), alice), ns[0].ID))
+	if rec.Code != http.StatusNoContent {
+		t.Errorf("mark own: status %d: %s", rec.Code, rec.Body)
+	}
+
+	rec = serve(GetUnreadNotificationsHandler(db), asUser(newJSONRequest(http.MethodGet, "/", ""), alice))
+	var unread models.UnreadNotifications
+	if err := json.Unmarshal(rec.Body.Bytes(), &unread); err != nil {
+		t.Fatal(err)
+	}
+	if unread.Total != 1 || unread.ByType[notifications.TypeReminder] != 0 {
+		t.Errorf("unread %+v, want one in total and every type listed", unread)
+	}
+
+	rec = serve(MarkAllNotificationsReadHandler(db), asUser(newJSONRequest(http.MethodPost, "/", ""), alice))
+	if rec.Code != http.StatusOK || rec.Body.String() != `{"marked":1}`+"\n" {
+		t.Errorf("mark all: status %d: %s", rec.Code, rec.Body)
+	}
+	if got := list(bob, "?unread=true"); len(got) != 1 {
+		t.Errorf("alice's mark all reached bob's notifications")
+	}
+}
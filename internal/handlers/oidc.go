@@ -0,0 +1,354 @@
+package handlers
+
+import (
+	"context"
+	"crypto/subtle"
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"gotasker/internal/auth"
+	"gotasker/internal/models"
+	"gotasker/internal/oidc"
+)
+
+const (
+	// oidcStateTTL is how long a user has to finish signing in at the provider.
+	oidcStateTTL = 10 * time.Minute
+	// oidcStateCookie holds the hash of the pending state in the browser
+	// that started the sign-in. The callback accepts only that state, so
+	// nobody can get a victim's browser to finish their login or link.
+	oidcStateCookie = "gotasker_oidc_state"
+)
+
+var (
+	errIdentityTaken = errors.New("this identity is linked to another account")
+	// errLinkRequired is returned when the provider's email matches an
+	// account we cannot link automatically.
+	errLinkRequired     = errors.New("an account with this email already exists; log in and link the provider with POST /auth/oidc/link")
+	errInvalidOIDCEmail = errors.New("the provider did not return a usable email address")
+	errOIDCStateBinding = errors.New("this sign-in was started in another browser; start it again here")
+)
+
+func oidcDisabled(w http.ResponseWriter, client *oidc.Client) bool {
+	if client == nil {
+		WriteJson(w, http.StatusNotFound, map[string]string{"error": "OIDC login is not configured"})
+		return true
+	}
+	return false
+}
+
+// beginOIDC stores a fresh state, nonce and PKCE verifier, binds the state
+// to the browser with oidcStateCookie and returns the provider URL to send
+// the user to. linkUserID is set when the callback should link the
+// identity to that user instead of logging in.
+func beginOIDC(w http.ResponseWriter, r *http.Request, db *sql.DB, client *oidc.Client, linkUserID *int64, loginHint string) (string, error) {
+	ctx := r.Context()
+	state, err := oidc.RandomString(32)
+	if err != nil {
+		return "", err
+	}
+	nonce, err := oidc.RandomString(32)
+	if err != nil {
+		return "", err
+	}
+	verifier, err := oidc.RandomString(32)
+	if err != nil {
+		return "", err
+	}
+
+	// Abandoned attempts are cleaned up here rather than by a job.
+	if _, err := db.ExecContext(ctx, `
+		DELETE FROM oidc_login_states WHERE expires_at < NOW()`); err != nil {
+		return "", err
+	}
+	if _, err := db.ExecContext(ctx, `
+		INSERT INTO oidc_login_states (state_hash, nonce, code_verifier, link_user_id, expires_at)
+		VALUES ($1, $2, $3, $4, NOW() + $5 * INTERVAL '1 second')`,
+		hashToken(state), nonce, verifier, linkUserID, int64(oidcStateTTL/time.Second)); err != nil {
+		return "", err
+	}
+	u, err := client.AuthCodeURL(ctx, state, nonce, verifier, loginHint)
+	if err != nil {
+		return "", err
+	}
+	setOIDCStateCookie(w, client, hashToken(state), oidcStateTTL)
+	return u, nil
+}
+
+// setOIDCStateCookie scopes the cookie to the callback path; it is Secure
+// whenever the callback is served over HTTPS. An empty value with a zero
+// ttl deletes it.
+func setOIDCStateCookie(w http.ResponseWriter, client *oidc.Client, value string, ttl time.Duration) {
+	path, secure := "/", false
+	if u, err := url.Parse(client.RedirectURL()); err == nil {
+		if u.Path != "" {
+			path = u.Path
+		}
+		secure = u.Scheme == "https"
+	}
+	maxAge := int(ttl / time.Second)
+	if maxAge == 0 {
+		maxAge = -1
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcStateCookie,
+		Value:    value,
+		Path:     path,
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		Secure:   secure,
+		// Lax still sends it on the provider's top-level redirect back.
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// checkOIDCStateCookie reports whether the request comes from the browser
+// that started the sign-in for state.
+func checkOIDCStateCookie(r *http.Request, state string) bool {
+	c, err := r.Cookie(oidcStateCookie)
+	if err != nil {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(c.Value), []byte(hashToken(state))) == 1
+}
+
+// OIDCLoginHandler redirects to the provider. ?login_hint is passed on.
+func OIDCLoginHandler(db *sql.DB, client *oidc.Client) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if oidcDisabled(w, client) {
+			return
+		}
+		u, err := beginOIDC(w, r, db, client, nil, strings.TrimSpace(r.URL.Query().Get("login_hint")))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		http.Redirect(w, r, u, http.StatusFound)
+	}
+}
+
+// OIDCLinkHandler starts linking a provider identity to the current user.
+// It returns the provider URL rather than redirecting, since the request
+// carries a bearer token and is not a browser navigation. The state cookie
+// comes with the response, so the URL only works in the browser that made
+// this request; a cross-origin caller must send it with credentials, or
+// the browser drops the cookie and the callback fails.
+func OIDCLinkHandler(db *sql.DB, client *oidc.Client) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if oidcDisabled(w, client) {
+			return
+		}
+		userID, ok := currentUserID(r)
+		if !ok {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if impersonating(r) {
+			WriteJson(w, http.StatusForbidden, map[string]string{"error": "not allowed while impersonating"})
+			return
+		}
+
+		u, err := beginOIDC(w, r, db, client, &userID, "")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		WriteJson(w, http.StatusOK, map[string]string{"url": u})
+	}
+}
+
+// OIDCCallbackHandler finishes a login or link started above. A login
+// resolves the user in this order: an identity already linked; an account
+// with the same email, linked only if both the provider and the account
+// have verified it; otherwise a new account, provisioned on the spot.
+func OIDCCallbackHandler(db *sql.DB, client *oidc.Client) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if oidcDisabled(w, client) {
+			return
+		}
+		q := r.URL.Query()
+		if e := q.Get("error"); e != "" {
+			msg := "provider returned " + e
+			if d := q.Get("error_description"); d != "" {
+				msg += ": " + d
+			}
+			WriteJson(w, http.StatusUnauthorized, map[string]string{"error": msg})
+			return
+		}
+		state, code := q.Get("state"), q.Get("code")
+		if state == "" || code == "" {
+			WriteJson(w, http.StatusBadRequest, map[string]string{"error": "code and state are required"})
+			return
+		}
+		if !checkOIDCStateCookie(r, state) {
+			WriteJson(w, http.StatusBadRequest, map[string]string{"error": errOIDCStateBinding.Error()})
+			return
+		}
+		setOIDCStateCookie(w, client, "", 0)
+
+		ctx := r.Context()
+		// Deleting the state makes it single-use.
+		var (
+			nonce, verifier string
+			linkUserID      *int64
+		)
+		err := db.QueryRowContext(ctx, `
+			DELETE FROM oidc_login_states
+			WHERE state_hash = $1 AND expires_at > NOW()
+			RETURNING nonce, code_verifier, link_user_id`, hashToken(state)).Scan(&nonce, &verifier, &linkUserID)
+		if errors.Is(err, sql.ErrNoRows) {
+			WriteJson(w, http.StatusBadRequest, map[string]string{"error": "invalid or expired state"})
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		claims, err := client.Exchange(ctx, code, verifier, nonce)
+		if err != nil {
+			log.Printf("oidc callback: %v", err)
+			WriteJson(w, http.StatusUnauthorized, map[string]string{"error": "sign-in with the provider failed"})
+			return
+		}
+
+		if linkUserID != nil {
+			if err := linkIdentity(ctx, db, *linkUserID, client.Issuer(), claims); err != nil {
+				if errors.Is(err, errIdentityTaken) {
+					WriteJson(w, http.StatusConflict, map[string]string{"error": err.Error()})
+					return
+				}
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			WriteJson(w, http.StatusOK, map[string]string{"status": "linked"})
+			return
+		}
+
+		userID, err := resolveOIDCUser(ctx, db, client.Issuer(), claims)
+		if err != nil {
+			switch {
+			case errors.Is(err, errLinkRequired), errors.Is(err, errIdentityTaken):
+				WriteJson(w, http.StatusConflict, map[string]string{"error": err.Error()})
+			case errors.Is(err, errInvalidOIDCEmail):
+				WriteJson(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			default:
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+			}
+			return
+		}
+
+		var user models.LoginAuth
+		if err := db.QueryRowContext(ctx, `
+			SELECT id, email, role, disabled_at IS NOT NULL, password_reset_required_at IS NOT NULL,
+				email_verified_at IS NOT NULL, totp_enabled_at IS NOT NULL
+			FROM users
+			WHERE id = $1`, userID).Scan(&user.ID, &user.Email, &user.Role, &user.Disabled, &user.ResetRequired,
+			&user.EmailVerified, &user.MFAEnabled); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		finishLogin(w, r, db, user)
+	}
+}
+
+// linkIdentity links the identity to userID. Linking it again to the same
+// user is a no-op; the no-op update only makes RETURNING see the owner.
+func linkIdentity(ctx context.Context, q dbtx, userID int64, issuer string, claims *oidc.Claims) error {
+	var owner int64
+	err := q.QueryRowContext(ctx, `
+		INSERT INTO user_identities (user_id, issuer, subject, email)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (issuer, subject) DO UPDATE SET email = user_identities.email
+		RETURNING user_id`,
+		userID, issuer, claims.Subject, strings.ToLower(claims.Email)).Scan(&owner)
+	if err != nil {
+		return err
+	}
+	if owner != userID {
+		return errIdentityTaken
+	}
+	return nil
+}
+
+// resolveOIDCUser returns the local user for a verified ID token, linking
+// or provisioning as described on OIDCCallbackHandler.
+func resolveOIDCUser(ctx context.Context, db *sql.DB, issuer string, claims *oidc.Claims) (int64, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var userID int64
+	err = tx.QueryRowContext(ctx, `
+		SELECT user_id FROM user_identities WHERE issuer = $1 AND subject = $2`,
+		issuer, claims.Subject).Scan(&userID)
+	if err == nil {
+		return userID, tx.Commit()
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return 0, err
+	}
+
+	email := strings.TrimSpace(strings.ToLower(claims.Email))
+	if !validEmail(email) {
+		return 0, errInvalidOIDCEmail
+	}
+
+	var localVerified bool
+	err = tx.QueryRowContext(ctx, `
+		SELECT id, email_verified_at IS NOT NULL FROM users WHERE email = $1
+		FOR UPDATE`, email).Scan(&userID, &localVerified)
+	switch {
+	case err == nil:
+		// Linking on an unverified address on either side would let
+		// whoever controls that side take over the account.
+		if !bool(claims.EmailVerified) || !localVerified {
+			return 0, errLinkRequired
+		}
+	case errors.Is(err, sql.ErrNoRows):
+		userID, err = provisionOIDCUser(ctx, tx, email, bool(claims.EmailVerified))
+		if err != nil {
+			return 0, err
+		}
+	default:
+		return 0, err
+	}
+
+	if err := linkIdentity(ctx, tx, userID, issuer, claims); err != nil {
+		return 0, err
+	}
+	return userID, tx.Commit()
+}
+
+// provisionOIDCUser creates an account for a first-time provider login.
+// Its password is random; /password/forgot can set a real one later.
+func provisionOIDCUser(ctx context.Context, tx *sql.Tx, email string, verified bool) (int64, error) {
+	random, err := newOpaqueToken()
+	if err != nil {
+		return 0, err
+	}
+	hash, err := auth.HashPassword(random)
+	if err != nil {
+		return 0, err
+	}
+
+	var userID int64
+	if err := tx.QueryRowContext(ctx, `
+		INSERT INTO users (email, password_hash, email_verified_at)
+		VALUES ($1, $2, CASE WHEN $3 THEN NOW() END)
+		RETURNING id`, email, hash, verified).Scan(&userID); err != nil {
+		return 0, err
+	}
+	if _, err := ensurePersonalWorkspace(ctx, tx, userID); err != nil {
+		return 0, err
+	}
+	return userID, nil
+}
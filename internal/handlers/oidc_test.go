@@ -0,0 +1,364 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+
+	"gotasker/internal/middleware"
+	"gotasker/internal/models"
+	"gotasker/internal/oidc"
+	"gotasker/internal/oidc/oidctest"
+
+	"github.com/go-chi/chi"
+)
+
+// oidcEnv is the API's OIDC routes in front of a mock provider.
+type oidcEnv struct {
+	db       *sql.DB
+	provider *oidctest.Provider
+	api      *httptest.Server
+}
+
+func newOIDCEnv(t *testing.T) *oidcEnv {
+	t.Helper()
+	e := &oidcEnv{db: testDB(t)}
+
+	providerSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		e.provider.Handler().ServeHTTP(w, r)
+	}))
+	t.Cleanup(providerSrv.Close)
+	var err error
+	if e.provider, err = oidctest.NewProvider(providerSrv.URL); err != nil {
+		t.Fatal(err)
+	}
+
+	router := chi.NewRouter()
+	router.Use(middleware.CORS())
+	e.api = httptest.NewServer(router)
+	t.Cleanup(e.api.Close)
+
+	client, err := oidc.NewClient(oidc.Config{
+		Issuer:      providerSrv.URL,
+		ClientID:    "gotasker",
+		RedirectURL: e.api.URL + "/auth/oidc/callback",
+	}, providerSrv.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+	router.Get("/auth/oidc/login", OIDCLoginHandler(e.db, client))
+	router.Get("/auth/oidc/callback", OIDCCallbackHandler(e.db, client))
+	// Stands in for JWTMiddleware: X-Test-User is the logged-in user.
+	router.Post("/auth/oidc/link", func(w http.ResponseWriter, r *http.Request) {
+		userID, err := strconv.ParseInt(r.Header.Get("X-Test-User"), 10, 64)
+		if err != nil {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		OIDCLinkHandler(e.db, client)(w, asUser(r, userID))
+	})
+	return e
+}
+
+// newBrowser returns a client with its own cookies that does not follow
+// redirects, so tests can step through the flow.
+func newBrowser(t *testing.T) *http.Client {
+	t.Helper()
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &http.Client{
+		Jar: jar,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+func do(t *testing.T, b *http.Client, req *http.Request) (*http.Response, []byte) {
+	t.Helper()
+	resp, err := b.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp, body
+}
+
+func get(t *testing.T, b *http.Client, u string) (*http.Response, []byte) {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return do(t, b, req)
+}
+
+// xhr sends req the way the web app's fetch does from its origin, with
+// credentials when withCredentials is set. The browser keeps the cookies
+// of a cross-origin response only for a credentialed request that CORS
+// allowed; b's jar gets them under the same rule.
+func xhr(t *testing.T, b *http.Client, req *http.Request, withCredentials bool) (*http.Response, []byte) {
+	t.Helper()
+	req.Header.Set("Origin", middleware.FrontendOrigin)
+	if withCredentials {
+		for _, c := range b.Jar.Cookies(req.URL) {
+			req.AddCookie(c)
+		}
+	}
+	resp, body := do(t, &http.Client{}, req)
+	if withCredentials &&
+		resp.Header.Get("Access-Control-Allow-Origin") == middleware.FrontendOrigin &&
+		resp.Header.Get("Access-Control-Allow-Credentials") == "true" {
+		b.Jar.SetCookies(req.URL, resp.Cookies())
+	}
+	return resp, body
+}
+
+// redirect returns where resp sends the browser.
+func redirect(t *testing.T, resp *http.Response, body []byte) *url.URL {
+	t.Helper()
+	if resp.StatusCode != http.StatusFound {
+		t.Fatalf("status %d, want a redirect: %s", resp.StatusCode, body)
+	}
+	u, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u
+}
+
+// authorize signs in at the provider URL and returns the callback URL it
+// redirects to. emailVerified=false makes the provider report the email
+// as unverified.
+func (e *oidcEnv) authorize(t *testing.T, b *http.Client, providerURL *url.URL, emailVerified bool) string {
+	t.Helper()
+	if !emailVerified {
+		q := providerURL.Query()
+		q.Set("email_verified", "false")
+		providerURL.RawQuery = q.Encode()
+	}
+	resp, body := get(t, b, providerURL.String())
+	return redirect(t, resp, body).String()
+}
+
+// startLogin starts a login in b and returns the callback URL.
+func (e *oidcEnv) startLogin(t *testing.T, b *http.Client, email string, emailVerified bool) string {
+	t.Helper()
+	resp, body := get(t, b, e.api.URL+"/auth/oidc/login?login_hint="+url.QueryEscape(email))
+	return e.authorize(t, b, redirect(t, resp, body), emailVerified)
+}
+
+func (e *oidcEnv) login(t *testing.T, email string, emailVerified bool) (int, []byte) {
+	t.Helper()
+	b := newBrowser(t)
+	resp, body := get(t, b, e.startLogin(t, b, email, emailVerified))
+	return resp.StatusCode, body
+}
+
+func (e *oidcEnv) identityOwner(t *testing.T, email string) int64 {
+	t.Helper()
+	var userID int64
+	err := e.db.QueryRow(`
+		SELECT user_id FROM user_identities WHERE subject = $1`, "mock|"+email).Scan(&userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return userID
+}
+
+func TestOIDCLoginProvisionsUser(t *testing.T) {
+	e := newOIDCEnv(t)
+
+	code, body := e.login(t, "new@example.com", true)
+	if code != http.StatusOK {
+		t.Fatalf("first login: status %d: %s", code, body)
+	}
+	var tokens models.TokenResponse
+	if err := json.Unmarshal(body, &tokens); err != nil || tokens.Token == "" || tokens.RefreshToken == "" {
+		t.Fatalf("first login: %s (%v)", body, err)
+	}
+
+	var (
+		userID   int64
+		verified bool
+	)
+	if err := e.db.QueryRow(`
+		SELECT id, email_verified_at IS NOT NULL FROM users WHERE email = 'new@example.com'`).Scan(&userID, &verified); err != nil {
+		t.Fatal(err)
+	}
+	if !verified {
+		t.Error("provisioned user is not verified although the provider verified the email")
+	}
+	if owner := e.identityOwner(t, "new@example.com"); owner != userID {
+		t.Errorf("identity linked to %d, want %d", owner, userID)
+	}
+	var workspaces int
+	if err := e.db.QueryRow(`
+		SELECT COUNT(*) FROM workspaces WHERE personal AND created_by = $1`, userID).Scan(&workspaces); err != nil {
+		t.Fatal(err)
+	}
+	if workspaces != 1 {
+		t.Errorf("%d personal workspaces, want 1", workspaces)
+	}
+
+	// The next login finds the linked identity.
+	if code, body := e.login(t, "new@example.com", true); code != http.StatusOK {
+		t.Fatalf("second login: status %d: %s", code, body)
+	}
+	var users int
+	if err := e.db.QueryRow(`SELECT COUNT(*) FROM users`).Scan(&users); err != nil {
+		t.Fatal(err)
+	}
+	if users != 1 {
+		t.Errorf("%d users after two logins, want 1", users)
+	}
+}
+
+func TestOIDCStateIsBoundAndSingleUse(t *testing.T) {
+	e := newOIDCEnv(t)
+	b := newBrowser(t)
+	callback := e.startLogin(t, b, "carol@example.com", true)
+
+	// Another browser cannot finish this sign-in.
+	if resp, body := get(t, newBrowser(t), callback); resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("other browser: status %d: %s", resp.StatusCode, body)
+	}
+	// That did not use the state up.
+	if resp, body := get(t, b, callback); resp.StatusCode != http.StatusOK {
+		t.Fatalf("own browser: status %d: %s", resp.StatusCode, body)
+	}
+
+	// Replaying the callback fails even with the cookie put back.
+	u, err := url.Parse(callback)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, err := http.NewRequest(http.MethodGet, callback, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.AddCookie(&http.Cookie{Name: oidcStateCookie, Value: hashToken(u.Query().Get("state"))})
+	if resp, body := do(t, &http.Client{}, req); resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("replay: status %d: %s", resp.StatusCode, body)
+	}
+}
+
+func TestOIDCCallbackChecksPKCEAndNonce(t *testing.T) {
+	e := newOIDCEnv(t)
+	for _, column := range []string{"code_verifier", "nonce"} {
+		b := newBrowser(t)
+		callback := e.startLogin(t, b, "dave@example.com", true)
+		if _, err := e.db.Exec(`UPDATE oidc_login_states SET ` + column + ` = 'tampered'`); err != nil {
+			t.Fatal(err)
+		}
+		if resp, body := get(t, b, callback); resp.StatusCode != http.StatusUnauthorized {
+			t.Errorf("wrong %s: status %d: %s", column, resp.StatusCode, body)
+		}
+	}
+	if owner := e.identityOwner(t, "dave@example.com"); owner != 0 {
+		t.Errorf("identity linked to %d after failed sign-ins", owner)
+	}
+}
+
+func TestOIDCLoginLinksOnlyVerifiedEmails(t *testing.T) {
+	e := newOIDCEnv(t)
+	verified := createTestUser(t, e.db, "verified@example.com")
+	var unverified int64
+	if err := e.db.QueryRow(`
+		INSERT INTO users (email, password_hash) VALUES ('unverified@example.com', 'not-a-hash')
+		RETURNING id`).Scan(&unverified); err != nil {
+		t.Fatal(err)
+	}
+
+	// Provider unverified, account verified.
+	if code, body := e.login(t, "verified@example.com", false); code != http.StatusConflict {
+		t.Errorf("unverified at the provider: status %d: %s", code, body)
+	}
+	// Provider verified, account unverified.
+	if code, body := e.login(t, "unverified@example.com", true); code != http.StatusConflict {
+		t.Errorf("unverified account: status %d: %s", code, body)
+	}
+	if owner := e.identityOwner(t, "verified@example.com"); owner != 0 {
+		t.Errorf("identity linked to %d without verified emails", owner)
+	}
+	if owner := e.identityOwner(t, "unverified@example.com"); owner != 0 {
+		t.Errorf("identity linked to %d without verified emails", owner)
+	}
+
+	// Both verified.
+	if code, body := e.login(t, "verified@example.com", true); code != http.StatusOK {
+		t.Fatalf("both verified: status %d: %s", code, body)
+	}
+	if owner := e.identityOwner(t, "verified@example.com"); owner != verified {
+		t.Errorf("identity linked to %d, want %d", owner, verified)
+	}
+}
+
+func TestOIDCLinkIsBoundToTheBrowser(t *testing.T) {
+	e := newOIDCEnv(t)
+	userID := createTestUser(t, e.db, "erin@example.com")
+
+	// startLink makes the web app's call in b and returns the provider URL.
+	startLink := func(b *http.Client, withCredentials bool) *url.URL {
+		req, err := http.NewRequest(http.MethodPost, e.api.URL+"/auth/oidc/link", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("X-Test-User", strconv.FormatInt(userID, 10))
+		resp, body := xhr(t, b, req, withCredentials)
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("link: status %d: %s", resp.StatusCode, body)
+		}
+		var out map[string]string
+		if err := json.Unmarshal(body, &out); err != nil {
+			t.Fatal(err)
+		}
+		u, err := url.Parse(out["url"])
+		if err != nil {
+			t.Fatal(err)
+		}
+		return u
+	}
+
+	// Someone handing their link URL to a victim: the victim's browser
+	// has no state cookie, so nothing is linked.
+	victim := newBrowser(t)
+	callback := e.authorize(t, victim, startLink(newBrowser(t), true), true)
+	if resp, body := get(t, victim, callback); resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("link finished in another browser: status %d: %s", resp.StatusCode, body)
+	}
+
+	// Without credentials the browser drops the cookie, and so fails.
+	b := newBrowser(t)
+	callback = e.authorize(t, b, startLink(b, false), true)
+	if resp, body := get(t, b, callback); resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("link without credentials: status %d: %s", resp.StatusCode, body)
+	}
+	if owner := e.identityOwner(t, e.provider.DefaultEmail); owner != 0 {
+		t.Fatalf("identity linked to %d without the state cookie", owner)
+	}
+
+	callback = e.authorize(t, b, startLink(b, true), true)
+	if resp, body := get(t, b, callback); resp.StatusCode != http.StatusOK {
+		t.Fatalf("link: status %d: %s", resp.StatusCode, body)
+	}
+	if owner := e.identityOwner(t, e.provider.DefaultEmail); owner != userID {
+		t.Errorf("identity linked to %d, want %d", owner, userID)
+	}
+}
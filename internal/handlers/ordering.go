@@ -0,0 +1,196 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"gotasker/internal/models"
+	"gotasker/internal/ordering"
+	"gotasker/internal/workspace"
+
+	"github.com/go-chi/chi"
+	"github.com/redis/go-redis/v9"
+)
+
+var errAnchorNotFound = errors.New("anchor task not found")
+
+// MoveTaskHandlerDB places a task directly before and/or after anchor tasks
+// in the manual order of its workspace. Only the moved row is written; if its
+// new position key gets long, the workspace's list is queued for background
+// rebalancing. Anchors must be in the same workspace.
+func MoveTaskHandlerDB(db *sql.DB, rdb *redis.Client, rebalancer *ordering.Rebalancer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			WriteJson(w, http.StatusBadRequest, map[string]string{"error": "Invalid task ID"})
+			return
+		}
+
+		var req models.MoveTaskRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			WriteJson(w, http.StatusBadRequest, map[string]string{"error": "Invalid Json"})
+			return
+		}
+		if req.Before == nil && req.After == nil {
+			WriteJson(w, http.StatusBadRequest, map[string]string{"error": "before or after is required"})
+			return
+		}
+		if (req.Before != nil && *req.Before == id) || (req.After != nil && *req.After == id) {
+			WriteJson(w, http.StatusBadRequest, map[string]string{"error": "a task cannot be its own anchor"})
+			return
+		}
+
+		userID, ok := currentUserID(r)
+		if !ok {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		ctx := r.Context()
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer tx.Rollback()
+
+		workspaceID, role, err := taskAccess(ctx, tx, userID, id)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if !workspace.CanRead(role) {
+			WriteJson(w, http.StatusNotFound, map[string]string{"error": "Task not found"})
+			return
+		}
+		if !workspace.CanWrite(role) {
+			WriteJson(w, http.StatusForbidden, map[string]string{"error": "insufficient role"})
+			return
+		}
+
+		// Tasks created before manual ordering existed have no position yet;
+		// give the whole list keys once so anchors are comparable.
+		var unpositioned bool
+		if err := tx.QueryRowContext(ctx, `
+			SELECT EXISTS (SELECT 1 FROM tasks WHERE workspace_id = $1 AND position IS NULL)`,
+			workspaceID).Scan(&unpositioned); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if unpositioned {
+			if err := ordering.Rebalance(ctx, tx, workspaceID); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+
+		key, err := positionBetweenAnchors(ctx, tx, userID, workspaceID, id, req)
+		if errors.Is(err, ordering.ErrInvalidKey) {
+			// Concurrent inserts can leave equal keys; compact and retry once.
+			if err = ordering.Rebalance(ctx, tx, workspaceID); err == nil {
+				key, err = positionBetweenAnchors(ctx, tx, userID, workspaceID, id, req)
+			}
+		}
+		if errors.Is(err, errAnchorNotFound) {
+			WriteJson(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		if errors.Is(err, ordering.ErrInvalidKey) {
+			WriteJson(w, http.StatusBadRequest, map[string]string{"error": "after must come before before"})
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		t, err := scanTask(tx.QueryRowContext(ctx, `
+			UPDATE tasks SET position = $1, updated_at = NOW()
+			WHERE id = $2
+			RETURNING `+taskColumns, key, id))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		if err := tx.Commit(); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		invalidateTaskViewers(ctx, db, rdb, id)
+
+		if len(key) > ordering.MaxKeyLength {
+			rebalancer.Enqueue(workspaceID)
+		}
+
+		WriteJson(w, http.StatusOK, t)
+	}
+}
+
+// positionBetweenAnchors works out the new key for task id. With only
+// "before", the key goes between the anchor and its predecessor; with only
+// "after", between the anchor and its successor; with both, between them.
+// Anchors must be in the workspace and visible to the user. The moved task
+// itself is ignored when looking for neighbours.
+func positionBetweenAnchors(ctx context.Context, tx *sql.Tx, userID, workspaceID int64, id int, req models.MoveTaskRequest) (string, error) {
+	anchor := func(anchorID int) (string, error) {
+		var pos string
+		err := tx.QueryRowContext(ctx, `
+			SELECT position FROM tasks WHERE id = $1 AND workspace_id = $2`,
+			anchorID, workspaceID).Scan(&pos)
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", errAnchorNotFound
+		}
+		if err != nil {
+			return "", err
+		}
+		// A share of a single task does not reveal the rest of the list.
+		visible, err := canViewTask(ctx, tx, userID, anchorID)
+		if err != nil {
+			return "", err
+		}
+		if !visible {
+			return "", errAnchorNotFound
+		}
+		return pos, nil
+	}
+
+	var lower, upper string
+	var err error
+	if req.After != nil {
+		if lower, err = anchor(*req.After); err != nil {
+			return "", err
+		}
+	}
+	if req.Before != nil {
+		if upper, err = anchor(*req.Before); err != nil {
+			return "", err
+		}
+	}
+
+	var neighbour sql.NullString
+	switch {
+	case req.After == nil:
+		err = tx.QueryRowContext(ctx, `
+			SELECT MAX(position) FROM tasks
+			WHERE workspace_id = $1 AND id <> $2 AND position < $3`,
+			workspaceID, id, upper).Scan(&neighbour)
+		lower = neighbour.String
+	case req.Before == nil:
+		err = tx.QueryRowContext(ctx, `
+			SELECT MIN(position) FROM tasks
+			WHERE workspace_id = $1 AND id <> $2 AND position > $3`,
+			workspaceID, id, lower).Scan(&neighbour)
+		upper = neighbour.String
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return ordering.KeyBetween(lower, upper)
+}
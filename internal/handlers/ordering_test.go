@@ -0,0 +1,149 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"gotasker/internal/models"
+	"gotasker/internal/ordering"
+)
+
+// sharedWorkspace creates a workspace owned by ownerID with the given
+// members and returns its ID.
+func sharedWorkspace(t *testing.T, db *sql.DB, ownerID int64, members map[int64]string) int64 {
+	t.Helper()
+	ctx := context.Background()
+	var id int64
+	if err := db.QueryRowContext(ctx, `
+		INSERT INTO workspaces (name, personal, created_by)
+		VALUES ('Team', FALSE, $1)
+		RETURNING id`, ownerID).Scan(&id); err != nil {
+		t.Fatal(err)
+	}
+	members[ownerID] = "owner"
+	for userID, role := range members {
+		if _, err := db.ExecContext(ctx, `
+			INSERT INTO workspace_members (workspace_id, user_id, role)
+			VALUES ($1, $2, $3)`, id, userID, role); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return id
+}
+
+func workspaceOrder(t *testing.T, db *sql.DB, workspaceID int64) []int64 {
+	t.Helper()
+	ids, err := queryIDs(context.Background(), db, `
+		SELECT id FROM tasks WHERE workspace_id = $1 ORDER BY position`, workspaceID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ids
+}
+
+func TestMoveTaskInSharedWorkspace(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	owner := createTestUser(t, db, "owner@example.com")
+	member := createTestUser(t, db, "member@example.com")
+	viewer := createTestUser(t, db, "viewer@example.com")
+	wsID := sharedWorkspace(t, db, owner, map[int64]string{member: "member", viewer: "viewer"})
+
+	// Tasks by different creators share one order.
+	var a, b, c int64
+	for _, tt := range []struct {
+		creator int64
+		id      *int64
+	}{{owner, &a}, {member, &b}, {owner, &c}} {
+		if err := db.QueryRowContext(ctx, `
+			INSERT INTO tasks (user_id, workspace_id, title) VALUES ($1, $2, 'task')
+			RETURNING id`, tt.creator, wsID).Scan(tt.id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// A task in the member's personal list must not take part.
+	insertTestTask(t, db, member, "Private", nil, []string{})
+
+	move := func(userID, id int64, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/tasksdb/move", strings.NewReader(body))
+		rec := httptest.NewRecorder()
+		MoveTaskHandlerDB(db, nil, ordering.NewRebalancer(db, nil))(rec, withTaskID(asUser(req, userID), id))
+		return rec
+	}
+
+	// The member moves a task the owner created to the end.
+	rec := move(member, a, `{"after":`+strconv.FormatInt(c, 10)+`}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("member move: status %d: %s", rec.Code, rec.Body)
+	}
+	var moved models.Task
+	if err := json.Unmarshal(rec.Body.Bytes(), &moved); err != nil {
+		t.Fatal(err)
+	}
+	if int64(moved.ID) != a {
+		t.Errorf("moved task %d, want %d", moved.ID, a)
+	}
+	if got := workspaceOrder(t, db, wsID); len(got) != 3 || got[2] != a {
+		t.Errorf("order %v, want %d last", got, a)
+	}
+
+	if rec := move(viewer, b, `{"after":`+strconv.FormatInt(a, 10)+`}`); rec.Code != http.StatusForbidden {
+		t.Errorf("viewer move: status %d, want 403", rec.Code)
+	}
+	outsider := createTestUser(t, db, "outsider@example.com")
+	if rec := move(outsider, b, `{"after":`+strconv.FormatInt(a, 10)+`}`); rec.Code != http.StatusNotFound {
+		t.Errorf("outsider move: status %d, want 404", rec.Code)
+	}
+	own := insertTestTask(t, db, owner, "Own", nil, []string{})
+	if rec := move(owner, b, `{"after":`+strconv.FormatInt(own, 10)+`}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("anchor in another workspace: status %d, want 400", rec.Code)
+	}
+}
+
+func TestArchiveInSharedWorkspace(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	owner := createTestUser(t, db, "owner@example.com")
+	member := createTestUser(t, db, "member@example.com")
+	viewer := createTestUser(t, db, "viewer@example.com")
+	wsID := sharedWorkspace(t, db, owner, map[int64]string{member: "member", viewer: "viewer"})
+
+	var id int64
+	if err := db.QueryRowContext(ctx, `
+		INSERT INTO tasks (user_id, workspace_id, title) VALUES ($1, $2, 'Launch')
+		RETURNING id`, owner, wsID).Scan(&id); err != nil {
+		t.Fatal(err)
+	}
+
+	archive := func(userID int64) int {
+		req := httptest.NewRequest(http.MethodPost, "/tasksdb/archive", nil)
+		rec := httptest.NewRecorder()
+		ArchiveTaskHandlerDB(db, nil)(rec, withTaskID(asUser(req, userID), id))
+		return rec.Code
+	}
+	if code := archive(viewer); code != http.StatusForbidden {
+		t.Errorf("viewer: status %d, want 403", code)
+	}
+	if code := archive(member); code != http.StatusOK {
+		t.Fatalf("member: status %d, want 200", code)
+	}
+
+	// Every member sees the archived task in the workspace's archive.
+	req := httptest.NewRequest(http.MethodGet, "/archive", nil)
+	req.Header.Set("X-Workspace-ID", strconv.FormatInt(wsID, 10))
+	rec := httptest.NewRecorder()
+	GetArchiveHandler(db)(rec, asUser(req, viewer))
+	var tasks []models.Task
+	if err := json.Unmarshal(rec.Body.Bytes(), &tasks); err != nil {
+		t.Fatalf("status %d: %v", rec.Code, err)
+	}
+	if len(tasks) != 1 || int64(tasks[0].ID) != id {
+		t.Errorf("archive list %+v, want task %d", tasks, id)
+	}
+}
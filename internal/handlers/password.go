@@ -0,0 +1,190 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"gotasker/internal/auth"
+	"gotasker/internal/mail"
+	"gotasker/internal/middleware"
+	"gotasker/internal/models"
+)
+
+const (
+	passwordResetTTL = time.Hour
+	// passwordResetInterval is the least time between two reset emails to
+	// the same user.
+	passwordResetInterval = time.Minute
+	passwordResetTimeout  = 30 * time.Second
+)
+
+// sendPasswordReset issues a reset token for the user with email, if there
+// is one that may log in, and mails it. It runs after the response was
+// written, so neither its result nor its duration tells the caller whether
+// the address is registered.
+func sendPasswordReset(ctx context.Context, db *sql.DB, mailer mail.Mailer, email string) error {
+	var (
+		userID int64
+		recent bool
+	)
+	err := db.QueryRowContext(ctx, `
+		SELECT u.id, EXISTS (
+			SELECT 1 FROM password_resets pr
+			WHERE pr.user_id = u.id AND pr.created_at > NOW() - $2 * INTERVAL '1 second'
+		)
+		FROM users u
+		WHERE u.email = $1 AND u.disabled_at IS NULL`,
+		email, int64(passwordResetInterval/time.Second)).Scan(&userID, &recent)
+	if errors.Is(err, sql.ErrNoRows) || recent {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	token, err := newOpaqueToken()
+	if err != nil {
+		return err
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var expiresAt time.Time
+	if err := tx.QueryRowContext(ctx, `
+		INSERT INTO password_resets (user_id, token_hash, expires_at)
+		VALUES ($1, $2, NOW() + $3 * INTERVAL '1 second')
+		RETURNING expires_at`, userID, hashToken(token), int64(passwordResetTTL/time.Second)).Scan(&expiresAt); err != nil {
+		return err
+	}
+
+	// Send before committing so a failed delivery leaves no token behind.
+	if err := mailer.Send(ctx, mail.Message{
+		To:      email,
+		Subject: "Reset your GoTasker password",
+		Body: fmt.Sprintf("Someone asked to reset the password of your GoTasker account.\n\n"+
+			"Choose a new password at %s/password/reset?token=%s or send this token to POST /password/reset:\n%s\n\n"+
+			"The link works once and expires on %s. If this wasn't you, ignore this email.\n",
+			middleware.FrontendOrigin, url.QueryEscape(token), token,
+			expiresAt.UTC().Format(time.RFC1123)),
+	}); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// ForgotPasswordHandler starts a password reset. It always answers 202 so
+// it cannot be used to find out which emails are registered.
+func ForgotPasswordHandler(db *sql.DB, mailer mail.Mailer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req models.ForgotPasswordRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			WriteJson(w, http.StatusBadRequest, map[string]string{"error": "Invalid JSON"})
+			return
+		}
+		req.Email = strings.TrimSpace(strings.ToLower(req.Email))
+		if req.Email == "" {
+			WriteJson(w, http.StatusBadRequest, map[string]string{"error": "email is required"})
+			return
+		}
+
+		go func() {
+			ctx, cancel := context.WithTimeout(context.Background(), passwordResetTimeout)
+			defer cancel()
+			if err := sendPasswordReset(ctx, db, mailer, req.Email); err != nil {
+				log.Printf("password reset for %s: %v", req.Email, err)
+			}
+		}()
+
+		WriteJson(w, http.StatusAccepted, map[string]string{
+			"status": "if the email is registered, a reset link has been sent",
+		})
+	}
+}
+
+// ResetPasswordHandler sets a new password with a reset token. The token
+// and any other outstanding ones are used up, a forced reset is cleared,
+// and every session and access token of the user is revoked.
+func ResetPasswordHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req models.ResetPasswordRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			WriteJson(w, http.StatusBadRequest, map[string]string{"error": "Invalid JSON"})
+			return
+		}
+		req.Token = strings.TrimSpace(req.Token)
+		req.Password = strings.TrimSpace(req.Password)
+		if req.Token == "" || req.Password == "" {
+			WriteJson(w, http.StatusBadRequest, map[string]string{"error": "token and password are required"})
+			return
+		}
+		if len(req.Password) < 8 {
+			WriteJson(w, http.StatusBadRequest, map[string]string{"error": "password must be at least 8 characters"})
+			return
+		}
+
+		ctx := r.Context()
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer tx.Rollback()
+
+		var userID int64
+		err = tx.QueryRowContext(ctx, `
+			SELECT user_id FROM password_resets
+			WHERE token_hash = $1 AND used_at IS NULL AND expires_at > NOW()
+			FOR UPDATE`, hashToken(req.Token)).Scan(&userID)
+		if errors.Is(err, sql.ErrNoRows) {
+			WriteJson(w, http.StatusBadRequest, map[string]string{"error": "invalid or expired reset token"})
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		hashedPassword, err := auth.HashPassword(req.Password)
+		if err != nil {
+			WriteJson(w, http.StatusInternalServerError, map[string]string{"error": "failed to secure password"})
+			return
+		}
+
+		if _, err := tx.ExecContext(ctx, `
+			UPDATE users
+			SET password_hash = $2, password_reset_required_at = NULL,
+				token_epoch = token_epoch + 1, updated_at = NOW()
+			WHERE id = $1`, userID, hashedPassword); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if _, err := tx.ExecContext(ctx, `
+			UPDATE password_resets SET used_at = NOW()
+			WHERE user_id = $1 AND used_at IS NULL`, userID); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if err := revokeUserSessions(ctx, tx, userID); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if err := tx.Commit(); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		WriteJson(w, http.StatusOK, map[string]string{"status": "password updated; please log in again"})
+	}
+}
@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"gotasker/internal/auth"
+	"gotasker/internal/middleware"
+)
+
+var resetLink = regexp.MustCompile(`/password/reset\?token=(\S+)`)
+
+func TestResetPasswordValidation(t *testing.T) {
+	// These are refused before the database is touched.
+	for name, body := range map[string]string{
+		"bad json":       `{`,
+		"no token":       `{"password":"long enough"}`,
+		"no password":    `{"token":"t"}`,
+		"short password": `{"token":"t","password":" short  "}`,
+	} {
+		rec := serve(ResetPasswordHandler(nil), newJSONRequest(http.MethodPost, "/password/reset", body))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status %d, want 400", name, rec.Code)
+		}
+	}
+	rec := serve(ForgotPasswordHandler(nil, nil), newJSONRequest(http.MethodPost, "/password/forgot", `{"email":"  "}`))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("forgot without email: status %d, want 400", rec.Code)
+	}
+}
+
+func TestPasswordReset(t *testing.T) {
+	db := testDB(t)
+	userID := createTestUser(t, db, "forgetful@example.com")
+	tokens := loginTestUser(t, db, userID)
+	mw := auth.JWTMiddleware(db, nil)
+	mailer := &recordingMailer{}
+
+	forgot := func(email string) int {
+		return serve(ForgotPasswordHandler(db, mailer), newJSONRequest(http.MethodPost, "/password/forgot", `{"email":"`+email+`"}`)).Code
+	}
+	reset := func(token, password string) int {
+		return serve(ResetPasswordHandler(db), newJSONRequest(http.MethodPost, "/password/reset",
+			`{"token":"`+token+`","password":"`+password+`"}`)).Code
+	}
+
+	if code := forgot("nobody@example.com"); code != http.StatusAccepted {
+		t.Errorf("unknown email: status %d, want 202", code)
+	}
+	if code := forgot("Forgetful@example.com"); code != http.StatusAccepted {
+		t.Fatalf("registered email: status %d, want 202", code)
+	}
+	mailer.waitForMail(t, 1)
+	body := mailer.sent[0].Body
+	if !strings.Contains(body, middleware.FrontendOrigin+"/password/reset?token=") {
+		t.Errorf("reset email does not link to the web app:\n%s", body)
+	}
+	m := resetLink.FindStringSubmatch(body)
+	if m == nil {
+		t.Fatalf("no reset link in:\n%s", body)
+	}
+	token, err := url.QueryUnescape(m[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A second request inside the interval sends nothing more.
+	forgot("forgetful@example.com")
+	time.Sleep(100 * time.Millisecond)
+	if n := mailer.count(); n != 1 {
+		t.Errorf("sent %d emails, want 1", n)
+	}
+
+	if code := reset("wrong-token", "a new password"); code != http.StatusBadRequest {
+		t.Errorf("wrong token: status %d, want 400", code)
+	}
+	if code := reset(token, "a new password"); code != http.StatusOK {
+		t.Fatalf("reset: status %d", code)
+	}
+	var hash string
+	if err := db.QueryRow(`SELECT password_hash FROM users WHERE id = $1`, userID).Scan(&hash); err != nil {
+		t.Fatal(err)
+	}
+	if err := auth.ComparePassword(hash, "a new password"); err != nil {
+		t.Errorf("password not changed: %v", err)
+	}
+	if code := reset(token, "another password"); code != http.StatusBadRequest {
+		t.Errorf("token reused: status %d, want 400", code)
+	}
+
+	// Devices signed in with the old password are signed out.
+	if code := serveAuthed(mw, okHandler, withBearer(http.MethodGet, "/settings", tokens.Token)); code != http.StatusUnauthorized {
+		t.Errorf("old access token: status %d, want 401", code)
+	}
+	if code, _ := refreshTokens(t, db, tokens.RefreshToken); code != http.StatusUnauthorized {
+		t.Errorf("old refresh token: status %d, want 401", code)
+	}
+}
+
+func TestPasswordResetTokenExpires(t *testing.T) {
+	db := testDB(t)
+	userID := createTestUser(t, db, "late@example.com")
+	if _, err := db.Exec(`
+		INSERT INTO password_resets (user_id, token_hash, expires_at)
+		VALUES ($1, $2, NOW() - INTERVAL '1 minute')`, userID, hashToken("stale")); err != nil {
+		t.Fatal(err)
+	}
+	rec := serve(ResetPasswordHandler(db), newJSONRequest(http.MethodPost, "/password/reset", `{"token":"stale","password":"a new password"}`))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expired token: status %d, want 400", rec.Code)
+	}
+}
@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"gotasker/internal/ai"
+	"gotasker/internal/models"
+	"gotasker/internal/quickadd"
+	cache "gotasker/internal/redis"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// QuickAddTaskHandlerDB parses a free-text line such as
+// "Pay rent every 1st at 9am #home !high tomorrow" and creates the task.
+// The parsed fields are returned alongside the task so the client can show
+// what was understood; with "preview": true nothing is stored.
+func QuickAddTaskHandlerDB(db *sql.DB, rdb *redis.Client, aiWorker *ai.Worker) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req models.QuickAddRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			WriteJson(w, http.StatusBadRequest, map[string]string{"error": "Invalid Json"})
+			return
+		}
+
+		req.Text = strings.TrimSpace(req.Text)
+		if req.Text == "" {
+			WriteJson(w, http.StatusBadRequest, map[string]string{"error": "text is required"})
+			return
+		}
+
+		loc := time.UTC
+		if req.Timezone != "" {
+			l, err := time.LoadLocation(req.Timezone)
+			if err != nil {
+				WriteJson(w, http.StatusBadRequest, map[string]string{"error": "invalid timezone"})
+				return
+			}
+			loc = l
+		}
+
+		userID, ok := currentUserID(r)
+		if !ok {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		parsed := quickadd.Parse(req.Text, time.Now(), loc)
+		if parsed.Title == "" {
+			WriteJson(w, http.StatusBadRequest, map[string]any{
+				"error":  "Title is empty",
+				"parsed": parsed,
+			})
+			return
+		}
+
+		if req.Preview {
+			WriteJson(w, http.StatusOK, models.QuickAddResponse{Parsed: parsed})
+			return
+		}
+
+		ctx := r.Context()
+		task := models.Task{
+			Title:    parsed.Title,
+			Priority: parsed.Priority,
+			DueAt:    parsed.DueAt,
+			Tags:     parsed.Tags,
+		}
+		if parsed.Recurrence != "" {
+			task.Recurrence = &parsed.Recurrence
+		}
+		summary := aiWorker.AnalyzeTask(ctx, task, 0, 0)
+		task.AiSummary = &summary
+
+		created, err := insertTask(ctx, db, userID, task)
+		if err != nil {
+			WriteJson(w, http.StatusInternalServerError, map[string]string{
+				"error": "failed to create task",
+			})
+			return
+		}
+
+		if err := cache.DeletTaks(ctx, rdb, userID); err != nil {
+			log.Printf("Redis DEL failed: %v", err)
+		}
+
+		WriteJson(w, http.StatusCreated, models.QuickAddResponse{Parsed: parsed, Task: &created})
+	}
+}
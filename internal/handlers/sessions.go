@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"database/sql"
+	"net"
+	"net/http"
+	"strconv"
+
+	"gotasker/internal/models"
+
+	"github.com/go-chi/chi"
+)
+
+const maxUserAgentLen = 512
+
+// remoteIP returns the client address of r without its port.
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// userAgent returns the request's User-Agent, cut to a sane length.
+func userAgent(r *http.Request) string {
+	ua := r.UserAgent()
+	if len(ua) > maxUserAgentLen {
+		ua = ua[:maxUserAgentLen]
+	}
+	return ua
+}
+
+// ListSessionsHandler lists the user's active sessions, most recently seen
+// first. A session is active until it is revoked or its refresh token
+// expires unused.
+func ListSessionsHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := currentUserID(r)
+		if !ok {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		var currentID int64
+		if claims, ok := currentClaims(r); ok {
+			currentID = claims.SessionID
+		}
+
+		rows, err := db.QueryContext(r.Context(), `
+			SELECT s.id, s.user_agent, s.ip, s.created_at, s.last_seen_at
+			FROM sessions s
+			WHERE s.user_id = $1 AND s.revoked_at IS NULL
+			  AND EXISTS (
+				SELECT 1 FROM refresh_tokens rt
+				WHERE rt.session_id = s.id AND rt.used_at IS NULL
+				  AND rt.revoked_at IS NULL AND rt.expires_at > NOW()
+			  )
+			ORDER BY s.last_seen_at DESC, s.id DESC`, userID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer rows.Close()
+
+		sessions := make([]models.Session, 0)
+		for rows.Next() {
+			var s models.Session
+			if err := rows.Scan(&s.ID, &s.UserAgent, &s.IP, &s.CreatedAt, &s.LastSeenAt); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			s.Current = s.ID == currentID
+			sessions = append(sessions, s)
+		}
+		if err := rows.Err(); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		WriteJson(w, http.StatusOK, sessions)
+	}
+}
+
+// RevokeSessionHandler signs one of the user's devices out. Its refresh
+// token stops working at once and so do its access tokens.
+func RevokeSessionHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+		if err != nil {
+			WriteJson(w, http.StatusBadRequest, map[string]string{"error": "Invalid session ID"})
+			return
+		}
+
+		userID, ok := currentUserID(r)
+		if !ok {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		ctx := r.Context()
+		var owned bool
+		err = db.QueryRowContext(ctx, `
+			SELECT EXISTS (SELECT 1 FROM sessions WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL)`,
+			id, userID).Scan(&owned)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if !owned {
+			WriteJson(w, http.StatusNotFound, map[string]string{"error": "Session not found"})
+			return
+		}
+
+		if err := revokeSession(ctx, db, id); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"gotasker/internal/auth"
+	"gotasker/internal/models"
+)
+
+func TestRemoteIPAndUserAgent(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.RemoteAddr = "[2001:db8::1]:4321"
+	if got := remoteIP(r); got != "2001:db8::1" {
+		t.Errorf("remoteIP %q", got)
+	}
+	r.RemoteAddr = "pipe"
+	if got := remoteIP(r); got != "pipe" {
+		t.Errorf("remoteIP without port %q", got)
+	}
+
+	r.Header.Set("User-Agent", strings.Repeat("x", maxUserAgentLen+10))
+	if got := userAgent(r); len(got) != maxUserAgentLen {
+		t.Errorf("user agent of %d bytes, want %d", len(got), maxUserAgentLen)
+	}
+}
+
+func TestSessionsBelongToTheirUser(t *testing.T) {
+	db := testDB(t)
+	alice := createTestUser(t, db, "alice@example.com")
+	bob := createTestUser(t, db, "bob@example.com")
+	laptop := loginTestUser(t, db, alice)
+	phone := loginTestUser(t, db, alice)
+	bobs := loginTestUser(t, db, bob)
+	mw := auth.JWTMiddleware(db, nil)
+
+	list := func(token string) []models.Session {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		mw(ListSessionsHandler(db)).ServeHTTP(rec, withBearer(http.MethodGet, "/sessions", token))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("list: status %d: %s", rec.Code, rec.Body)
+		}
+		var sessions []models.Session
+		if err := json.Unmarshal(rec.Body.Bytes(), &sessions); err != nil {
+			t.Fatal(err)
+		}
+		return sessions
+	}
+	revoke := func(token string, id int64) int {
+		return serveAuthed(mw, RevokeSessionHandler(db), withTaskID(withBearer(http.MethodDelete, "/sessions/x", token), id))
+	}
+
+	sessions := list(laptop.Token)
+	if len(sessions) != 2 {
+		t.Fatalf("alice has %d sessions, want 2", len(sessions))
+	}
+	var current, other int64
+	for _, s := range sessions {
+		if s.Current {
+			current = s.ID
+		} else {
+			other = s.ID
+		}
+	}
+	if current == 0 || other == 0 {
+		t.Fatalf("sessions %+v, want exactly one marked current", sessions)
+	}
+	bobSessions := list(bobs.Token)
+	if len(bobSessions) != 1 {
+		t.Fatalf("bob has %d sessions, want 1", len(bobSessions))
+	}
+
+	// Someone else's session looks like it doesn't exist.
+	if code := revoke(laptop.Token, bobSessions[0].ID); code != http.StatusNotFound {
+		t.Errorf("revoke bob's session as alice: status %d, want 404", code)
+	}
+	if code := serveAuthed(mw, okHandler, withBearer(http.MethodGet, "/settings", bobs.Token)); code != http.StatusOK {
+		t.Errorf("bob signed out by alice: status %d", code)
+	}
+
+	// Revoking the phone from the laptop signs the phone out at once.
+	if code := revoke(laptop.Token, other); code != http.StatusNoContent {
+		t.Fatalf("revoke: status %d", code)
+	}
+	if code := serveAuthed(mw, okHandler, withBearer(http.MethodGet, "/settings", phone.Token)); code != http.StatusUnauthorized {
+		t.Errorf("revoked access token: status %d, want 401", code)
+	}
+	if code, _ := refreshTokens(t, db, phone.RefreshToken); code != http.StatusUnauthorized {
+		t.Errorf("revoked refresh token: status %d, want 401", code)
+	}
+	if code := revoke(laptop.Token, other); code != http.StatusNotFound {
+		t.Errorf("revoke twice: status %d, want 404", code)
+	}
+	if sessions := list(laptop.Token); len(sessions) != 1 || sessions[0].ID != current {
+		t.Errorf("sessions after revoke %+v, want only the laptop", sessions)
+	}
+}
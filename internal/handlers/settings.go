@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"gotasker/internal/models"
+)
+
+func GetSettingsHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := currentUserID(r)
+		if !ok {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		var settings models.UserSettings
+		err := db.QueryRowContext(r.Context(), `
+			SELECT archive_done_after_days FROM user_settings WHERE user_id = $1`,
+			userID).Scan(&settings.ArchiveDoneAfterDays)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		WriteJson(w, http.StatusOK, settings)
+	}
+}
+
+// UpdateSettingsHandler replaces the user's settings. Archiving itself is
+// left to the background job.
+func UpdateSettingsHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req models.UserSettings
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			WriteJson(w, http.StatusBadRequest, map[string]string{"error": "Invalid Json"})
+			return
+		}
+		if req.ArchiveDoneAfterDays != nil && (*req.ArchiveDoneAfterDays <= 0 || *req.ArchiveDoneAfterDays > 3650) {
+			WriteJson(w, http.StatusBadRequest, map[string]string{"error": "archive_done_after_days must be between 1 and 3650"})
+			return
+		}
+
+		userID, ok := currentUserID(r)
+		if !ok {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		if _, err := db.ExecContext(r.Context(), `
+			INSERT INTO user_settings (user_id, archive_done_after_days)
+			VALUES ($1, $2)
+			ON CONFLICT (user_id) DO UPDATE
+			SET archive_done_after_days = EXCLUDED.archive_done_after_days, updated_at = NOW()`,
+			userID, req.ArchiveDoneAfterDays); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		WriteJson(w, http.StatusOK, req)
+	}
+}
@@ -0,0 +1,290 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"gotasker/internal/models"
+	"gotasker/internal/notifications"
+	"gotasker/internal/workspace"
+
+	"github.com/go-chi/chi"
+	"github.com/redis/go-redis/v9"
+)
+
+const shareGrantSelect = `
+	SELECT g.id, CASE WHEN g.task_id IS NULL THEN 'list' ELSE 'task' END, g.task_id,
+		o.id, o.email, u.id, u.email, g.permission, g.created_at
+	FROM share_grants g
+	JOIN users o ON o.id = g.owner_id
+	JOIN users u ON u.id = g.grantee_id`
+
+func scanShareGrant(s rowScanner) (models.ShareGrant, error) {
+	var g models.ShareGrant
+	err := s.Scan(&g.ID, &g.Type, &g.TaskID, &g.Owner.ID, &g.Owner.Email,
+		&g.Grantee.ID, &g.Grantee.Email, &g.Permission, &g.CreatedAt)
+	return g, err
+}
+
+func loadShareGrant(ctx context.Context, q dbtx, id int64) (models.ShareGrant, error) {
+	return scanShareGrant(q.QueryRowContext(ctx, shareGrantSelect+` WHERE g.id = $1`, id))
+}
+
+// ListSharesHandler lists the shares the user granted and received.
+// ?direction=given or ?direction=received narrows it to one side.
+func ListSharesHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := currentUserID(r)
+		if !ok {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		where := "WHERE g.owner_id = $1 OR g.grantee_id = $1"
+		switch strings.TrimSpace(r.URL.Query().Get("direction")) {
+		case "":
+		case "given":
+			where = "WHERE g.owner_id = $1"
+		case "received":
+			where = "WHERE g.grantee_id = $1"
+		default:
+			WriteJson(w, http.StatusBadRequest, map[string]string{"error": "invalid direction"})
+			return
+		}
+
+		rows, err := db.QueryContext(r.Context(), shareGrantSelect+`
+			`+where+`
+			ORDER BY g.created_at DESC, g.id DESC`, userID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer rows.Close()
+
+		grants := make([]models.ShareGrant, 0)
+		for rows.Next() {
+			g, err := scanShareGrant(rows)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			grants = append(grants, g)
+		}
+		if err := rows.Err(); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		WriteJson(w, http.StatusOK, grants)
+	}
+}
+
+// CreateShareHandler shares the user's personal list, or a task they manage,
+// with another user. Sharing the same thing again changes the permission.
+func CreateShareHandler(db *sql.DB, rdb *redis.Client) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req models.ShareRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			WriteJson(w, http.StatusBadRequest, map[string]string{"error": "Invalid Json"})
+			return
+		}
+		req.Email = strings.TrimSpace(strings.ToLower(req.Email))
+		if req.Email == "" {
+			WriteJson(w, http.StatusBadRequest, map[string]string{"error": "email is required"})
+			return
+		}
+		if req.Permission == "" {
+			req.Permission = workspace.PermissionView
+		}
+		if !workspace.ValidPermission(req.Permission) {
+			WriteJson(w, http.StatusBadRequest, map[string]string{"error": "invalid permission"})
+			return
+		}
+		switch req.Type {
+		case models.ShareList:
+			if req.TaskID != nil {
+				WriteJson(w, http.StatusBadRequest, map[string]string{"error": "task_id is only allowed for task shares"})
+				return
+			}
+		case models.ShareTask:
+			if req.TaskID == nil {
+				WriteJson(w, http.StatusBadRequest, map[string]string{"error": "task_id is required"})
+				return
+			}
+		default:
+			WriteJson(w, http.StatusBadRequest, map[string]string{"error": "type must be list or task"})
+			return
+		}
+
+		userID, ok := currentUserID(r)
+		if !ok {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		ctx := r.Context()
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer tx.Rollback()
+
+		var granteeID int64
+		err = tx.QueryRowContext(ctx, `SELECT id FROM users WHERE email = $1`, req.Email).Scan(&granteeID)
+		if errors.Is(err, sql.ErrNoRows) {
+			WriteJson(w, http.StatusNotFound, map[string]string{"error": "user not found"})
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if granteeID == userID {
+			WriteJson(w, http.StatusBadRequest, map[string]string{"error": "cannot share with yourself"})
+			return
+		}
+
+		var (
+			id    int64
+			title string
+		)
+		if req.Type == models.ShareList {
+			workspaceID, err := ensurePersonalWorkspace(ctx, tx, userID)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			err = tx.QueryRowContext(ctx, `
+				INSERT INTO share_grants (owner_id, grantee_id, workspace_id, permission)
+				VALUES ($1, $2, $3, $4)
+				ON CONFLICT (workspace_id, grantee_id) WHERE workspace_id IS NOT NULL
+				DO UPDATE SET permission = EXCLUDED.permission
+				RETURNING id`, userID, granteeID, workspaceID, req.Permission).Scan(&id)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			title = "shared their task list with you"
+		} else {
+			_, role, err := taskAccess(ctx, tx, userID, *req.TaskID)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			if !workspace.CanRead(role) {
+				WriteJson(w, http.StatusNotFound, map[string]string{"error": "Task not found"})
+				return
+			}
+			if !workspace.CanManage(role) {
+				WriteJson(w, http.StatusForbidden, map[string]string{"error": "insufficient role"})
+				return
+			}
+			var taskTitle string
+			err = tx.QueryRowContext(ctx, `
+				WITH g AS (
+					INSERT INTO share_grants (owner_id, grantee_id, task_id, permission)
+					VALUES ($1, $2, $3, $4)
+					ON CONFLICT (task_id, grantee_id) WHERE task_id IS NOT NULL
+					DO UPDATE SET permission = EXCLUDED.permission
+					RETURNING id, task_id
+				)
+				SELECT g.id, t.title FROM g JOIN tasks t ON t.id = g.task_id`,
+				userID, granteeID, *req.TaskID, req.Permission).Scan(&id, &taskTitle)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			title = "shared " + strconv.Quote(taskTitle) + " with you"
+		}
+
+		g, err := loadShareGrant(ctx, tx, id)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		data, err := json.Marshal(map[string]any{"share_id": g.ID, "permission": g.Permission})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if err := notifications.Notify(ctx, tx, models.Notification{
+			UserID:  granteeID,
+			Type:    notifications.TypeShare,
+			TaskID:  g.TaskID,
+			ActorID: &userID,
+			Title:   g.Owner.Email + " " + title,
+			Data:    data,
+		}); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		if err := tx.Commit(); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		invalidateUsers(ctx, rdb, granteeID)
+
+		WriteJson(w, http.StatusCreated, g)
+	}
+}
+
+// DeleteShareHandler revokes a share. The user who granted it, its grantee
+// and anyone managing a shared task may revoke it.
+func DeleteShareHandler(db *sql.DB, rdb *redis.Client) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+		if err != nil {
+			WriteJson(w, http.StatusBadRequest, map[string]string{"error": "Invalid share ID"})
+			return
+		}
+
+		userID, ok := currentUserID(r)
+		if !ok {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		ctx := r.Context()
+		g, err := loadShareGrant(ctx, db, id)
+		if errors.Is(err, sql.ErrNoRows) {
+			WriteJson(w, http.StatusNotFound, map[string]string{"error": "Share not found"})
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		allowed := g.Owner.ID == userID || g.Grantee.ID == userID
+		if !allowed && g.TaskID != nil {
+			_, role, err := taskAccess(ctx, db, userID, *g.TaskID)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			allowed = workspace.CanManage(role)
+		}
+		if !allowed {
+			WriteJson(w, http.StatusNotFound, map[string]string{"error": "Share not found"})
+			return
+		}
+
+		if _, err := db.ExecContext(ctx, `DELETE FROM share_grants WHERE id = $1`, id); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		invalidateUsers(ctx, rdb, g.Grantee.ID)
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"testing"
+
+	"gotasker/internal/models"
+)
+
+func TestCreateShareValidation(t *testing.T) {
+	// These are refused before the database is touched.
+	for name, body := range map[string]string{
+		"no email":          `{"type":"list"}`,
+		"bad permission":    `{"type":"list","email":"a@example.com","permission":"admin"}`,
+		"bad type":          `{"type":"workspace","email":"a@example.com"}`,
+		"list with task_id": `{"type":"list","email":"a@example.com","task_id":1}`,
+		"task without id":   `{"type":"task","email":"a@example.com"}`,
+	} {
+		rec := serve(CreateShareHandler(nil, nil), newJSONRequest(http.MethodPost, "/shares", body))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status %d, want 400: %s", name, rec.Code, rec.Body)
+		}
+	}
+}
+
+func TestShareAccess(t *testing.T) {
+	db := testDB(t)
+	owner := createTestUser(t, db, "owner@example.com")
+	friend := createTestUser(t, db, "friend@example.com")
+	outsider := createTestUser(t, db, "outsider@example.com")
+	taskID := insertTestTask(t, db, owner, "Plan trip", nil, []string{})
+
+	share := func(userID int64, email string) (int, models.ShareGrant) {
+		t.Helper()
+		body := `{"type":"task","task_id":` + strconv.FormatInt(taskID, 10) + `,"email":"` + email + `"}`
+		rec := serve(CreateShareHandler(db, nil), asUser(newJSONRequest(http.MethodPost, "/shares", body), userID))
+		var g models.ShareGrant
+		if rec.Code == http.StatusCreated {
+			if err := json.Unmarshal(rec.Body.Bytes(), &g); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return rec.Code, g
+	}
+
+	if code, _ := share(owner, "owner@example.com"); code != http.StatusBadRequest {
+		t.Errorf("share with yourself: status %d, want 400", code)
+	}
+	if code, _ := share(owner, "nobody@example.com"); code != http.StatusNotFound {
+		t.Errorf("share with unknown user: status %d, want 404", code)
+	}
+	if code, _ := share(outsider, "friend@example.com"); code != http.StatusNotFound {
+		t.Errorf("share someone else's task: status %d, want 404", code)
+	}
+
+	code, g := share(owner, "friend@example.com")
+	if code != http.StatusCreated {
+		t.Fatalf("share: status %d", code)
+	}
+	visible, err := canViewTask(context.Background(), db, friend, int(taskID))
+	if err != nil || !visible {
+		t.Fatalf("grantee cannot see the shared task: %v, %v", visible, err)
+	}
+
+	// A view-only grantee can see the task but not pass it on.
+	if code, _ := share(friend, "outsider@example.com"); code != http.StatusForbidden {
+		t.Errorf("share as viewer: status %d, want 403", code)
+	}
+
+	rec := serve(ListSharesHandler(db), asUser(newJSONRequest(http.MethodGet, "/shares?direction=received", ""), friend))
+	var received []models.ShareGrant
+	if err := json.Unmarshal(rec.Body.Bytes(), &received); err != nil {
+		t.Fatal(err)
+	}
+	if len(received) != 1 || received[0].ID != g.ID {
+		t.Errorf("received %+v, want the one share", received)
+	}
+	rec = serve(ListSharesHandler(db), asUser(newJSONRequest(http.MethodGet, "/shares", ""), outsider))
+	if rec.Code != http.StatusOK || rec.Body.String() != "[]\n" {
+		t.Errorf("list as outsider: status %d: %s", rec.Code, rec.Body)
+	}
+
+	rec = serve(DeleteShareHandler(db, nil), withTaskID(asUser(newJSONRequest(http.MethodDelete, "/", ""), outsider), g.ID))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("delete as outsider: status %d, want 404", rec.Code)
+	}
+	// The grantee may give a share back.
+	rec = serve(DeleteShareHandler(db, nil), withTaskID(asUser(newJSONRequest(http.MethodDelete, "/", ""), friend), g.ID))
+	if rec.Code != http.StatusNoContent {
+		t.Errorf("delete as grantee: status %d: %s", rec.Code, rec.Body)
+	}
+	if visible, _ := canViewTask(context.Background(), db, friend, int(taskID)); visible {
+		t.Error("task still visible after the share was revoked")
+	}
+}
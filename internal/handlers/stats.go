@@ -0,0 +1,198 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"gotasker/internal/models"
+	cache "gotasker/internal/redis"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// maxStatsRange keeps the generated series to a sensible size.
+const maxStatsRange = 2 * 366 * 24 * time.Hour
+
+// GetStatsHandler returns throughput metrics for ?from= .. ?to= (same formats
+// as /reports/time, default the last 30 days), bucketed by ?bucket=day|week
+// in ?tz=. Results are cached per query until the user's tasks change.
+func GetStatsHandler(db *sql.DB, rdb *redis.Client) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+
+		bucket := strings.TrimSpace(query.Get("bucket"))
+		if bucket == "" {
+			bucket = "day"
+		}
+		if bucket != "day" && bucket != "week" {
+			WriteJson(w, http.StatusBadRequest, map[string]string{"error": "bucket must be day or week"})
+			return
+		}
+
+		oldest := 5
+		if v := strings.TrimSpace(query.Get("oldest")); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 || n > 50 {
+				WriteJson(w, http.StatusBadRequest, map[string]string{"error": "invalid oldest"})
+				return
+			}
+			oldest = n
+		}
+
+		loc, err := reportLocation(query.Get("tz"))
+		if err != nil {
+			WriteJson(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+
+		from, to, err := parseReportRange(query.Get("from"), query.Get("to"), loc, 30)
+		if err != nil {
+			WriteJson(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		if to.Sub(from) > maxStatsRange {
+			WriteJson(w, http.StatusBadRequest, map[string]string{"error": "range is too long"})
+			return
+		}
+
+		userID, ok := currentUserID(r)
+		if !ok {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		ctx := r.Context()
+		field := query.Encode()
+		if stats, found, err := cache.GetStats(ctx, rdb, userID, field); err != nil {
+			log.Printf("Redis HGET failed: %v", err)
+		} else if found {
+			WriteJson(w, http.StatusOK, stats)
+			return
+		}
+
+		stats, err := computeStats(ctx, db, userID, from, to, loc.String(), bucket, oldest)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		if err := cache.SetStats(ctx, rdb, userID, field, stats); err != nil {
+			log.Printf("Redis HSET failed: %v", err)
+		}
+
+		WriteJson(w, http.StatusOK, stats)
+	}
+}
+
+func computeStats(ctx context.Context, db *sql.DB, userID int64, from, to time.Time, tz, bucket string, oldest int) (models.Stats, error) {
+	stats := models.Stats{
+		From:       from,
+		To:         to,
+		Bucket:     bucket,
+		Series:     []models.StatsBucket{},
+		OldestOpen: []models.Task{},
+	}
+
+	rows, err := db.QueryContext(ctx, `
+		WITH created AS (
+			SELECT date_trunc($5, created_at AT TIME ZONE $4) AS start, COUNT(*) AS n
+			FROM tasks
+			WHERE user_id = $1 AND created_at >= $2 AND created_at < $3
+			GROUP BY 1
+		), completed AS (
+			SELECT date_trunc($5, completed_at AT TIME ZONE $4) AS start, COUNT(*) AS n
+			FROM tasks
+			WHERE user_id = $1 AND completed_at >= $2 AND completed_at < $3
+			GROUP BY 1
+		)
+		SELECT to_char(b.start, 'YYYY-MM-DD'), COALESCE(c.n, 0), COALESCE(d.n, 0)
+		FROM generate_series(
+			date_trunc($5, $2 AT TIME ZONE $4),
+			($3 AT TIME ZONE $4) - INTERVAL '1 microsecond',
+			('1 ' || $5)::interval
+		) AS b(start)
+		LEFT JOIN created c ON c.start = b.start
+		LEFT JOIN completed d ON d.start = b.start
+		ORDER BY b.start`, userID, from, to, tz, bucket)
+	if err != nil {
+		return stats, err
+	}
+	for rows.Next() {
+		var b models.StatsBucket
+		if err := rows.Scan(&b.Start, &b.Created, &b.Completed); err != nil {
+			rows.Close()
+			return stats, err
+		}
+		stats.CreatedTotal += b.Created
+		stats.CompletedTotal += b.Completed
+		stats.Series = append(stats.Series, b)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return stats, err
+	}
+
+	var median sql.NullFloat64
+	if err := db.QueryRowContext(ctx, `
+		SELECT percentile_cont(0.5) WITHIN GROUP (ORDER BY EXTRACT(EPOCH FROM (completed_at - created_at)))
+		FROM tasks
+		WHERE user_id = $1 AND completed_at >= $2 AND completed_at < $3`,
+		userID, from, to).Scan(&median); err != nil {
+		return stats, err
+	}
+	if median.Valid {
+		secs := int64(median.Float64)
+		stats.MedianTimeToCompleteSeconds = &secs
+	}
+
+	if err := db.QueryRowContext(ctx, `
+		SELECT COUNT(*) FROM tasks WHERE user_id = $1 AND done = FALSE`,
+		userID).Scan(&stats.OpenCount); err != nil {
+		return stats, err
+	}
+
+	rows, err = db.QueryContext(ctx, `
+		SELECT `+taskColumns+`
+		FROM tasks
+		WHERE user_id = $1 AND done = FALSE
+		ORDER BY created_at, id
+		LIMIT $2`, userID, oldest)
+	if err != nil {
+		return stats, err
+	}
+	for rows.Next() {
+		t, err := scanTask(rows)
+		if err != nil {
+			rows.Close()
+			return stats, err
+		}
+		stats.OldestOpen = append(stats.OldestOpen, t)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return stats, err
+	}
+
+	// Streaks are runs of consecutive local days with at least one
+	// completion; the current one may end today or yesterday.
+	err = db.QueryRowContext(ctx, `
+		WITH days AS (
+			SELECT DISTINCT (completed_at AT TIME ZONE $2)::date AS d
+			FROM tasks
+			WHERE user_id = $1 AND completed_at IS NOT NULL
+		), runs AS (
+			SELECT MAX(d) AS last_day, COUNT(*) AS len
+			FROM (SELECT d, d - (ROW_NUMBER() OVER (ORDER BY d))::int AS grp FROM days) islands
+			GROUP BY grp
+		)
+		SELECT
+			COALESCE(MAX(len) FILTER (WHERE last_day >= (NOW() AT TIME ZONE $2)::date - 1), 0),
+			COALESCE(MAX(len), 0)
+		FROM runs`, userID, tz).Scan(&stats.CurrentStreakDays, &stats.LongestStreakDays)
+	return stats, err
+}
@@ -1,33 +1,154 @@
 package handlers
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
 	"gotasker/internal/ai"
 	"gotasker/internal/auth"
+	"gotasker/internal/blob"
 	"gotasker/internal/models"
+	"gotasker/internal/ordering"
 	cache "gotasker/internal/redis"
+	"gotasker/internal/workflow"
+	"gotasker/internal/workspace"
 
 	"github.com/go-chi/chi"
+	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/redis/go-redis/v9"
 )
 
+// taskColumns is the column list scanTask expects, in order.
+const taskColumns = `id, workspace_id, parent_id, title, done, status, priority, due_at, completed_at, tags, recurrence, position, time_spent_seconds, archived_at, assignee_id, ai_summary, created_at, updated_at`
+
+// rowScanner is satisfied by *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// dbtx is satisfied by both *sql.DB and *sql.Tx.
+type dbtx interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// extraScanner lets scanTask read rows that select more columns after
+// taskColumns; the extra values land in extra.
+type extraScanner struct {
+	s     rowScanner
+	extra []any
+}
+
+func (e extraScanner) Scan(dest ...any) error {
+	return e.s.Scan(append(dest, e.extra...)...)
+}
+
+func scanWithExtra(s rowScanner, extra ...any) rowScanner {
+	return extraScanner{s: s, extra: extra}
+}
+
+func scanTask(s rowScanner) (models.Task, error) {
+	var t models.Task
+	err := s.Scan(
+		&t.ID,
+		&t.WorkspaceID,
+		&t.ParentID,
+		&t.Title,
+		&t.Done,
+		&t.Status,
+		&t.Priority,
+		&t.DueAt,
+		&t.CompletedAt,
+		pgtype.NewMap().SQLScanner(&t.Tags),
+		&t.Recurrence,
+		&t.Position,
+		&t.TimeSpentSeconds,
+		&t.ArchivedAt,
+		&t.AssigneeID,
+		&t.AiSummary,
+		&t.CreatedAt,
+		&t.UpdatedAt,
+	)
+	if t.Tags == nil {
+		t.Tags = []string{}
+	}
+	return t, err
+}
+
+// insertTask stores t, created by userID, and returns the stored row. A
+// zero WorkspaceID means the user's personal workspace. A zero CreatedAt
+// falls back to NOW(); a done task without CompletedAt is stamped with
+// NOW(), and a task already past due gets no reminder. An empty Status is
+// derived from Done using the workspace's workflow, otherwise Done is
+// derived from Status.
+func insertTask(ctx context.Context, q dbtx, userID int64, t models.Task) (models.Task, error) {
+	if t.WorkspaceID == 0 {
+		id, err := ensurePersonalWorkspace(ctx, q, userID)
+		if err != nil {
+			return models.Task{}, err
+		}
+		t.WorkspaceID = id
+	}
+
+	wf, err := workspaceWorkflow(ctx, q, t.WorkspaceID)
+	if err != nil {
+		return models.Task{}, err
+	}
+	if t.Status == "" {
+		t.Status = workflow.StatusForDone(wf, t.Done)
+	} else if _, ok := workflow.Find(wf, t.Status); !ok {
+		return models.Task{}, errUnknownStatus
+	}
+	t.Done = workflow.IsDone(wf, t.Status)
+
+	// New tasks go to the top of the manual order.
+	var first sql.NullString
+	if err := q.QueryRowContext(ctx, `
+		SELECT MIN(position) FROM tasks WHERE workspace_id = $1`, t.WorkspaceID).Scan(&first); err != nil {
+		return models.Task{}, err
+	}
+	position, err := ordering.KeyBetween("", first.String)
+	if err != nil {
+		return models.Task{}, err
+	}
+
+	var createdAt *time.Time
+	if !t.CreatedAt.IsZero() {
+		createdAt = &t.CreatedAt
+	}
+	return scanTask(q.QueryRowContext(ctx, `
+		INSERT INTO tasks (user_id, title, done, priority, due_at, completed_at, tags, ai_summary, created_at, parent_id, recurrence, status, position, reminded_at, workspace_id)
+		VALUES ($1, $2, $3, $4, $5,
+			CASE WHEN $3 THEN COALESCE($6, NOW()) END,
+			$7, $8, COALESCE($9, NOW()), $10, $11, $12, $13,
+			CASE WHEN $5 < NOW() THEN NOW() END, $14)
+		RETURNING `+taskColumns,
+		userID, t.Title, t.Done, t.Priority, t.DueAt, t.CompletedAt,
+		models.NormalizeTags(t.Tags), t.AiSummary, createdAt, t.ParentID, t.Recurrence, t.Status, position, t.WorkspaceID))
+}
+
 func GetTasksHandlerDB(db *sql.DB, rdb *redis.Client) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 
 		// ── 1. Read query params ─────────────────────────────
 		q := strings.TrimSpace(r.URL.Query().Get("q"))
 		doneParam := strings.TrimSpace(r.URL.Query().Get("done"))
+		statusParam := strings.TrimSpace(r.URL.Query().Get("status"))
+		sortParam := strings.TrimSpace(r.URL.Query().Get("sort"))
 		limitParam := strings.TrimSpace(r.URL.Query().Get("limit"))
 		offsetParam := strings.TrimSpace(r.URL.Query().Get("offset"))
+		assigneeParam := strings.TrimSpace(r.URL.Query().Get("assignee"))
 
-		hasQueryParams := q != "" || doneParam != "" || limitParam != "" || offsetParam != ""
+		hasQueryParams := q != "" || doneParam != "" || statusParam != "" || sortParam != "" || limitParam != "" || offsetParam != "" || assigneeParam != ""
 
 		// ── 2. Extract userID from JWT context ───────────────
 		userIDVal := r.Context().Value(auth.UserIDContextKey)
@@ -39,9 +160,15 @@ func GetTasksHandlerDB(db *sql.DB, rdb *redis.Client) http.HandlerFunc {
 		userID := userIDVal.(int64)
 		ctx := r.Context()
 
+		workspaceID, _, err := requestWorkspace(r, db, userID)
+		if err != nil {
+			writeWorkspaceError(w, err)
+			return
+		}
+
 		// ── 3. Redis ONLY if no query params ─────────────────
 		if !hasQueryParams {
-			if tasks, hit, err := cache.GetTasks(ctx, rdb, userID); err == nil && hit {
+			if tasks, hit, err := cache.GetTasks(ctx, rdb, workspaceID, userID); err == nil && hit {
 				log.Println("Redis cache HIT")
 				WriteJson(w, http.StatusOK, tasks)
 				return
@@ -52,10 +179,24 @@ func GetTasksHandlerDB(db *sql.DB, rdb *redis.Client) http.HandlerFunc {
 		// ── 4. Build filters ─────────────────────────────────
 		var (
 			args   []any
-			where  = "WHERE user_id = $1"
+			where  = "WHERE workspace_id = $1 AND archived_at IS NULL"
 			argPos = 2
 		)
-		args = append(args, userID)
+		args = append(args, workspaceID)
+
+		// The personal list also shows what others shared with the user.
+		personalID, err := ensurePersonalWorkspace(ctx, db, userID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if workspaceID == personalID {
+			where = `WHERE (workspace_id = $1 OR EXISTS (
+				SELECT 1 FROM share_grants g WHERE g.grantee_id = $2 AND ` + grantCoversTask + `
+			)) AND archived_at IS NULL`
+			args = append(args, userID)
+			argPos++
+		}
 
 		if doneParam != "" {
 			val, err := strconv.ParseBool(doneParam)
@@ -68,12 +209,47 @@ func GetTasksHandlerDB(db *sql.DB, rdb *redis.Client) http.HandlerFunc {
 			argPos++
 		}
 
+		if statusParam != "" {
+			where += fmt.Sprintf(" AND status = $%d", argPos)
+			args = append(args, statusParam)
+			argPos++
+		}
+
+		switch assigneeParam {
+		case "":
+		case "none":
+			where += " AND assignee_id IS NULL"
+		default:
+			assignee := userID
+			if assigneeParam != "me" {
+				val, err := strconv.ParseInt(assigneeParam, 10, 64)
+				if err != nil {
+					WriteJson(w, http.StatusBadRequest, map[string]string{"error": "invalid assignee param"})
+					return
+				}
+				assignee = val
+			}
+			where += fmt.Sprintf(" AND assignee_id = $%d", argPos)
+			args = append(args, assignee)
+			argPos++
+		}
+
 		if q != "" {
 			where += fmt.Sprintf(" AND LOWER(title) LIKE $%d", argPos)
 			args = append(args, "%"+strings.ToLower(q)+"%")
 			argPos++
 		}
 
+		orderBy := "created_at DESC"
+		switch sortParam {
+		case "", "created_at":
+		case "position":
+			orderBy = "position NULLS LAST, created_at DESC"
+		default:
+			WriteJson(w, http.StatusBadRequest, map[string]string{"error": "invalid sort"})
+			return
+		}
+
 		// ── 5. Pagination ────────────────────────────────────
 		limit := 20
 		offset := 0
@@ -98,13 +274,26 @@ func GetTasksHandlerDB(db *sql.DB, rdb *redis.Client) http.HandlerFunc {
 
 		args = append(args, limit, offset)
 
+		// Comment counts for the whole page come from one grouped query.
 		query := fmt.Sprintf(`
-			SELECT id, title, done, ai_summary, created_at, updated_at
-			FROM tasks
-			%s
-			ORDER BY created_at DESC
-			LIMIT $%d OFFSET $%d
-		`, where, argPos, argPos+1)
+			WITH page AS (
+				SELECT `+taskColumns+`, user_id AS owner_id
+				FROM tasks t
+				%s
+				ORDER BY %s
+				LIMIT $%d OFFSET $%d
+			)
+			SELECT page.*, COALESCE(cc.n, 0),
+				(SELECT email FROM users WHERE id = page.owner_id)
+			FROM page
+			LEFT JOIN (
+				SELECT task_id, COUNT(*) AS n
+				FROM task_comments
+				WHERE task_id IN (SELECT id FROM page)
+				GROUP BY task_id
+			) cc ON cc.task_id = page.id
+			ORDER BY %s
+		`, where, orderBy, argPos, argPos+1, orderBy)
 
 		// ── 6. DB query ──────────────────────────────────────
 		rows, err := db.Query(query, args...)
@@ -117,24 +306,26 @@ func GetTasksHandlerDB(db *sql.DB, rdb *redis.Client) http.HandlerFunc {
 		tasks := make([]models.Task, 0)
 
 		for rows.Next() {
-			var t models.Task
-			if err := rows.Scan(
-				&t.ID,
-				&t.Title,
-				&t.Done,
-				&t.AiSummary,
-				&t.CreatedAt,
-				&t.UpdatedAt,
-			); err != nil {
+			var (
+				ownerID    int64
+				comments   int
+				ownerEmail string
+			)
+			t, err := scanTask(scanWithExtra(rows, &ownerID, &comments, &ownerEmail))
+			if err != nil {
 				http.Error(w, err.Error(), http.StatusInternalServerError)
 				return
 			}
+			t.CommentCount = comments
+			if t.WorkspaceID != workspaceID {
+				t.Owner = &models.UserRef{ID: ownerID, Email: ownerEmail}
+			}
 			tasks = append(tasks, t)
 		}
 
 		// ── 7. Cache ONLY full list ──────────────────────────
 		if !hasQueryParams {
-			if err := cache.SetTasks(ctx, rdb, userID, tasks); err != nil {
+			if err := cache.SetTasks(ctx, rdb, workspaceID, userID, tasks); err != nil {
 				log.Printf("Redis SET failed: %v", err)
 			}
 		}
@@ -163,11 +354,20 @@ func GetTaskbyIDHandlerDB(db *sql.DB) http.HandlerFunc {
 			http.Error(w, "unauthorized", http.StatusUnauthorized)
 			return
 		}
+		ok, err = canViewTask(r.Context(), db, userID, id)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if !ok {
+			WriteJson(w, http.StatusNotFound, map[string]string{"error": "Task not found"})
+			return
+		}
+
 		rows, err := db.Query(`
-			SELECT id, title, done, created_at, updated_at
+			SELECT `+taskColumns+`
 			FROM tasks
-			WHERE user_id = $1 AND id = $2
-			ORDER BY created_at DESC`, userID, id)
+			WHERE id = $1`, id)
 
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -176,15 +376,7 @@ func GetTaskbyIDHandlerDB(db *sql.DB) http.HandlerFunc {
 		defer rows.Close()
 
 		for rows.Next() {
-			var t models.Task
-			err := rows.Scan(
-				&t.ID,
-				&t.Title,
-				&t.Done,
-				&t.CreatedAt,
-				&t.UpdatedAt,
-			)
-
+			t, err := scanTask(rows)
 			if err != nil {
 				http.Error(w, err.Error(), http.StatusInternalServerError)
 				return
@@ -209,6 +401,11 @@ func CreateTaskHandlerDB(db *sql.DB, rdb *redis.Client, aiWorker *ai.Worker) htt
 			return
 		}
 
+		if !models.ValidPriority(req.Priority) {
+			WriteJson(w, http.StatusBadRequest, map[string]string{"error": "invalid priority"})
+			return
+		}
+
 		userIDVal := r.Context().Value(auth.UserIDContextKey)
 		if userIDVal == nil {
 			http.Error(w, "unauthorized", http.StatusUnauthorized)
@@ -220,27 +417,58 @@ func CreateTaskHandlerDB(db *sql.DB, rdb *redis.Client, aiWorker *ai.Worker) htt
 			http.Error(w, "unauthorized", http.StatusUnauthorized)
 			return
 		}
-		var task models.Task
-
 		//Using Redis to delete the data
 		ctx := r.Context()
 
+		// Subtasks live in their parent's workspace, so access to the parent
+		// (possibly through a share) decides.
+		var (
+			workspaceID int64
+			role        string
+			err         error
+		)
+		if req.ParentID != nil {
+			workspaceID, role, err = taskAccess(ctx, db, userID, *req.ParentID)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			if !workspace.CanRead(role) {
+				WriteJson(w, http.StatusBadRequest, map[string]string{"error": "parent task not found"})
+				return
+			}
+		} else {
+			workspaceID, role, err = requestWorkspace(r, db, userID)
+			if err != nil {
+				writeWorkspaceError(w, err)
+				return
+			}
+		}
+		if !workspace.CanWrite(role) {
+			WriteJson(w, http.StatusForbidden, map[string]string{"error": "insufficient role"})
+			return
+		}
+
 		// Generate AI summary before insert (using a temp task with just the title)
 		tempTask := models.Task{Title: req.Title, Done: req.Done}
 		summary := aiWorker.AnalyzeTask(ctx, tempTask, 0, 0)
 
-		err := db.QueryRow(`
-			INSERT INTO tasks (user_id, title, done, ai_summary)
-			VALUES ($1, $2, $3, $4)
-			RETURNING id, title, done, ai_summary, created_at, updated_at`, userID, req.Title, req.Done, summary).Scan(
-			&task.ID,
-			&task.Title,
-			&task.Done,
-			&task.AiSummary,
-			&task.CreatedAt,
-			&task.UpdatedAt,
-		)
-
+		task, err := insertTask(ctx, db, userID, models.Task{
+			WorkspaceID: workspaceID,
+			ParentID:    req.ParentID,
+			Title:       req.Title,
+			Done:        req.Done,
+			Status:      req.Status,
+			Priority:    req.Priority,
+			DueAt:       req.DueAt,
+			Tags:        req.Tags,
+			AiSummary:   &summary,
+		})
+
+		if errors.Is(err, errUnknownStatus) {
+			WriteJson(w, http.StatusBadRequest, map[string]string{"error": "unknown status"})
+			return
+		}
 		if err != nil {
 			WriteJson(w, http.StatusInternalServerError, map[string]string{
 				"error": "failed to create task",
@@ -248,9 +476,7 @@ func CreateTaskHandlerDB(db *sql.DB, rdb *redis.Client, aiWorker *ai.Worker) htt
 			return
 		}
 
-		if err := cache.DeletTaks(ctx, rdb, userID); err != nil {
-			log.Printf("Redis DEL failed: %v", err)
-		}
+		invalidateWorkspace(ctx, db, rdb, workspaceID, userID)
 
 		WriteJson(w, http.StatusCreated, task)
 	}
@@ -276,6 +502,16 @@ func PatchTaskHandlerDB(db *sql.DB, rdb *redis.Client) http.HandlerFunc {
 			req.Title = &trimmed
 		}
 
+		if req.Priority != nil && !models.ValidPriority(*req.Priority) {
+			WriteJson(w, http.StatusBadRequest, map[string]string{"error": "invalid priority"})
+			return
+		}
+
+		var tags any
+		if req.Tags != nil {
+			tags = models.NormalizeTags(*req.Tags)
+		}
+
 		//DB logic
 
 		userIDVal := r.Context().Value(auth.UserIDContextKey)
@@ -289,52 +525,120 @@ func PatchTaskHandlerDB(db *sql.DB, rdb *redis.Client) http.HandlerFunc {
 			http.Error(w, "unauthorized", http.StatusUnauthorized)
 			return
 		}
-		row, err := db.Query(`
-			UPDATE tasks SET
-            title = COALESCE($1, title),
-            done = COALESCE($2, done),
-            updated_at = NOW()
-            WHERE
-            id = $3
-            AND user_id = $4
-            RETURNING
-        id, title, done, created_at, updated_at`, req.Title, req.Done, id, userID)
+		ctx := r.Context()
 
+		tx, err := db.BeginTx(ctx, nil)
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
+		defer tx.Rollback()
 
-		for row.Next() {
-			var t models.Task
-			err := row.Scan(
-				&t.ID,
-				&t.Title,
-				&t.Done,
-				&t.CreatedAt,
-				&t.UpdatedAt,
-			)
+		workspaceID, role, err := taskAccess(ctx, tx, userID, id)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if !workspace.CanRead(role) {
+			WriteJson(w, http.StatusNotFound, map[string]string{"error": "Task not found"})
+			return
+		}
+		if !workspace.CanWrite(role) {
+			WriteJson(w, http.StatusForbidden, map[string]string{"error": "insufficient role"})
+			return
+		}
 
-			if err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
+		// Lock the row so the transition check and the update see the same
+		// current status.
+		var (
+			current   string
+			creatorID int64
+		)
+		err = tx.QueryRowContext(ctx, `
+			SELECT status, user_id FROM tasks
+			WHERE id = $1
+			FOR UPDATE`, id).Scan(&current, &creatorID)
+		if err == sql.ErrNoRows {
+			WriteJson(w, http.StatusNotFound, map[string]string{"error": "Task not found"})
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		wf, err := workspaceWorkflow(ctx, tx, workspaceID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		// The legacy done flag is mapped onto the workflow; an explicit
+		// status wins but must agree with done if both are sent.
+		target := current
+		switch {
+		case req.Status != nil:
+			if _, ok := workflow.Find(wf, *req.Status); !ok {
+				WriteJson(w, http.StatusBadRequest, map[string]string{"error": "unknown status"})
 				return
 			}
-			WriteJson(w, http.StatusOK, t)
+			if req.Done != nil && *req.Done != workflow.IsDone(wf, *req.Status) {
+				WriteJson(w, http.StatusBadRequest, map[string]string{"error": "status and done disagree"})
+				return
+			}
+			target = *req.Status
+		case req.Done != nil && *req.Done != workflow.IsDone(wf, current):
+			target = workflow.StatusForDone(wf, *req.Done)
+		}
+
+		if !workflow.CanTransition(wf, current, target) {
+			WriteJson(w, http.StatusConflict, map[string]string{
+				"error": "transition not allowed",
+				"from":  current,
+				"to":    target,
+			})
 			return
 		}
-		//Using Redis to delete the data
-		ctx := r.Context()
 
-		if err := cache.DeletTaks(ctx, rdb, userID); err != nil {
-			log.Printf("Redis DEL failed: %v", err)
+		t, err := scanTask(tx.QueryRowContext(ctx, `
+			UPDATE tasks SET
+            title = COALESCE($1, title),
+            status = $2,
+            done = $3,
+            completed_at = CASE
+                WHEN $3 = done THEN completed_at
+                WHEN $3 THEN NOW()
+                ELSE NULL
+            END,
+            priority = COALESCE($4, priority),
+            due_at = COALESCE($5, due_at),
+            reminded_at = CASE WHEN $5 IS NULL OR $5 = due_at THEN reminded_at END,
+            tags = COALESCE($6, tags),
+            updated_at = NOW()
+            WHERE
+            id = $7
+            RETURNING `+taskColumns,
+			req.Title, target, workflow.IsDone(wf, target), req.Priority, req.DueAt, tags, id))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
 		}
 
-		WriteJson(w, http.StatusNotFound, map[string]string{"error": "Task not found"})
+		if err := tx.Commit(); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
 
+		//Using Redis to delete the data
+		invalidateWorkspace(ctx, db, rdb, workspaceID, creatorID)
+
+		WriteJson(w, http.StatusOK, t)
 	}
 }
 
-func DeleteTaskHandlerDB(db *sql.DB, rdb *redis.Client) http.HandlerFunc {
+// DeleteTaskHandlerDB deletes a task with its subtasks and removes their
+// attachment blobs from store.
+func DeleteTaskHandlerDB(db *sql.DB, rdb *redis.Client, store blob.BlobStore) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		IDParam := chi.URLParam(r, "id")
 		id, err := strconv.Atoi(IDParam)
@@ -354,35 +658,58 @@ func DeleteTaskHandlerDB(db *sql.DB, rdb *redis.Client) http.HandlerFunc {
 			http.Error(w, "unauthorized", http.StatusUnauthorized)
 			return
 		}
-		//var task models.Task
-		res, err := db.Exec(`DELETE FROM tasks
-               WHERE id = $1
-               AND user_id = $2`, id, userID)
+		ctx := r.Context()
 
+		workspaceID, role, err := taskAccess(ctx, db, userID, id)
 		if err != nil {
-			WriteJson(w, http.StatusInternalServerError, map[string]string{
-				"error": "failed to create task",
-			})
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if !workspace.CanRead(role) {
+			WriteJson(w, http.StatusNotFound, map[string]string{"error": "Task not found"})
 			return
 		}
-		rowsAffected, err := res.RowsAffected()
+		if !workspace.CanWrite(role) {
+			WriteJson(w, http.StatusForbidden, map[string]string{"error": "insufficient role"})
+			return
+		}
+
+		// Collect blob keys and the users it is shared with before the
+		// rows cascade away.
+		keys, err := taskAttachmentKeys(ctx, db, id)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		viewers, err := taskViewerIDs(ctx, db, id)
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 
-		if rowsAffected == 0 {
+		//var task models.Task
+		var creatorID int64
+		err = db.QueryRowContext(ctx, `DELETE FROM tasks
+               WHERE id = $1
+               RETURNING user_id`, id).Scan(&creatorID)
+		if err == sql.ErrNoRows {
 			WriteJson(w, http.StatusNotFound, map[string]string{
 				"error": "Task not found"})
 			return
 		}
 
+		if err != nil {
+			WriteJson(w, http.StatusInternalServerError, map[string]string{
+				"error": "failed to create task",
+			})
+			return
+		}
+
 		//Using Redis to delete the data
-		ctx := r.Context()
+		invalidateWorkspace(ctx, db, rdb, workspaceID, creatorID)
+		invalidateUsers(ctx, rdb, viewers...)
 
-		if err := cache.DeletTaks(ctx, rdb, userID); err != nil {
-			log.Printf("Redis DEL failed: %v", err)
-		}
+		deleteBlobs(ctx, store, keys)
 
 		w.WriteHeader(http.StatusNoContent)
 
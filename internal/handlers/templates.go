@@ -0,0 +1,483 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"gotasker/internal/models"
+	cache "gotasker/internal/redis"
+	"gotasker/internal/templates"
+
+	"github.com/go-chi/chi"
+	"github.com/redis/go-redis/v9"
+)
+
+const templateColumns = `id, name, task, subtasks, created_at, updated_at`
+
+func scanTemplate(s rowScanner) (models.TaskTemplate, error) {
+	var (
+		t        models.TaskTemplate
+		task     []byte
+		subtasks []byte
+	)
+	if err := s.Scan(&t.ID, &t.Name, &task, &subtasks, &t.CreatedAt, &t.UpdatedAt); err != nil {
+		return t, err
+	}
+	if err := json.Unmarshal(task, &t.Task); err != nil {
+		return t, err
+	}
+	if err := json.Unmarshal(subtasks, &t.Subtasks); err != nil {
+		return t, err
+	}
+	if t.Subtasks == nil {
+		t.Subtasks = []models.TemplateTask{}
+	}
+
+	t.Variables = templates.Placeholders(t.Task.Title)
+	for _, st := range t.Subtasks {
+		for _, v := range templates.Placeholders(st.Title) {
+			if !containsString(t.Variables, v) {
+				t.Variables = append(t.Variables, v)
+			}
+		}
+	}
+	if t.Variables == nil {
+		t.Variables = []string{}
+	}
+	return t, nil
+}
+
+// normalizeTemplate trims and validates a template request in place.
+func normalizeTemplate(req *models.TemplateRequest) error {
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		return errors.New("name is required")
+	}
+	if req.Subtasks == nil {
+		req.Subtasks = []models.TemplateTask{}
+	}
+
+	items := []*models.TemplateTask{&req.Task}
+	for i := range req.Subtasks {
+		items = append(items, &req.Subtasks[i])
+	}
+	for _, it := range items {
+		it.Title = strings.TrimSpace(it.Title)
+		if it.Title == "" {
+			return errors.New("every task in a template needs a title")
+		}
+		if !models.ValidPriority(it.Priority) {
+			return errors.New("invalid priority")
+		}
+		if _, err := templates.ParseOffset(it.DueOffset); err != nil {
+			return err
+		}
+		it.Tags = models.NormalizeTags(it.Tags)
+	}
+	return nil
+}
+
+func templateIDParam(r *http.Request) (int64, error) {
+	return strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+}
+
+func ListTemplatesHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := currentUserID(r)
+		if !ok {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		rows, err := db.QueryContext(r.Context(), `
+			SELECT `+templateColumns+`
+			FROM task_templates
+			WHERE user_id = $1
+			ORDER BY name`, userID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer rows.Close()
+
+		out := make([]models.TaskTemplate, 0)
+		for rows.Next() {
+			t, err := scanTemplate(rows)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			out = append(out, t)
+		}
+
+		WriteJson(w, http.StatusOK, out)
+	}
+}
+
+func GetTemplateHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := templateIDParam(r)
+		if err != nil {
+			WriteJson(w, http.StatusBadRequest, map[string]string{"error": "Invalid template ID"})
+			return
+		}
+
+		userID, ok := currentUserID(r)
+		if !ok {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		t, err := scanTemplate(db.QueryRowContext(r.Context(), `
+			SELECT `+templateColumns+`
+			FROM task_templates
+			WHERE id = $1 AND user_id = $2`, id, userID))
+		if errors.Is(err, sql.ErrNoRows) {
+			WriteJson(w, http.StatusNotFound, map[string]string{"error": "Template not found"})
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		WriteJson(w, http.StatusOK, t)
+	}
+}
+
+func CreateTemplateHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req models.TemplateRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			WriteJson(w, http.StatusBadRequest, map[string]string{"error": "Invalid Json"})
+			return
+		}
+		if err := normalizeTemplate(&req); err != nil {
+			WriteJson(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+
+		userID, ok := currentUserID(r)
+		if !ok {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		t, err := insertTemplate(r, db, userID, req)
+		if err != nil {
+			writeTemplateError(w, err)
+			return
+		}
+
+		WriteJson(w, http.StatusCreated, t)
+	}
+}
+
+func UpdateTemplateHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := templateIDParam(r)
+		if err != nil {
+			WriteJson(w, http.StatusBadRequest, map[string]string{"error": "Invalid template ID"})
+			return
+		}
+
+		var req models.TemplateRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			WriteJson(w, http.StatusBadRequest, map[string]string{"error": "Invalid Json"})
+			return
+		}
+		if err := normalizeTemplate(&req); err != nil {
+			WriteJson(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+
+		userID, ok := currentUserID(r)
+		if !ok {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		task, _ := json.Marshal(req.Task)
+		subtasks, _ := json.Marshal(req.Subtasks)
+
+		t, err := scanTemplate(db.QueryRowContext(r.Context(), `
+			UPDATE task_templates SET
+				name = $1,
+				task = $2::jsonb,
+				subtasks = $3::jsonb,
+				updated_at = NOW()
+			WHERE id = $4 AND user_id = $5
+			RETURNING `+templateColumns,
+			req.Name, string(task), string(subtasks), id, userID))
+		if errors.Is(err, sql.ErrNoRows) {
+			WriteJson(w, http.StatusNotFound, map[string]string{"error": "Template not found"})
+			return
+		}
+		if err != nil {
+			writeTemplateError(w, err)
+			return
+		}
+
+		WriteJson(w, http.StatusOK, t)
+	}
+}
+
+func DeleteTemplateHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := templateIDParam(r)
+		if err != nil {
+			WriteJson(w, http.StatusBadRequest, map[string]string{"error": "Invalid template ID"})
+			return
+		}
+
+		userID, ok := currentUserID(r)
+		if !ok {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		res, err := db.ExecContext(r.Context(), `
+			DELETE FROM task_templates
+			WHERE id = $1 AND user_id = $2`, id, userID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			WriteJson(w, http.StatusNotFound, map[string]string{"error": "Template not found"})
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// InstantiateTemplateHandler creates the template's task and subtasks in one
+// transaction, substituting placeholders and turning due offsets into due
+// dates relative to now.
+func InstantiateTemplateHandler(db *sql.DB, rdb *redis.Client) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := templateIDParam(r)
+		if err != nil {
+			WriteJson(w, http.StatusBadRequest, map[string]string{"error": "Invalid template ID"})
+			return
+		}
+
+		var req models.InstantiateTemplateRequest
+		if r.ContentLength != 0 {
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				WriteJson(w, http.StatusBadRequest, map[string]string{"error": "Invalid Json"})
+				return
+			}
+		}
+
+		loc := time.UTC
+		if req.Timezone != "" {
+			l, err := time.LoadLocation(req.Timezone)
+			if err != nil {
+				WriteJson(w, http.StatusBadRequest, map[string]string{"error": "invalid timezone"})
+				return
+			}
+			loc = l
+		}
+
+		userID, ok := currentUserID(r)
+		if !ok {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		ctx := r.Context()
+		tmpl, err := scanTemplate(db.QueryRowContext(ctx, `
+			SELECT `+templateColumns+`
+			FROM task_templates
+			WHERE id = $1 AND user_id = $2`, id, userID))
+		if errors.Is(err, sql.ErrNoRows) {
+			WriteJson(w, http.StatusNotFound, map[string]string{"error": "Template not found"})
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		now := time.Now()
+		vars := map[string]string{"date": now.In(loc).Format("2006-01-02")}
+		for k, v := range req.Variables {
+			vars[k] = v
+		}
+
+		build := func(tt models.TemplateTask) (models.Task, []string) {
+			title, missing := templates.Render(tt.Title, vars)
+			t := models.Task{Title: title, Priority: tt.Priority, Tags: tt.Tags}
+			if tt.DueOffset != "" {
+				// Offsets were validated when the template was saved.
+				off, _ := templates.ParseOffset(tt.DueOffset)
+				due := now.Add(off).UTC()
+				t.DueAt = &due
+			}
+			return t, missing
+		}
+
+		root, missing := build(tmpl.Task)
+		children := make([]models.Task, 0, len(tmpl.Subtasks))
+		for _, st := range tmpl.Subtasks {
+			child, m := build(st)
+			missing = append(missing, m...)
+			children = append(children, child)
+		}
+		if len(missing) > 0 {
+			WriteJson(w, http.StatusBadRequest, map[string]any{
+				"error":   "missing template variables",
+				"missing": uniqueStrings(missing),
+			})
+			return
+		}
+
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer tx.Rollback()
+
+		resp := models.InstantiateTemplateResponse{Subtasks: make([]models.Task, 0, len(children))}
+		resp.Task, err = insertTask(ctx, tx, userID, root)
+		if err != nil {
+			WriteJson(w, http.StatusInternalServerError, map[string]string{"error": "failed to create tasks"})
+			return
+		}
+		for _, child := range children {
+			child.ParentID = &resp.Task.ID
+			stored, err := insertTask(ctx, tx, userID, child)
+			if err != nil {
+				WriteJson(w, http.StatusInternalServerError, map[string]string{"error": "failed to create tasks"})
+				return
+			}
+			resp.Subtasks = append(resp.Subtasks, stored)
+		}
+
+		if err := tx.Commit(); err != nil {
+			WriteJson(w, http.StatusInternalServerError, map[string]string{"error": "failed to create tasks"})
+			return
+		}
+
+		if err := cache.DeletTaks(ctx, rdb, userID); err != nil {
+			log.Printf("Redis DEL failed: %v", err)
+		}
+
+		WriteJson(w, http.StatusCreated, resp)
+	}
+}
+
+// SaveTaskAsTemplateHandler stores an existing task and its direct subtasks
+// as a template. Due dates become offsets from the task's creation time.
+func SaveTaskAsTemplateHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			WriteJson(w, http.StatusBadRequest, map[string]string{"error": "Invalid task ID"})
+			return
+		}
+
+		var req models.SaveAsTemplateRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			WriteJson(w, http.StatusBadRequest, map[string]string{"error": "Invalid Json"})
+			return
+		}
+
+		userID, ok := currentUserID(r)
+		if !ok {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		ctx := r.Context()
+		rows, err := db.QueryContext(ctx, `
+			SELECT `+taskColumns+`
+			FROM tasks
+			WHERE user_id = $1 AND (id = $2 OR parent_id = $2)
+			ORDER BY parent_id NULLS FIRST, created_at, id`, userID, id)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer rows.Close()
+
+		var (
+			root     *models.Task
+			children []models.Task
+		)
+		for rows.Next() {
+			t, err := scanTask(rows)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			if t.ID == id {
+				root = &t
+			} else {
+				children = append(children, t)
+			}
+		}
+		if root == nil {
+			WriteJson(w, http.StatusNotFound, map[string]string{"error": "Task not found"})
+			return
+		}
+
+		toTemplate := func(t models.Task) models.TemplateTask {
+			tt := models.TemplateTask{Title: t.Title, Priority: t.Priority, Tags: t.Tags}
+			if t.DueAt != nil {
+				tt.DueOffset = templates.FormatOffset(t.DueAt.Sub(root.CreatedAt))
+			}
+			return tt
+		}
+
+		tmplReq := models.TemplateRequest{Name: req.Name, Task: toTemplate(*root)}
+		for _, c := range children {
+			tmplReq.Subtasks = append(tmplReq.Subtasks, toTemplate(c))
+		}
+		if err := normalizeTemplate(&tmplReq); err != nil {
+			WriteJson(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+
+		t, err := insertTemplate(r, db, userID, tmplReq)
+		if err != nil {
+			writeTemplateError(w, err)
+			return
+		}
+
+		WriteJson(w, http.StatusCreated, t)
+	}
+}
+
+func insertTemplate(r *http.Request, db *sql.DB, userID int64, req models.TemplateRequest) (models.TaskTemplate, error) {
+	task, err := json.Marshal(req.Task)
+	if err != nil {
+		return models.TaskTemplate{}, err
+	}
+	subtasks, err := json.Marshal(req.Subtasks)
+	if err != nil {
+		return models.TaskTemplate{}, err
+	}
+	return scanTemplate(db.QueryRowContext(r.Context(), `
+		INSERT INTO task_templates (user_id, name, task, subtasks)
+		VALUES ($1, $2, $3::jsonb, $4::jsonb)
+		RETURNING `+templateColumns,
+		userID, req.Name, string(task), string(subtasks)))
+}
+
+func writeTemplateError(w http.ResponseWriter, err error) {
+	if strings.Contains(err.Error(), "duplicate key") {
+		WriteJson(w, http.StatusConflict, map[string]string{"error": "a template with this name already exists"})
+		return
+	}
+	WriteJson(w, http.StatusInternalServerError, map[string]string{"error": "failed to save template"})
+}
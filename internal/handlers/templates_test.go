@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"gotasker/internal/models"
+)
+
+func TestNormalizeTemplate(t *testing.T) {
+	req := models.TemplateRequest{
+		Name:     "  Onboarding ",
+		Task:     models.TemplateTask{Title: " Onboard {{name}} ", Tags: []string{"#HR"}},
+		Subtasks: nil,
+	}
+	if err := normalizeTemplate(&req); err != nil {
+		t.Fatal(err)
+	}
+	if req.Name != "Onboarding" || req.Task.Title != "Onboard {{name}}" || req.Subtasks == nil {
+		t.Errorf("not normalized: %+v", req)
+	}
+
+	for name, req := range map[string]models.TemplateRequest{
+		"no name":          {Task: models.TemplateTask{Title: "x"}},
+		"no title":         {Name: "n", Task: models.TemplateTask{Title: " "}},
+		"no subtask title": {Name: "n", Task: models.TemplateTask{Title: "x"}, Subtasks: []models.TemplateTask{{}}},
+		"bad priority":     {Name: "n", Task: models.TemplateTask{Title: "x", Priority: "urgent"}},
+		"bad offset":       {Name: "n", Task: models.TemplateTask{Title: "x", DueOffset: "soon"}},
+	} {
+		if err := normalizeTemplate(&req); err == nil {
+			t.Errorf("%s: want error", name)
+		}
+	}
+}
+
+func TestTemplatesBelongToTheirOwner(t *testing.T) {
+	db := testDB(t)
+	owner := createTestUser(t, db, "owner@example.com")
+	other := createTestUser(t, db, "other@example.com")
+
+	rec := serve(CreateTemplateHandler(db), asUser(newJSONRequest(http.MethodPost, "/templates",
+		`{"name":"Release","task":{"title":"Release {{version}}"},"subtasks":[{"title":"Tag {{version}}","due_offset":"1d"}]}`), owner))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create: status %d: %s", rec.Code, rec.Body)
+	}
+	var tmpl models.TaskTemplate
+	if err := json.Unmarshal(rec.Body.Bytes(), &tmpl); err != nil {
+		t.Fatal(err)
+	}
+
+	// Someone else's template does not exist for them.
+	for name, h := range map[string]http.HandlerFunc{
+		"get":         GetTemplateHandler(db),
+		"update":      UpdateTemplateHandler(db),
+		"delete":      DeleteTemplateHandler(db),
+		"instantiate": InstantiateTemplateHandler(db, nil),
+	} {
+		req := newJSONRequest(http.MethodPost, "/templates/x", `{"name":"Mine","task":{"title":"t"},"variables":{"version":"1"}}`)
+		if rec := serve(h, withTaskID(asUser(req, other), tmpl.ID)); rec.Code != http.StatusNotFound {
+			t.Errorf("%s as another user: status %d, want 404", name, rec.Code)
+		}
+	}
+	rec = serve(ListTemplatesHandler(db), asUser(newJSONRequest(http.MethodGet, "/templates", ""), other))
+	if rec.Code != http.StatusOK || strings.TrimSpace(rec.Body.String()) != "[]" {
+		t.Errorf("list as another user: status %d: %s", rec.Code, rec.Body)
+	}
+
+	// Variables must all be given.
+	rec = serve(InstantiateTemplateHandler(db, nil), withTaskID(asUser(newJSONRequest(http.MethodPost, "/", `{}`), owner), tmpl.ID))
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "version") {
+		t.Errorf("missing variable: status %d: %s", rec.Code, rec.Body)
+	}
+	rec = serve(InstantiateTemplateHandler(db, nil), withTaskID(asUser(newJSONRequest(http.MethodPost, "/",
+		`{"variables":{"version":"2.0"}}`), owner), tmpl.ID))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("instantiate: status %d: %s", rec.Code, rec.Body)
+	}
+	var created models.InstantiateTemplateResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &created); err != nil {
+		t.Fatal(err)
+	}
+	if created.Task.Title != "Release 2.0" || len(created.Subtasks) != 1 || created.Subtasks[0].Title != "Tag 2.0" ||
+		created.Subtasks[0].ParentID == nil || *created.Subtasks[0].ParentID != created.Task.ID || created.Subtasks[0].DueAt == nil {
+		t.Errorf("instantiated %+v", created)
+	}
+
+	// Only your own tasks can become templates.
+	taskID := insertTestTask(t, db, owner, "Private", nil, []string{})
+	rec = serve(SaveTaskAsTemplateHandler(db), withTaskID(asUser(newJSONRequest(http.MethodPost, "/", `{"name":"Stolen"}`), other), taskID))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("save another user's task: status %d, want 404", rec.Code)
+	}
+}
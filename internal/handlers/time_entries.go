@@ -0,0 +1,600 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"gotasker/internal/models"
+
+	"github.com/go-chi/chi"
+	"github.com/redis/go-redis/v9"
+)
+
+const timeEntryColumns = `id, task_id, started_at, ended_at, note,
+	EXTRACT(EPOCH FROM (COALESCE(ended_at, NOW()) - started_at))::bigint,
+	created_at, updated_at`
+
+func scanTimeEntry(s rowScanner) (models.TimeEntry, error) {
+	var e models.TimeEntry
+	err := s.Scan(&e.ID, &e.TaskID, &e.StartedAt, &e.EndedAt, &e.Note, &e.DurationSeconds, &e.CreatedAt, &e.UpdatedAt)
+	return e, err
+}
+
+// recomputeTimeSpent refreshes the task's cached total of finished entries.
+func recomputeTimeSpent(ctx context.Context, q dbtx, taskID int) error {
+	_, err := q.ExecContext(ctx, `
+		UPDATE tasks SET time_spent_seconds = (
+			SELECT COALESCE(SUM(EXTRACT(EPOCH FROM (ended_at - started_at))), 0)::bigint
+			FROM time_entries
+			WHERE task_id = $1 AND ended_at IS NOT NULL
+		)
+		WHERE id = $1`, taskID)
+	return err
+}
+
+// StartTimerHandler starts a running time entry on the task. A user can only
+// have one running timer; starting a second one returns 409 with the running
+// entry.
+func StartTimerHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		taskID, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			WriteJson(w, http.StatusBadRequest, map[string]string{"error": "Invalid task ID"})
+			return
+		}
+
+		var req models.TimerRequest
+		if r.ContentLength != 0 {
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				WriteJson(w, http.StatusBadRequest, map[string]string{"error": "Invalid Json"})
+				return
+			}
+		}
+
+		userID, ok := currentUserID(r)
+		if !ok {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		ctx := r.Context()
+		owned, err := canEditTask(ctx, db, userID, taskID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if !owned {
+			WriteJson(w, http.StatusNotFound, map[string]string{"error": "Task not found"})
+			return
+		}
+
+		e, err := scanTimeEntry(db.QueryRowContext(ctx, `
+			INSERT INTO time_entries (user_id, task_id, started_at, note)
+			VALUES ($1, $2, NOW(), $3)
+			RETURNING `+timeEntryColumns, userID, taskID, strings.TrimSpace(req.Note)))
+		if err != nil && strings.Contains(err.Error(), "duplicate key") {
+			running, err := scanTimeEntry(db.QueryRowContext(ctx, `
+				SELECT `+timeEntryColumns+`
+				FROM time_entries
+				WHERE user_id = $1 AND ended_at IS NULL`, userID))
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			WriteJson(w, http.StatusConflict, map[string]any{
+				"error":   "a timer is already running",
+				"running": running,
+			})
+			return
+		}
+		if err != nil {
+			WriteJson(w, http.StatusInternalServerError, map[string]string{"error": "failed to start timer"})
+			return
+		}
+
+		WriteJson(w, http.StatusCreated, e)
+	}
+}
+
+// StopTimerHandler stops the running timer on the task.
+func StopTimerHandler(db *sql.DB, rdb *redis.Client) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		taskID, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			WriteJson(w, http.StatusBadRequest, map[string]string{"error": "Invalid task ID"})
+			return
+		}
+
+		userID, ok := currentUserID(r)
+		if !ok {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		ctx := r.Context()
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer tx.Rollback()
+
+		e, err := scanTimeEntry(tx.QueryRowContext(ctx, `
+			UPDATE time_entries SET ended_at = NOW(), updated_at = NOW()
+			WHERE user_id = $1 AND task_id = $2 AND ended_at IS NULL
+			RETURNING `+timeEntryColumns, userID, taskID))
+		if errors.Is(err, sql.ErrNoRows) {
+			WriteJson(w, http.StatusConflict, map[string]string{"error": "no running timer on this task"})
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		if err := recomputeTimeSpent(ctx, tx, taskID); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if err := tx.Commit(); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		invalidateTaskViewers(ctx, db, rdb, taskID)
+
+		WriteJson(w, http.StatusOK, e)
+	}
+}
+
+func ListTimeEntriesHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		taskID, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			WriteJson(w, http.StatusBadRequest, map[string]string{"error": "Invalid task ID"})
+			return
+		}
+
+		userID, ok := currentUserID(r)
+		if !ok {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		ctx := r.Context()
+		owned, err := canViewTask(ctx, db, userID, taskID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if !owned {
+			WriteJson(w, http.StatusNotFound, map[string]string{"error": "Task not found"})
+			return
+		}
+
+		rows, err := db.QueryContext(ctx, `
+			SELECT `+timeEntryColumns+`
+			FROM time_entries
+			WHERE task_id = $1 AND user_id = $2
+			ORDER BY started_at DESC`, taskID, userID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer rows.Close()
+
+		entries := make([]models.TimeEntry, 0)
+		for rows.Next() {
+			e, err := scanTimeEntry(rows)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			entries = append(entries, e)
+		}
+
+		WriteJson(w, http.StatusOK, entries)
+	}
+}
+
+// CreateTimeEntryHandler records a finished span of work entered by hand.
+func CreateTimeEntryHandler(db *sql.DB, rdb *redis.Client) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		taskID, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			WriteJson(w, http.StatusBadRequest, map[string]string{"error": "Invalid task ID"})
+			return
+		}
+
+		var req models.TimeEntryRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			WriteJson(w, http.StatusBadRequest, map[string]string{"error": "Invalid Json"})
+			return
+		}
+		if req.StartedAt == nil || req.EndedAt == nil {
+			WriteJson(w, http.StatusBadRequest, map[string]string{"error": "started_at and ended_at are required"})
+			return
+		}
+		if req.EndedAt.Before(*req.StartedAt) {
+			WriteJson(w, http.StatusBadRequest, map[string]string{"error": "ended_at is before started_at"})
+			return
+		}
+		note := ""
+		if req.Note != nil {
+			note = strings.TrimSpace(*req.Note)
+		}
+
+		userID, ok := currentUserID(r)
+		if !ok {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		ctx := r.Context()
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer tx.Rollback()
+
+		owned, err := canEditTask(ctx, tx, userID, taskID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if !owned {
+			WriteJson(w, http.StatusNotFound, map[string]string{"error": "Task not found"})
+			return
+		}
+
+		e, err := scanTimeEntry(tx.QueryRowContext(ctx, `
+			INSERT INTO time_entries (user_id, task_id, started_at, ended_at, note)
+			VALUES ($1, $2, $3, $4, $5)
+			RETURNING `+timeEntryColumns, userID, taskID, *req.StartedAt, *req.EndedAt, note))
+		if err != nil {
+			WriteJson(w, http.StatusInternalServerError, map[string]string{"error": "failed to create time entry"})
+			return
+		}
+
+		if err := recomputeTimeSpent(ctx, tx, taskID); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if err := tx.Commit(); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		invalidateTaskViewers(ctx, db, rdb, taskID)
+
+		WriteJson(w, http.StatusCreated, e)
+	}
+}
+
+func UpdateTimeEntryHandler(db *sql.DB, rdb *redis.Client) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+		if err != nil {
+			WriteJson(w, http.StatusBadRequest, map[string]string{"error": "Invalid time entry ID"})
+			return
+		}
+
+		var req models.TimeEntryRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			WriteJson(w, http.StatusBadRequest, map[string]string{"error": "Invalid Json"})
+			return
+		}
+		if req.Note != nil {
+			trimmed := strings.TrimSpace(*req.Note)
+			req.Note = &trimmed
+		}
+
+		userID, ok := currentUserID(r)
+		if !ok {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		ctx := r.Context()
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer tx.Rollback()
+
+		e, err := scanTimeEntry(tx.QueryRowContext(ctx, `
+			UPDATE time_entries SET
+				started_at = COALESCE($1, started_at),
+				ended_at = COALESCE($2, ended_at),
+				note = COALESCE($3, note),
+				updated_at = NOW()
+			WHERE id = $4 AND user_id = $5
+			RETURNING `+timeEntryColumns, req.StartedAt, req.EndedAt, req.Note, id, userID))
+		if errors.Is(err, sql.ErrNoRows) {
+			WriteJson(w, http.StatusNotFound, map[string]string{"error": "Time entry not found"})
+			return
+		}
+		if err != nil && strings.Contains(err.Error(), "check constraint") {
+			WriteJson(w, http.StatusBadRequest, map[string]string{"error": "ended_at is before started_at"})
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		if err := recomputeTimeSpent(ctx, tx, e.TaskID); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if err := tx.Commit(); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		invalidateTaskViewers(ctx, db, rdb, e.TaskID)
+
+		WriteJson(w, http.StatusOK, e)
+	}
+}
+
+func DeleteTimeEntryHandler(db *sql.DB, rdb *redis.Client) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+		if err != nil {
+			WriteJson(w, http.StatusBadRequest, map[string]string{"error": "Invalid time entry ID"})
+			return
+		}
+
+		userID, ok := currentUserID(r)
+		if !ok {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		ctx := r.Context()
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer tx.Rollback()
+
+		var taskID int
+		err = tx.QueryRowContext(ctx, `
+			DELETE FROM time_entries
+			WHERE id = $1 AND user_id = $2
+			RETURNING task_id`, id, userID).Scan(&taskID)
+		if errors.Is(err, sql.ErrNoRows) {
+			WriteJson(w, http.StatusNotFound, map[string]string{"error": "Time entry not found"})
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		if err := recomputeTimeSpent(ctx, tx, taskID); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if err := tx.Commit(); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		invalidateTaskViewers(ctx, db, rdb, taskID)
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// timeReportQueries group finished entries overlapping [$2, $3), clipped to
+// the range. $4, the timezone used for day buckets, is only passed to the
+// day query (see timeReportArgs).
+var timeReportQueries = map[string]string{
+	"task": `
+		SELECT t.id::text, t.title, SUM(e.secs)::bigint
+		FROM report_entries e
+		JOIN tasks t ON t.id = e.task_id
+		GROUP BY t.id, t.title
+		ORDER BY 3 DESC, 1`,
+	"day": `
+		SELECT d, d, SUM(e.secs)::bigint
+		FROM (
+			SELECT to_char(GREATEST(started_at, $2) AT TIME ZONE $4, 'YYYY-MM-DD') AS d, secs
+			FROM report_entries
+		) e
+		GROUP BY d
+		ORDER BY d`,
+	"tag": `
+		SELECT COALESCE(tag, ''), COALESCE(tag, '(untagged)'), SUM(e.secs)::bigint
+		FROM report_entries e
+		JOIN tasks t ON t.id = e.task_id
+		LEFT JOIN LATERAL unnest(t.tags) AS tag ON TRUE
+		GROUP BY tag
+		ORDER BY 3 DESC, 1`,
+}
+
+const timeReportTotal = `
+	SELECT COALESCE(SUM(secs), 0)::bigint FROM report_entries`
+
+const timeReportEntries = `
+	WITH report_entries AS (
+		SELECT task_id, started_at,
+			EXTRACT(EPOCH FROM (LEAST(ended_at, $3) - GREATEST(started_at, $2)))::bigint AS secs
+		FROM time_entries
+		WHERE user_id = $1 AND ended_at IS NOT NULL
+			AND started_at < $3 AND ended_at > $2
+	)`
+
+// TimeReportHandler sums tracked time between ?from= and ?to= (RFC 3339 or
+// YYYY-MM-DD; a date-only "to" includes that day; default the last 7 days),
+// grouped by task, day or tag. Entries are clipped to the range; time on a
+// task with several tags counts towards each tag. ?format=csv returns CSV.
+func TimeReportHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+
+		groupBy := strings.TrimSpace(query.Get("group_by"))
+		if groupBy == "" {
+			groupBy = "task"
+		}
+		groupQuery, ok := timeReportQueries[groupBy]
+		if !ok {
+			WriteJson(w, http.StatusBadRequest, map[string]string{"error": "group_by must be task, day or tag"})
+			return
+		}
+
+		loc, err := reportLocation(query.Get("tz"))
+		if err != nil {
+			WriteJson(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+
+		from, to, err := parseReportRange(query.Get("from"), query.Get("to"), loc, 7)
+		if err != nil {
+			WriteJson(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+
+		userID, ok := currentUserID(r)
+		if !ok {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		ctx := r.Context()
+		report := models.TimeReport{From: from, To: to, GroupBy: groupBy, Rows: []models.TimeReportRow{}}
+
+		args := timeReportArgs(groupBy, userID, from, to, loc)
+		if err := db.QueryRowContext(ctx, timeReportEntries+timeReportTotal, args[:3]...).
+			Scan(&report.TotalSeconds); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		rows, err := db.QueryContext(ctx, timeReportEntries+groupQuery, args...)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var row models.TimeReportRow
+			if err := rows.Scan(&row.Key, &row.Label, &row.Seconds); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			report.Rows = append(report.Rows, row)
+		}
+		if err := rows.Err(); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		if query.Get("format") == "csv" {
+			writeTimeReportCSV(w, report)
+			return
+		}
+
+		WriteJson(w, http.StatusOK, report)
+	}
+}
+
+// timeReportArgs returns the arguments for timeReportEntries plus the
+// group query. pgx rejects arguments a query does not use, so the timezone
+// is only added for day buckets.
+func timeReportArgs(groupBy string, userID int64, from, to time.Time, loc *time.Location) []any {
+	args := []any{userID, from, to}
+	if groupBy == "day" {
+		args = append(args, loc.String())
+	}
+	return args
+}
+
+func writeTimeReportCSV(w http.ResponseWriter, report models.TimeReport) {
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="time-report.csv"`)
+	w.WriteHeader(http.StatusOK)
+
+	cw := csv.NewWriter(w)
+	_ = cw.Write([]string{report.GroupBy, "label", "seconds", "hours"})
+	for _, row := range report.Rows {
+		_ = cw.Write([]string{
+			row.Key,
+			row.Label,
+			strconv.FormatInt(row.Seconds, 10),
+			fmt.Sprintf("%.2f", float64(row.Seconds)/3600),
+		})
+	}
+	cw.Flush()
+}
+
+// reportLocation resolves ?tz=, UTC when it is empty. The name goes to
+// Postgres as well, so "Local", which only Go understands, is refused.
+func reportLocation(tz string) (*time.Location, error) {
+	tz = strings.TrimSpace(tz)
+	if tz == "" {
+		return time.UTC, nil
+	}
+	if tz == "Local" {
+		return nil, errors.New("invalid tz")
+	}
+	loc, err := time.LoadLocation(tz)
+	if err != nil {
+		return nil, errors.New("invalid tz")
+	}
+	return loc, nil
+}
+
+// parseReportRange reads from/to query values. Each may be RFC 3339 or a
+// YYYY-MM-DD date in loc; a date-only "to" is moved to the end of that day.
+// Missing values default to the last defaultDays days.
+func parseReportRange(fromParam, toParam string, loc *time.Location, defaultDays int) (time.Time, time.Time, error) {
+	parse := func(v string, endOfDay bool) (time.Time, error) {
+		if t, err := time.Parse(time.RFC3339, v); err == nil {
+			return t, nil
+		}
+		d, err := time.ParseInLocation("2006-01-02", v, loc)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if endOfDay {
+			d = d.AddDate(0, 0, 1)
+		}
+		return d, nil
+	}
+
+	to := time.Now()
+	if v := strings.TrimSpace(toParam); v != "" {
+		t, err := parse(v, true)
+		if err != nil {
+			return time.Time{}, time.Time{}, errors.New("invalid to")
+		}
+		to = t
+	}
+	from := to.AddDate(0, 0, -defaultDays)
+	if v := strings.TrimSpace(fromParam); v != "" {
+		t, err := parse(v, false)
+		if err != nil {
+			return time.Time{}, time.Time{}, errors.New("invalid from")
+		}
+		from = t
+	}
+	if !from.Before(to) {
+		return time.Time{}, time.Time{}, errors.New("from must be before to")
+	}
+	return from.UTC(), to.UTC(), nil
+}
@@ -0,0 +1,151 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"regexp"
+	"strconv"
+	"testing"
+	"time"
+
+	"gotasker/internal/models"
+)
+
+var placeholder = regexp.MustCompile(`\$(\d+)`)
+
+// maxPlaceholder returns the highest $n in query.
+func maxPlaceholder(query string) int {
+	highest := 0
+	for _, m := range placeholder.FindAllStringSubmatch(query, -1) {
+		if n, _ := strconv.Atoi(m[1]); n > highest {
+			highest = n
+		}
+	}
+	return highest
+}
+
+// pgx fails queries that are given more arguments than they reference, so
+// every group query must use exactly the arguments it gets.
+func TestTimeReportArgsMatchQueries(t *testing.T) {
+	now := time.Now()
+	for groupBy, query := range timeReportQueries {
+		args := timeReportArgs(groupBy, 1, now.Add(-time.Hour), now, time.UTC)
+		if got := maxPlaceholder(timeReportEntries + query); got != len(args) {
+			t.Errorf("group_by=%s: query uses %d placeholders, handler passes %d arguments", groupBy, got, len(args))
+		}
+		if got := maxPlaceholder(timeReportEntries + timeReportTotal); got != 3 {
+			t.Errorf("total query uses %d placeholders, handler passes 3 arguments", got)
+		}
+	}
+}
+
+func TestTimeReportHandler(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	userID := createTestUser(t, db, "timer@example.com")
+
+	var wsID int64
+	if err := db.QueryRowContext(ctx, `
+		SELECT id FROM workspaces WHERE personal AND created_by = $1`, userID).Scan(&wsID); err != nil {
+		t.Fatal(err)
+	}
+	task := func(title string, tags []string) int64 {
+		var id int64
+		if err := db.QueryRowContext(ctx, `
+			INSERT INTO tasks (user_id, workspace_id, title, tags)
+			VALUES ($1, $2, $3, $4)
+			RETURNING id`, userID, wsID, title, tags).Scan(&id); err != nil {
+			t.Fatal(err)
+		}
+		return id
+	}
+	entry := func(taskID int64, start, end string) {
+		if _, err := db.ExecContext(ctx, `
+			INSERT INTO time_entries (user_id, task_id, started_at, ended_at)
+			VALUES ($1, $2, $3, $4)`, userID, taskID, start, end); err != nil {
+			t.Fatal(err)
+		}
+	}
+	design := task("Design", []string{"client-a", "ux"})
+	build := task("Build", []string{})
+	entry(design, "2026-03-02T09:00:00Z", "2026-03-02T10:00:00Z")
+	entry(design, "2026-03-03T23:30:00Z", "2026-03-04T00:30:00Z")
+	entry(build, "2026-03-03T12:00:00Z", "2026-03-03T14:00:00Z")
+	// Outside the range.
+	entry(build, "2026-02-01T12:00:00Z", "2026-02-01T14:00:00Z")
+
+	tests := []struct {
+		groupBy string
+		want    []models.TimeReportRow
+	}{
+		{"task", []models.TimeReportRow{
+			// Ties are ordered by key; design was created first.
+			{Key: strconv.FormatInt(design, 10), Label: "Design", Seconds: 7200},
+			{Key: strconv.FormatInt(build, 10), Label: "Build", Seconds: 7200},
+		}},
+		{"day", []models.TimeReportRow{
+			{Key: "2026-03-02", Label: "2026-03-02", Seconds: 3600},
+			{Key: "2026-03-03", Label: "2026-03-03", Seconds: 10800},
+		}},
+		{"tag", []models.TimeReportRow{
+			{Key: "", Label: "(untagged)", Seconds: 7200},
+			{Key: "client-a", Label: "client-a", Seconds: 7200},
+			{Key: "ux", Label: "ux", Seconds: 7200},
+		}},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet,
+			"/reports/time?from=2026-03-01&to=2026-03-07&group_by="+tt.groupBy, nil)
+		rec := httptest.NewRecorder()
+		TimeReportHandler(db)(rec, asUser(req, userID))
+		if rec.Code != http.StatusOK {
+			t.Errorf("group_by=%s: status %d: %s", tt.groupBy, rec.Code, rec.Body)
+			continue
+		}
+		var report models.TimeReport
+		if err := json.Unmarshal(rec.Body.Bytes(), &report); err != nil {
+			t.Fatal(err)
+		}
+		if report.TotalSeconds != 14400 {
+			t.Errorf("group_by=%s: total %d, want 14400", tt.groupBy, report.TotalSeconds)
+		}
+		if !reflect.DeepEqual(report.Rows, tt.want) {
+			t.Errorf("group_by=%s: rows %+v, want %+v", tt.groupBy, report.Rows, tt.want)
+		}
+	}
+}
+
+func TestReportLocation(t *testing.T) {
+	for tz, want := range map[string]string{
+		"":                 "UTC",
+		" ":                "UTC",
+		"UTC":              "UTC",
+		"Europe/Berlin":    "Europe/Berlin",
+		" America/Denver ": "America/Denver",
+	} {
+		loc, err := reportLocation(tz)
+		if err != nil || loc.String() != want {
+			t.Errorf("%q: %v, %v; want %s", tz, loc, err, want)
+		}
+	}
+	for _, tz := range []string{"Local", "Mars/Olympus", "+02:00"} {
+		if _, err := reportLocation(tz); err == nil {
+			t.Errorf("%q: want error", tz)
+		}
+	}
+
+	// Both reports refuse such a zone before going near the database.
+	for name, h := range map[string]http.HandlerFunc{
+		"stats":       GetStatsHandler(nil, nil),
+		"time report": TimeReportHandler(nil),
+	} {
+		rec := httptest.NewRecorder()
+		h(rec, asUser(httptest.NewRequest(http.MethodGet, "/?tz=Local", nil), 1))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status %d, want 400", name, rec.Code)
+		}
+	}
+}
@@ -0,0 +1,239 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"gotasker/internal/auth"
+	"gotasker/internal/models"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// hashToken returns the hex SHA-256 an opaque token is stored under.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// newOpaqueToken returns 32 random bytes, base64url encoded.
+func newOpaqueToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// tokenEpoch returns the epoch new access tokens for the user are issued in.
+func tokenEpoch(ctx context.Context, q dbtx, userID int64) (int64, error) {
+	var epoch int64
+	err := q.QueryRowContext(ctx, `SELECT token_epoch FROM users WHERE id = $1`, userID).Scan(&epoch)
+	return epoch, err
+}
+
+// issueTokens signs an access token for the user's session and stores a new
+// refresh token in it.
+func issueTokens(ctx context.Context, q dbtx, userID int64, role string, sessionID int64) (models.TokenResponse, error) {
+	epoch, err := tokenEpoch(ctx, q, userID)
+	if err != nil {
+		return models.TokenResponse{}, err
+	}
+
+	now := time.Now()
+	resp := models.TokenResponse{
+		SessionID:        sessionID,
+		ExpiresAt:        now.Add(auth.AccessTokenTTL).UTC().Truncate(time.Second),
+		RefreshExpiresAt: now.Add(auth.RefreshTokenTTL).UTC().Truncate(time.Second),
+	}
+
+	resp.Token, err = auth.SignToken(auth.JWTClaims{
+		UserID:    userID,
+		Role:      role,
+		SessionID: sessionID,
+		Epoch:     epoch,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(resp.ExpiresAt),
+			IssuedAt:  jwt.NewNumericDate(now),
+		},
+	})
+	if err != nil {
+		return models.TokenResponse{}, err
+	}
+
+	resp.RefreshToken, err = newOpaqueToken()
+	if err != nil {
+		return models.TokenResponse{}, err
+	}
+	if _, err := q.ExecContext(ctx, `
+		INSERT INTO refresh_tokens (session_id, user_id, token_hash, expires_at)
+		VALUES ($1, $2, $3, $4)`, sessionID, userID, hashToken(resp.RefreshToken), resp.RefreshExpiresAt); err != nil {
+		return models.TokenResponse{}, err
+	}
+	return resp, nil
+}
+
+// startLogin records a new session for the user on the requesting device
+// and issues its first token pair.
+func startLogin(ctx context.Context, db *sql.DB, r *http.Request, userID int64, role string) (models.TokenResponse, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return models.TokenResponse{}, err
+	}
+	defer tx.Rollback()
+
+	var sessionID int64
+	if err := tx.QueryRowContext(ctx, `
+		INSERT INTO sessions (user_id, user_agent, ip)
+		VALUES ($1, $2, $3)
+		RETURNING id`, userID, userAgent(r), remoteIP(r)).Scan(&sessionID); err != nil {
+		return models.TokenResponse{}, err
+	}
+	resp, err := issueTokens(ctx, tx, userID, role, sessionID)
+	if err != nil {
+		return models.TokenResponse{}, err
+	}
+	return resp, tx.Commit()
+}
+
+// revokeSession revokes a session and every refresh token in it. Access
+// tokens of the session are rejected by auth.JWTMiddleware from then on.
+func revokeSession(ctx context.Context, q dbtx, sessionID int64) error {
+	if _, err := q.ExecContext(ctx, `
+		UPDATE sessions SET revoked_at = COALESCE(revoked_at, NOW()) WHERE id = $1`, sessionID); err != nil {
+		return err
+	}
+	_, err := q.ExecContext(ctx, `
+		UPDATE refresh_tokens SET revoked_at = NOW()
+		WHERE session_id = $1 AND revoked_at IS NULL`, sessionID)
+	return err
+}
+
+// revokeUserSessions revokes every session of the user.
+func revokeUserSessions(ctx context.Context, q dbtx, userID int64) error {
+	if _, err := q.ExecContext(ctx, `
+		UPDATE sessions SET revoked_at = NOW()
+		WHERE user_id = $1 AND revoked_at IS NULL`, userID); err != nil {
+		return err
+	}
+	_, err := q.ExecContext(ctx, `
+		UPDATE refresh_tokens SET revoked_at = NOW()
+		WHERE user_id = $1 AND revoked_at IS NULL`, userID)
+	return err
+}
+
+// RefreshTokenHandler exchanges a refresh token for a new token pair. Each
+// refresh token works once; presenting one that was already used revokes
+// its whole session, since either the client or an attacker holds a copy.
+func RefreshTokenHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req models.RefreshRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			WriteJson(w, http.StatusBadRequest, map[string]string{"error": "Invalid JSON"})
+			return
+		}
+		req.RefreshToken = strings.TrimSpace(req.RefreshToken)
+		if req.RefreshToken == "" {
+			WriteJson(w, http.StatusBadRequest, map[string]string{"error": "refresh_token is required"})
+			return
+		}
+
+		ctx := r.Context()
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer tx.Rollback()
+
+		var (
+			id, sessionID, userID int64
+			expiresAt             time.Time
+			used, revoked         bool
+			role                  string
+			disabled, mustReset   bool
+			verified              bool
+		)
+		err = tx.QueryRowContext(ctx, `
+			SELECT rt.id, rt.session_id, rt.user_id, rt.expires_at, rt.used_at IS NOT NULL,
+				rt.revoked_at IS NOT NULL OR s.revoked_at IS NOT NULL,
+				u.role, u.disabled_at IS NOT NULL, u.password_reset_required_at IS NOT NULL,
+				u.email_verified_at IS NOT NULL
+			FROM refresh_tokens rt
+			JOIN sessions s ON s.id = rt.session_id
+			JOIN users u ON u.id = rt.user_id
+			WHERE rt.token_hash = $1
+			FOR UPDATE OF rt`, hashToken(req.RefreshToken)).
+			Scan(&id, &sessionID, &userID, &expiresAt, &used, &revoked, &role, &disabled, &mustReset, &verified)
+		if errors.Is(err, sql.ErrNoRows) {
+			WriteJson(w, http.StatusUnauthorized, map[string]string{"error": "invalid refresh token"})
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		if used && !revoked {
+			log.Printf("refresh token reuse for user %d; revoking session %d", userID, sessionID)
+			if err := revokeSession(ctx, tx, sessionID); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			if err := tx.Commit(); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			WriteJson(w, http.StatusUnauthorized, map[string]string{"error": "refresh token reuse detected"})
+			return
+		}
+		if used || revoked || !time.Now().Before(expiresAt) {
+			WriteJson(w, http.StatusUnauthorized, map[string]string{"error": "invalid refresh token"})
+			return
+		}
+		if disabled {
+			WriteJson(w, http.StatusForbidden, map[string]string{"error": "account disabled"})
+			return
+		}
+		if mustReset {
+			WriteJson(w, http.StatusForbidden, map[string]string{"error": "password reset required"})
+			return
+		}
+		if !verified && auth.EmailVerificationPolicy == auth.VerificationBlock {
+			WriteJson(w, http.StatusForbidden, map[string]string{"error": "email not verified"})
+			return
+		}
+
+		if _, err := tx.ExecContext(ctx, `
+			UPDATE refresh_tokens SET used_at = NOW() WHERE id = $1`, id); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if _, err := tx.ExecContext(ctx, `
+			UPDATE sessions SET last_seen_at = NOW(), ip = $2 WHERE id = $1`, sessionID, remoteIP(r)); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		resp, err := issueTokens(ctx, tx, userID, role, sessionID)
+		if err != nil {
+			WriteJson(w, http.StatusInternalServerError, map[string]string{"error": "failed to generate token"})
+			return
+		}
+		if err := tx.Commit(); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		WriteJson(w, http.StatusOK, resp)
+	}
+}
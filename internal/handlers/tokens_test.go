@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"gotasker/internal/auth"
+	"gotasker/internal/models"
+)
+
+// refreshTokens presents refreshToken to RefreshTokenHandler.
+func refreshTokens(t *testing.T, db *sql.DB, refreshToken string) (int, models.TokenResponse) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	RefreshTokenHandler(db)(rec, httptest.NewRequest(http.MethodPost, "/token/refresh",
+		strings.NewReader(`{"refresh_token":"`+refreshToken+`"}`)))
+	var tokens models.TokenResponse
+	if rec.Code == http.StatusOK {
+		if err := json.Unmarshal(rec.Body.Bytes(), &tokens); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return rec.Code, tokens
+}
+
+func TestRefreshRotatesAndDetectsReuse(t *testing.T) {
+	db := testDB(t)
+	userID := createTestUser(t, db, "refresh@example.com")
+	first := loginTestUser(t, db, userID)
+	mw := auth.JWTMiddleware(db, nil)
+
+	code, second := refreshTokens(t, db, first.RefreshToken)
+	if code != http.StatusOK {
+		t.Fatalf("refresh: status %d", code)
+	}
+	if second.RefreshToken == "" || second.RefreshToken == first.RefreshToken {
+		t.Fatalf("refresh token not rotated: %q", second.RefreshToken)
+	}
+	if second.SessionID != first.SessionID {
+		t.Errorf("session %d, want %d", second.SessionID, first.SessionID)
+	}
+	if code := serveAuthed(mw, okHandler, withBearer(http.MethodGet, "/sessions", second.Token)); code != http.StatusOK {
+		t.Fatalf("new access token: status %d", code)
+	}
+
+	// The old token was used up; presenting it again means a copy is out
+	// there, so the whole session goes.
+	if code, _ := refreshTokens(t, db, first.RefreshToken); code != http.StatusUnauthorized {
+		t.Fatalf("reused token: status %d, want 401", code)
+	}
+	if code, _ := refreshTokens(t, db, second.RefreshToken); code != http.StatusUnauthorized {
+		t.Errorf("current token after reuse: status %d, want 401", code)
+	}
+	if code := serveAuthed(mw, okHandler, withBearer(http.MethodGet, "/sessions", second.Token)); code != http.StatusUnauthorized {
+		t.Errorf("access token after reuse: status %d, want 401", code)
+	}
+	var revoked bool
+	if err := db.QueryRow(`SELECT revoked_at IS NOT NULL FROM sessions WHERE id = $1`, first.SessionID).Scan(&revoked); err != nil {
+		t.Fatal(err)
+	}
+	if !revoked {
+		t.Error("session not revoked after reuse")
+	}
+}
+
+func TestRefreshRejectsExpiredToken(t *testing.T) {
+	db := testDB(t)
+	userID := createTestUser(t, db, "expired@example.com")
+	tokens := loginTestUser(t, db, userID)
+	if _, err := db.Exec(`
+		UPDATE refresh_tokens SET expires_at = NOW() - INTERVAL '1 second'
+		WHERE token_hash = $1`, hashToken(tokens.RefreshToken)); err != nil {
+		t.Fatal(err)
+	}
+
+	if code, _ := refreshTokens(t, db, tokens.RefreshToken); code != http.StatusUnauthorized {
+		t.Fatalf("expired token: status %d, want 401", code)
+	}
+	// Expiry is not reuse: the session lives on.
+	if code := serveAuthed(auth.JWTMiddleware(db, nil), okHandler, withBearer(http.MethodGet, "/sessions", tokens.Token)); code != http.StatusOK {
+		t.Errorf("access token after expired refresh: status %d, want 200", code)
+	}
+	if code, _ := refreshTokens(t, db, "not-a-token"); code != http.StatusUnauthorized {
+		t.Errorf("unknown token: status %d, want 401", code)
+	}
+}
@@ -0,0 +1,191 @@
+package handlers
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"gotasker/internal/auth"
+	"gotasker/internal/mail"
+	"gotasker/internal/middleware"
+	"gotasker/internal/models"
+)
+
+const (
+	// emailVerificationTTL is how long a verification link works.
+	emailVerificationTTL = 48 * time.Hour
+	// verificationResendInterval is the least time between two
+	// verification emails to the same user.
+	verificationResendInterval = time.Minute
+	// verificationSendTimeout bounds a background resend.
+	verificationSendTimeout = 30 * time.Second
+)
+
+var errInvalidVerification = errors.New("invalid or expired verification token")
+
+// emailVerificationKey signs verification tokens.
+var emailVerificationKey = func() []byte {
+	if k := os.Getenv("EMAIL_VERIFICATION_SIGNING_KEY"); k != "" {
+		return []byte(k)
+	}
+	return auth.JwtSecret
+}()
+
+// emailVerificationSignature covers the address too, so a token stops
+// working if the user's email changes.
+func emailVerificationSignature(userID int64, email string, expires int64) string {
+	m := hmac.New(sha256.New, emailVerificationKey)
+	fmt.Fprintf(m, "verify-email:%d:%s:%d", userID, email, expires)
+	return hex.EncodeToString(m.Sum(nil))
+}
+
+// newEmailVerificationToken returns a token of the form
+// <userID>.<expires>.<signature>.
+func newEmailVerificationToken(userID int64, email string, expiresAt time.Time) string {
+	expires := expiresAt.Unix()
+	return fmt.Sprintf("%d.%d.%s", userID, expires, emailVerificationSignature(userID, email, expires))
+}
+
+// sendEmailVerification mails the user a verification link and records
+// when it was sent.
+func sendEmailVerification(ctx context.Context, q dbtx, mailer mail.Mailer, userID int64, email string) error {
+	expiresAt := time.Now().Add(emailVerificationTTL)
+	token := newEmailVerificationToken(userID, email, expiresAt)
+	if err := mailer.Send(ctx, mail.Message{
+		To:      email,
+		Subject: "Verify your GoTasker email",
+		Body: fmt.Sprintf("Welcome to GoTasker!\n\n"+
+			"Confirm your email address at %s/verify-email?token=%s or send this token to POST /verify-email:\n%s\n\n"+
+			"The link expires on %s.\n",
+			middleware.FrontendOrigin, url.QueryEscape(token), token,
+			expiresAt.UTC().Format(time.RFC1123)),
+	}); err != nil {
+		return err
+	}
+	_, err := q.ExecContext(ctx, `UPDATE users SET verification_sent_at = NOW() WHERE id = $1`, userID)
+	return err
+}
+
+// verifyEmail checks token and marks the email it was issued for as
+// verified. Verifying twice is not an error.
+func verifyEmail(ctx context.Context, q dbtx, token string) error {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return errInvalidVerification
+	}
+	userID, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return errInvalidVerification
+	}
+	expires, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil || time.Now().Unix() > expires {
+		return errInvalidVerification
+	}
+
+	var email string
+	err = q.QueryRowContext(ctx, `SELECT email FROM users WHERE id = $1`, userID).Scan(&email)
+	if errors.Is(err, sql.ErrNoRows) {
+		return errInvalidVerification
+	}
+	if err != nil {
+		return err
+	}
+	if !hmac.Equal([]byte(parts[2]), []byte(emailVerificationSignature(userID, email, expires))) {
+		return errInvalidVerification
+	}
+
+	_, err = q.ExecContext(ctx, `
+		UPDATE users SET email_verified_at = COALESCE(email_verified_at, NOW()), updated_at = NOW()
+		WHERE id = $1`, userID)
+	return err
+}
+
+// VerifyEmailHandler confirms an email address with the token from the
+// verification link.
+func VerifyEmailHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req models.VerifyEmailRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			WriteJson(w, http.StatusBadRequest, map[string]string{"error": "Invalid JSON"})
+			return
+		}
+		req.Token = strings.TrimSpace(req.Token)
+		if req.Token == "" {
+			WriteJson(w, http.StatusBadRequest, map[string]string{"error": "token is required"})
+			return
+		}
+
+		if err := verifyEmail(r.Context(), db, req.Token); err != nil {
+			if errors.Is(err, errInvalidVerification) {
+				WriteJson(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+				return
+			}
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		WriteJson(w, http.StatusOK, map[string]string{"status": "email verified"})
+	}
+}
+
+// resendEmailVerification mails a new link to email if it belongs to an
+// unverified user who was not sent one within verificationResendInterval.
+// The send slot is claimed in one UPDATE so concurrent requests mail once.
+func resendEmailVerification(ctx context.Context, db *sql.DB, mailer mail.Mailer, email string) error {
+	var userID int64
+	err := db.QueryRowContext(ctx, `
+		UPDATE users SET verification_sent_at = NOW()
+		WHERE email = $1 AND email_verified_at IS NULL
+			AND (verification_sent_at IS NULL OR verification_sent_at < NOW() - $2 * INTERVAL '1 second')
+		RETURNING id`, email, int(verificationResendInterval/time.Second)).Scan(&userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return sendEmailVerification(ctx, db, mailer, userID, email)
+}
+
+// ResendVerificationHandler sends a new verification link, at most once per
+// verificationResendInterval. Like ForgotPasswordHandler it always answers
+// 202 and does the work in the background, so neither the status nor the
+// response time tells whether the address is registered.
+func ResendVerificationHandler(db *sql.DB, mailer mail.Mailer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req models.ResendVerificationRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			WriteJson(w, http.StatusBadRequest, map[string]string{"error": "Invalid JSON"})
+			return
+		}
+		req.Email = strings.TrimSpace(strings.ToLower(req.Email))
+		if req.Email == "" {
+			WriteJson(w, http.StatusBadRequest, map[string]string{"error": "email is required"})
+			return
+		}
+
+		go func() {
+			ctx, cancel := context.WithTimeout(context.Background(), verificationSendTimeout)
+			defer cancel()
+			if err := resendEmailVerification(ctx, db, mailer, req.Email); err != nil {
+				log.Printf("verification email for %s: %v", req.Email, err)
+			}
+		}()
+
+		WriteJson(w, http.StatusAccepted, map[string]string{
+			"status": "if the email is registered and unverified, a verification link has been sent",
+		})
+	}
+}
@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"gotasker/internal/mail"
+	"gotasker/internal/middleware"
+)
+
+// recordingMailer keeps sent messages for inspection.
+type recordingMailer struct {
+	mu   sync.Mutex
+	sent []mail.Message
+}
+
+func (m *recordingMailer) Send(_ context.Context, msg mail.Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sent = append(m.sent, msg)
+	return nil
+}
+
+func (m *recordingMailer) count() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.sent)
+}
+
+// waitForMail waits for the background send to reach want messages.
+func (m *recordingMailer) waitForMail(t *testing.T, want int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for m.count() < want && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := m.count(); got != want {
+		t.Fatalf("sent %d emails, want %d", got, want)
+	}
+}
+
+func TestResendVerificationAlwaysAccepts(t *testing.T) {
+	db := testDB(t)
+	if _, err := db.Exec(`
+		INSERT INTO users (email, password_hash) VALUES ('new@example.com', 'not-a-hash')`); err != nil {
+		t.Fatal(err)
+	}
+	createTestUser(t, db, "verified@example.com")
+	mailer := &recordingMailer{}
+	handler := ResendVerificationHandler(db, mailer)
+
+	resend := func(email string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/verify-email/resend",
+			strings.NewReader(`{"email":"`+email+`"}`))
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+		return rec
+	}
+
+	// Unknown, verified and unverified addresses, and a second request
+	// inside the resend interval, all get the same answer.
+	var bodies []string
+	for _, email := range []string{"nobody@example.com", "verified@example.com", "new@example.com", "NEW@example.com"} {
+		rec := resend(email)
+		if rec.Code != http.StatusAccepted {
+			t.Errorf("%s: status %d, want 202", email, rec.Code)
+		}
+		if rec.Header().Get("Retry-After") != "" {
+			t.Errorf("%s: Retry-After set", email)
+		}
+		bodies = append(bodies, rec.Body.String())
+		if email == "new@example.com" {
+			mailer.waitForMail(t, 1)
+		}
+	}
+	for _, b := range bodies[1:] {
+		if b != bodies[0] {
+			t.Errorf("responses differ: %q and %q", bodies[0], b)
+		}
+	}
+
+	// The rate limit still applies in the background.
+	time.Sleep(100 * time.Millisecond)
+	if got := mailer.count(); got != 1 {
+		t.Fatalf("sent %d emails, want 1", got)
+	}
+	if to := mailer.sent[0].To; to != "new@example.com" {
+		t.Errorf("sent to %s, want new@example.com", to)
+	}
+	if body := mailer.sent[0].Body; !strings.Contains(body, middleware.FrontendOrigin+"/verify-email?token=") {
+		t.Errorf("email does not link to the app's verification page:\n%s", body)
+	}
+}
@@ -0,0 +1,260 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"gotasker/internal/models"
+	cache "gotasker/internal/redis"
+	"gotasker/internal/workflow"
+
+	"github.com/redis/go-redis/v9"
+)
+
+var errUnknownStatus = errors.New("unknown status")
+
+// loadWorkflow returns the user's workflow, or the default one if they have
+// not defined their own.
+func loadWorkflow(ctx context.Context, q dbtx, userID int64) (models.Workflow, error) {
+	var raw []byte
+	err := q.QueryRowContext(ctx, `
+		SELECT statuses FROM user_workflows WHERE user_id = $1`, userID).Scan(&raw)
+	if errors.Is(err, sql.ErrNoRows) {
+		return workflow.Default(), nil
+	}
+	if err != nil {
+		return models.Workflow{}, err
+	}
+
+	var wf models.Workflow
+	if err := json.Unmarshal(raw, &wf.Statuses); err != nil {
+		return models.Workflow{}, err
+	}
+	return wf, nil
+}
+
+func GetWorkflowHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := currentUserID(r)
+		if !ok {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		wf, err := loadWorkflow(r.Context(), db, userID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		WriteJson(w, http.StatusOK, wf)
+	}
+}
+
+// UpdateWorkflowHandler replaces the user's workflow. Tasks sitting in a
+// status that is being removed have to be moved with "remap", otherwise the
+// update is refused with 409 and the affected statuses are listed.
+func UpdateWorkflowHandler(db *sql.DB, rdb *redis.Client) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req models.UpdateWorkflowRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			WriteJson(w, http.StatusBadRequest, map[string]string{"error": "Invalid Json"})
+			return
+		}
+
+		wf := models.Workflow{Statuses: req.Statuses}
+		if err := workflow.Validate(&wf); err != nil {
+			WriteJson(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		for from, to := range req.Remap {
+			if _, ok := workflow.Find(wf, to); !ok {
+				WriteJson(w, http.StatusBadRequest, map[string]string{"error": "remap target " + strconv.Quote(from) + " -> " + strconv.Quote(to) + " is not a status"})
+				return
+			}
+		}
+
+		userID, ok := currentUserID(r)
+		if !ok {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		ctx := r.Context()
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer tx.Rollback()
+
+		// Move tasks out of statuses that are going away.
+		for from, to := range req.Remap {
+			if _, err := tx.ExecContext(ctx, `
+				UPDATE tasks SET
+					status = $1,
+					done = $2,
+					completed_at = CASE
+						WHEN $2 = done THEN completed_at
+						WHEN $2 THEN NOW()
+						ELSE NULL
+					END,
+					updated_at = NOW()
+				WHERE user_id = $3 AND status = $4`,
+				to, workflow.IsDone(wf, to), userID, from); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+
+		keys := make([]string, 0, len(wf.Statuses))
+		for _, s := range wf.Statuses {
+			keys = append(keys, s.Key)
+		}
+		rows, err := tx.QueryContext(ctx, `
+			SELECT status, COUNT(*)
+			FROM tasks
+			WHERE user_id = $1 AND NOT (status = ANY($2))
+			GROUP BY status`, userID, keys)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		orphaned := map[string]int{}
+		for rows.Next() {
+			var (
+				status string
+				n      int
+			)
+			if err := rows.Scan(&status, &n); err != nil {
+				rows.Close()
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			orphaned[status] = n
+		}
+		rows.Close()
+		if len(orphaned) > 0 {
+			WriteJson(w, http.StatusConflict, map[string]any{
+				"error":    "tasks still use removed statuses; supply remap",
+				"orphaned": orphaned,
+			})
+			return
+		}
+
+		statuses, err := json.Marshal(wf.Statuses)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO user_workflows (user_id, statuses)
+			VALUES ($1, $2::jsonb)
+			ON CONFLICT (user_id) DO UPDATE
+			SET statuses = EXCLUDED.statuses, updated_at = NOW()`,
+			userID, string(statuses)); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		if err := tx.Commit(); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		if len(req.Remap) > 0 {
+			if err := cache.DeletTaks(ctx, rdb, userID); err != nil {
+				log.Printf("Redis DEL failed: %v", err)
+			}
+		}
+
+		WriteJson(w, http.StatusOK, wf)
+	}
+}
+
+// GetBoardHandler returns the user's top-level tasks grouped into workflow
+// columns. Each column reports its full count and whether it exceeds its WIP
+// limit; at most ?limit= tasks (default 50) are listed per column.
+func GetBoardHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		limit := 50
+		if v := strings.TrimSpace(r.URL.Query().Get("limit")); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n <= 0 || n > 200 {
+				WriteJson(w, http.StatusBadRequest, map[string]string{"error": "invalid limit"})
+				return
+			}
+			limit = n
+		}
+
+		userID, ok := currentUserID(r)
+		if !ok {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		ctx := r.Context()
+		wf, err := loadWorkflow(ctx, db, userID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		board := models.Board{Columns: make([]models.BoardColumn, len(wf.Statuses))}
+		index := make(map[string]int, len(wf.Statuses))
+		for i, s := range wf.Statuses {
+			board.Columns[i] = models.BoardColumn{WorkflowStatus: s, Tasks: []models.Task{}}
+			index[s.Key] = i
+		}
+
+		rows, err := db.QueryContext(ctx, `
+			SELECT `+taskColumns+`, total
+			FROM (
+				SELECT *,
+					COUNT(*) OVER (PARTITION BY status) AS total,
+					ROW_NUMBER() OVER (PARTITION BY status ORDER BY position NULLS LAST, created_at DESC, id DESC) AS rn
+				FROM tasks
+				WHERE user_id = $1 AND parent_id IS NULL AND archived_at IS NULL
+			) t
+			WHERE rn <= $2
+			ORDER BY status, rn`, userID, limit)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var total int
+			t, err := scanTask(scanWithExtra(rows, &total))
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			i, ok := index[t.Status]
+			if !ok {
+				continue
+			}
+			col := &board.Columns[i]
+			col.Count = total
+			col.Tasks = append(col.Tasks, t)
+		}
+		if err := rows.Err(); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		for i := range board.Columns {
+			col := &board.Columns[i]
+			col.OverLimit = col.WIPLimit != nil && col.Count > *col.WIPLimit
+		}
+
+		WriteJson(w, http.StatusOK, board)
+	}
+}
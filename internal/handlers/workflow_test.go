@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestUpdateWorkflowRejectsInvalid(t *testing.T) {
+	// Validation runs before the database is touched.
+	for name, body := range map[string]string{
+		"bad json":     `{`,
+		"no done":      `{"statuses":[{"key":"todo","category":"todo"}]}`,
+		"bad target":   `{"statuses":[{"key":"todo","category":"todo","transitions":["review"]},{"key":"done","category":"done"}]}`,
+		"bad remap to": `{"statuses":[{"key":"todo","category":"todo"},{"key":"done","category":"done"}],"remap":{"doing":"review"}}`,
+	} {
+		rec := serve(UpdateWorkflowHandler(nil, nil), newJSONRequest(http.MethodPut, "/workflow", body))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status %d, want 400: %s", name, rec.Code, rec.Body)
+		}
+	}
+}
+
+func TestPatchTaskEnforcesTransitions(t *testing.T) {
+	db := testDB(t)
+	owner := createTestUser(t, db, "owner@example.com")
+	other := createTestUser(t, db, "other@example.com")
+
+	rec := serve(UpdateWorkflowHandler(db, nil), asUser(newJSONRequest(http.MethodPut, "/workflow", `{"statuses":[
+		{"key":"todo","category":"todo","transitions":["doing"]},
+		{"key":"doing","category":"in_progress","transitions":["done"]},
+		{"key":"done","category":"done","transitions":["todo"]}]}`), owner))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("update workflow: status %d: %s", rec.Code, rec.Body)
+	}
+	taskID := insertTestTask(t, db, owner, "Ship it", nil, []string{})
+
+	patch := func(userID int64, body string) (int, string) {
+		rec := serve(PatchTaskHandlerDB(db, nil), withTaskID(asUser(newJSONRequest(http.MethodPatch, "/tasksdb/x", body), userID), taskID))
+		return rec.Code, rec.Body.String()
+	}
+
+	if code, body := patch(owner, `{"status":"done"}`); code != http.StatusConflict || !strings.Contains(body, "transition not allowed") {
+		t.Errorf("todo -> done: status %d: %s", code, body)
+	}
+	// The legacy flag goes through the same check.
+	if code, body := patch(owner, `{"done":true}`); code != http.StatusConflict {
+		t.Errorf("done=true from todo: status %d: %s", code, body)
+	}
+	if code, body := patch(owner, `{"status":"doing","done":true}`); code != http.StatusBadRequest || !strings.Contains(body, "disagree") {
+		t.Errorf("status and done disagree: status %d: %s", code, body)
+	}
+	if code, body := patch(owner, `{"status":"review"}`); code != http.StatusBadRequest {
+		t.Errorf("unknown status: status %d: %s", code, body)
+	}
+
+	// Someone outside the workspace cannot see the task at all.
+	if code, body := patch(other, `{"status":"doing"}`); code != http.StatusNotFound {
+		t.Errorf("another user: status %d, want 404: %s", code, body)
+	}
+
+	if code, body := patch(owner, `{"status":"doing"}`); code != http.StatusOK {
+		t.Fatalf("todo -> doing: status %d: %s", code, body)
+	}
+	if code, body := patch(owner, `{"done":true}`); code != http.StatusOK || !strings.Contains(body, `"status":"done"`) {
+		t.Errorf("doing -> done: status %d: %s", code, body)
+	}
+}
+
+func TestViewerCannotMoveTask(t *testing.T) {
+	db := testDB(t)
+	owner := createTestUser(t, db, "owner@example.com")
+	viewer := createTestUser(t, db, "viewer@example.com")
+	wsID := sharedWorkspace(t, db, owner, map[int64]string{viewer: "viewer"})
+
+	var taskID int64
+	if err := db.QueryRow(`
+		INSERT INTO tasks (user_id, workspace_id, title) VALUES ($1, $2, 'Shared')
+		RETURNING id`, owner, wsID).Scan(&taskID); err != nil {
+		t.Fatal(err)
+	}
+
+	rec := serve(PatchTaskHandlerDB(db, nil), withTaskID(asUser(newJSONRequest(http.MethodPatch, "/tasksdb/x", `{"status":"done"}`), viewer), taskID))
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("viewer: status %d, want 403: %s", rec.Code, rec.Body)
+	}
+}
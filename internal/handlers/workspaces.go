@@ -0,0 +1,540 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"gotasker/internal/blob"
+	"gotasker/internal/models"
+	cache "gotasker/internal/redis"
+	"gotasker/internal/workspace"
+
+	"github.com/go-chi/chi"
+	"github.com/redis/go-redis/v9"
+)
+
+const maxWorkspaceName = 100
+
+var errLastOwner = errors.New("a workspace needs at least one owner")
+
+// loadWorkspace returns the workspace with the user's role in it, or
+// sql.ErrNoRows if it does not exist or the user is not a member.
+func loadWorkspace(ctx context.Context, q dbtx, userID, id int64) (models.Workspace, error) {
+	var ws models.Workspace
+	err := q.QueryRowContext(ctx, `
+		SELECT w.id, w.name, w.personal, m.role, w.created_at, w.updated_at
+		FROM workspaces w
+		JOIN workspace_members m ON m.workspace_id = w.id AND m.user_id = $2
+		WHERE w.id = $1`, id, userID).Scan(&ws.ID, &ws.Name, &ws.Personal, &ws.Role, &ws.CreatedAt, &ws.UpdatedAt)
+	return ws, err
+}
+
+// workspaceFromURL parses {id} and loads the workspace for the current
+// user, answering the request itself when that fails.
+func workspaceFromURL(w http.ResponseWriter, r *http.Request, db *sql.DB) (models.Workspace, int64, bool) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		WriteJson(w, http.StatusBadRequest, map[string]string{"error": "Invalid workspace ID"})
+		return models.Workspace{}, 0, false
+	}
+
+	userID, ok := currentUserID(r)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return models.Workspace{}, 0, false
+	}
+
+	ws, err := loadWorkspace(r.Context(), db, userID, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		WriteJson(w, http.StatusNotFound, map[string]string{"error": "Workspace not found"})
+		return models.Workspace{}, 0, false
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return models.Workspace{}, 0, false
+	}
+	return ws, userID, true
+}
+
+func normalizeWorkspaceName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", errors.New("name is required")
+	}
+	if len(name) > maxWorkspaceName {
+		return "", errors.New("name is too long")
+	}
+	return name, nil
+}
+
+// workspaceMemberIDs returns the IDs of every member of the workspace.
+func workspaceMemberIDs(ctx context.Context, q dbtx, workspaceID int64) ([]int64, error) {
+	return queryIDs(ctx, q, `
+		SELECT user_id FROM workspace_members WHERE workspace_id = $1`, workspaceID)
+}
+
+// ListWorkspacesHandler lists the workspaces the user belongs to, the
+// personal one first.
+func ListWorkspacesHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := currentUserID(r)
+		if !ok {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		ctx := r.Context()
+		if _, err := ensurePersonalWorkspace(ctx, db, userID); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		rows, err := db.QueryContext(ctx, `
+			SELECT w.id, w.name, w.personal, m.role, w.created_at, w.updated_at
+			FROM workspaces w
+			JOIN workspace_members m ON m.workspace_id = w.id
+			WHERE m.user_id = $1
+			ORDER BY w.personal DESC, LOWER(w.name), w.id`, userID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer rows.Close()
+
+		list := make([]models.Workspace, 0)
+		for rows.Next() {
+			var ws models.Workspace
+			if err := rows.Scan(&ws.ID, &ws.Name, &ws.Personal, &ws.Role, &ws.CreatedAt, &ws.UpdatedAt); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			list = append(list, ws)
+		}
+		if err := rows.Err(); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		WriteJson(w, http.StatusOK, list)
+	}
+}
+
+// CreateWorkspaceHandler creates a shared workspace owned by the user.
+func CreateWorkspaceHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req models.WorkspaceRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			WriteJson(w, http.StatusBadRequest, map[string]string{"error": "Invalid Json"})
+			return
+		}
+		name, err := normalizeWorkspaceName(req.Name)
+		if err != nil {
+			WriteJson(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+
+		userID, ok := currentUserID(r)
+		if !ok {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		ctx := r.Context()
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer tx.Rollback()
+
+		ws := models.Workspace{Name: name, Role: workspace.RoleOwner}
+		if err := tx.QueryRowContext(ctx, `
+			INSERT INTO workspaces (name, created_by)
+			VALUES ($1, $2)
+			RETURNING id, created_at, updated_at`, name, userID).Scan(&ws.ID, &ws.CreatedAt, &ws.UpdatedAt); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO workspace_members (workspace_id, user_id, role)
+			VALUES ($1, $2, $3)`, ws.ID, userID, workspace.RoleOwner); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if err := tx.Commit(); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		WriteJson(w, http.StatusCreated, ws)
+	}
+}
+
+func GetWorkspaceHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ws, _, ok := workspaceFromURL(w, r, db)
+		if !ok {
+			return
+		}
+		WriteJson(w, http.StatusOK, ws)
+	}
+}
+
+// UpdateWorkspaceHandler renames a workspace. Admins and owners only.
+func UpdateWorkspaceHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req models.WorkspaceRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			WriteJson(w, http.StatusBadRequest, map[string]string{"error": "Invalid Json"})
+			return
+		}
+		name, err := normalizeWorkspaceName(req.Name)
+		if err != nil {
+			WriteJson(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+
+		ws, _, ok := workspaceFromURL(w, r, db)
+		if !ok {
+			return
+		}
+		if !workspace.CanManage(ws.Role) {
+			WriteJson(w, http.StatusForbidden, map[string]string{"error": "insufficient role"})
+			return
+		}
+
+		if err := db.QueryRowContext(r.Context(), `
+			UPDATE workspaces SET name = $1, updated_at = NOW()
+			WHERE id = $2
+			RETURNING updated_at`, name, ws.ID).Scan(&ws.UpdatedAt); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		ws.Name = name
+
+		WriteJson(w, http.StatusOK, ws)
+	}
+}
+
+// DeleteWorkspaceHandler deletes a shared workspace with all its tasks and
+// their attachment blobs. Owners only; personal workspaces cannot be
+// deleted.
+func DeleteWorkspaceHandler(db *sql.DB, rdb *redis.Client, store blob.BlobStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ws, _, ok := workspaceFromURL(w, r, db)
+		if !ok {
+			return
+		}
+		if ws.Role != workspace.RoleOwner {
+			WriteJson(w, http.StatusForbidden, map[string]string{"error": "only owners can delete a workspace"})
+			return
+		}
+		if ws.Personal {
+			WriteJson(w, http.StatusBadRequest, map[string]string{"error": "personal workspace cannot be deleted"})
+			return
+		}
+
+		ctx := r.Context()
+
+		// Collect blob keys and affected users before the rows cascade away.
+		rows, err := db.QueryContext(ctx, `
+			SELECT a.storage_key
+			FROM attachments a
+			JOIN tasks t ON t.id = a.task_id
+			WHERE t.workspace_id = $1`, ws.ID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		var keys []string
+		for rows.Next() {
+			var key string
+			if err := rows.Scan(&key); err != nil {
+				rows.Close()
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			keys = append(keys, key)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		members, err := workspaceMemberIDs(ctx, db, ws.ID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		grantees, err := workspaceGranteeIDs(ctx, db, ws.ID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		if _, err := db.ExecContext(ctx, `DELETE FROM workspaces WHERE id = $1`, ws.ID); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		if err := cache.DeleteWorkspaceTasks(ctx, rdb, ws.ID); err != nil {
+			log.Printf("Redis DEL failed: %v", err)
+		}
+		invalidateUsers(ctx, rdb, append(members, grantees...)...)
+		deleteBlobs(ctx, store, keys)
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func ListWorkspaceMembersHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ws, _, ok := workspaceFromURL(w, r, db)
+		if !ok {
+			return
+		}
+
+		rows, err := db.QueryContext(r.Context(), `
+			SELECT m.user_id, u.email, m.role, m.created_at
+			FROM workspace_members m
+			JOIN users u ON u.id = m.user_id
+			WHERE m.workspace_id = $1
+			ORDER BY m.created_at, m.user_id`, ws.ID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer rows.Close()
+
+		members := make([]models.WorkspaceMember, 0)
+		for rows.Next() {
+			var m models.WorkspaceMember
+			if err := rows.Scan(&m.UserID, &m.Email, &m.Role, &m.CreatedAt); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			members = append(members, m)
+		}
+		if err := rows.Err(); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		WriteJson(w, http.StatusOK, members)
+	}
+}
+
+// AddWorkspaceMemberHandler adds an existing user by email. Role defaults
+// to member; only owners may add owners or admins.
+func AddWorkspaceMemberHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req models.AddMemberRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			WriteJson(w, http.StatusBadRequest, map[string]string{"error": "Invalid Json"})
+			return
+		}
+		req.Email = strings.TrimSpace(strings.ToLower(req.Email))
+		if req.Email == "" {
+			WriteJson(w, http.StatusBadRequest, map[string]string{"error": "email is required"})
+			return
+		}
+		if req.Role == "" {
+			req.Role = workspace.RoleMember
+		}
+		if !workspace.ValidRole(req.Role) {
+			WriteJson(w, http.StatusBadRequest, map[string]string{"error": "invalid role"})
+			return
+		}
+
+		ws, _, ok := workspaceFromURL(w, r, db)
+		if !ok {
+			return
+		}
+		if ws.Personal {
+			WriteJson(w, http.StatusBadRequest, map[string]string{"error": "personal workspace cannot have members"})
+			return
+		}
+		if !workspace.CanAssign(ws.Role, "", req.Role) {
+			WriteJson(w, http.StatusForbidden, map[string]string{"error": "insufficient role"})
+			return
+		}
+
+		m := models.WorkspaceMember{Email: req.Email, Role: req.Role}
+		err := db.QueryRowContext(r.Context(), `
+			INSERT INTO workspace_members (workspace_id, user_id, role)
+			SELECT $1, id, $3 FROM users WHERE email = $2
+			RETURNING user_id, created_at`, ws.ID, req.Email, req.Role).Scan(&m.UserID, &m.CreatedAt)
+		if errors.Is(err, sql.ErrNoRows) {
+			WriteJson(w, http.StatusNotFound, map[string]string{"error": "user not found"})
+			return
+		}
+		if err != nil {
+			if strings.Contains(err.Error(), "duplicate key") {
+				WriteJson(w, http.StatusConflict, map[string]string{"error": "user is already a member"})
+				return
+			}
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		WriteJson(w, http.StatusCreated, m)
+	}
+}
+
+// memberFromURL parses {userID} and returns that member's current role, or
+// "" if they are not a member.
+func memberFromURL(r *http.Request, q dbtx, workspaceID int64) (int64, string, error) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "userID"), 10, 64)
+	if err != nil {
+		return 0, "", err
+	}
+	role, err := workspaceRole(r.Context(), q, id, workspaceID)
+	return id, role, err
+}
+
+// ensureOwnerRemains fails with errLastOwner if the workspace has no owner
+// left. It is called inside the transaction that changed the members.
+func ensureOwnerRemains(ctx context.Context, tx *sql.Tx, workspaceID int64) error {
+	var owners int
+	if err := tx.QueryRowContext(ctx, `
+		SELECT COUNT(*) FROM workspace_members
+		WHERE workspace_id = $1 AND role = 'owner'`, workspaceID).Scan(&owners); err != nil {
+		return err
+	}
+	if owners == 0 {
+		return errLastOwner
+	}
+	return nil
+}
+
+// UpdateWorkspaceMemberHandler changes a member's role.
+func UpdateWorkspaceMemberHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req models.UpdateMemberRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			WriteJson(w, http.StatusBadRequest, map[string]string{"error": "Invalid Json"})
+			return
+		}
+		if !workspace.ValidRole(req.Role) {
+			WriteJson(w, http.StatusBadRequest, map[string]string{"error": "invalid role"})
+			return
+		}
+
+		ws, _, ok := workspaceFromURL(w, r, db)
+		if !ok {
+			return
+		}
+
+		ctx := r.Context()
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer tx.Rollback()
+
+		memberID, current, err := memberFromURL(r, tx, ws.ID)
+		if err != nil {
+			WriteJson(w, http.StatusBadRequest, map[string]string{"error": "Invalid user ID"})
+			return
+		}
+		if current == "" {
+			WriteJson(w, http.StatusNotFound, map[string]string{"error": "Member not found"})
+			return
+		}
+		if !workspace.CanAssign(ws.Role, current, req.Role) {
+			WriteJson(w, http.StatusForbidden, map[string]string{"error": "insufficient role"})
+			return
+		}
+
+		var m models.WorkspaceMember
+		if err := tx.QueryRowContext(ctx, `
+			UPDATE workspace_members m SET role = $1
+			FROM users u
+			WHERE m.workspace_id = $2 AND m.user_id = $3 AND u.id = m.user_id
+			RETURNING m.user_id, u.email, m.role, m.created_at`, req.Role, ws.ID, memberID).Scan(&m.UserID, &m.Email, &m.Role, &m.CreatedAt); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if err := ensureOwnerRemains(ctx, tx, ws.ID); err != nil {
+			if errors.Is(err, errLastOwner) {
+				WriteJson(w, http.StatusConflict, map[string]string{"error": err.Error()})
+				return
+			}
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if err := tx.Commit(); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		WriteJson(w, http.StatusOK, m)
+	}
+}
+
+// RemoveWorkspaceMemberHandler removes a member. Anyone may leave; removing
+// others needs the same rights as changing their role.
+func RemoveWorkspaceMemberHandler(db *sql.DB, rdb *redis.Client) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ws, userID, ok := workspaceFromURL(w, r, db)
+		if !ok {
+			return
+		}
+		if ws.Personal {
+			WriteJson(w, http.StatusBadRequest, map[string]string{"error": "cannot leave a personal workspace"})
+			return
+		}
+
+		ctx := r.Context()
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer tx.Rollback()
+
+		memberID, current, err := memberFromURL(r, tx, ws.ID)
+		if err != nil {
+			WriteJson(w, http.StatusBadRequest, map[string]string{"error": "Invalid user ID"})
+			return
+		}
+		if current == "" {
+			WriteJson(w, http.StatusNotFound, map[string]string{"error": "Member not found"})
+			return
+		}
+		if memberID != userID && !workspace.CanAssign(ws.Role, current, current) {
+			WriteJson(w, http.StatusForbidden, map[string]string{"error": "insufficient role"})
+			return
+		}
+
+		if _, err := tx.ExecContext(ctx, `
+			DELETE FROM workspace_members WHERE workspace_id = $1 AND user_id = $2`, ws.ID, memberID); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if err := ensureOwnerRemains(ctx, tx, ws.ID); err != nil {
+			if errors.Is(err, errLastOwner) {
+				WriteJson(w, http.StatusConflict, map[string]string{"error": err.Error()})
+				return
+			}
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if err := tx.Commit(); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		invalidateUsers(ctx, rdb, memberID)
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,157 @@
+package interchange
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"gotasker/internal/models"
+)
+
+// GitHub Issues JSON: an array of issues as returned by the REST API
+// (GET /repos/{owner}/{repo}/issues) or by `gh issue list --json ...`.
+//
+// Mapping onto tasks:
+//   - title                 -> Title
+//   - state closed          -> Done, closed_at -> CompletedAt
+//   - created_at            -> CreatedAt
+//   - labels, milestone     -> Tags
+//   - milestone due_on      -> DueAt
+//   - "- [ ]" body items    -> subtasks of the issue, titles taken verbatim
+//
+// Pull requests, which the REST API lists alongside issues, are skipped and
+// counted under "issues.pull_request".
+
+type githubLabel struct {
+	Name string `json:"name"`
+}
+
+type githubIssue struct {
+	Number    flexID          `json:"number"`
+	Title     string          `json:"title"`
+	Body      string          `json:"body"`
+	State     string          `json:"state"`
+	Labels    json.RawMessage `json:"labels"`
+	CreatedAt string          `json:"created_at"`
+	ClosedAt  string          `json:"closed_at"`
+	Milestone *struct {
+		Title string `json:"title"`
+		DueOn string `json:"due_on"`
+		// gh CLI spelling.
+		DueOnCamel string `json:"dueOn"`
+	} `json:"milestone"`
+	PullRequest json.RawMessage `json:"pull_request"`
+
+	// gh CLI spellings.
+	CreatedAtCamel string `json:"createdAt"`
+	ClosedAtCamel  string `json:"closedAt"`
+}
+
+var githubMapped = []string{
+	"number", "title", "body", "state", "labels", "created_at", "closed_at", "milestone",
+	"createdAt", "closedAt", "pull_request",
+	// Bookkeeping fields with no user-visible meaning.
+	"id", "node_id", "url", "html_url", "repository_url", "labels_url", "comments_url",
+	"events_url", "timeline_url", "updated_at", "updatedAt", "locked", "author_association",
+	"state_reason", "reactions", "performed_via_github_app",
+}
+
+// ImportGitHub reads a JSON array of GitHub issues.
+func ImportGitHub(r io.Reader) (*ImportResult, error) {
+	var issues []json.RawMessage
+	if err := json.NewDecoder(r).Decode(&issues); err != nil {
+		return nil, fmt.Errorf("invalid GitHub issues JSON: %w", err)
+	}
+
+	res := &ImportResult{Unmapped: map[string]int{}}
+
+	for _, raw := range issues {
+		var is githubIssue
+		fields, err := decodeBoth(raw, &is)
+		if err != nil {
+			return nil, fmt.Errorf("invalid GitHub issue: %w", err)
+		}
+
+		if !isEmptyJSON(is.PullRequest) {
+			res.Unmapped["issues.pull_request"]++
+			continue
+		}
+		noteUnmapped(res, "issues", fields, githubMapped...)
+
+		if is.Title == "" {
+			continue
+		}
+
+		tags, err := githubLabels(is.Labels)
+		if err != nil {
+			return nil, fmt.Errorf("invalid labels on issue %s: %w", is.Number, err)
+		}
+
+		t := models.Task{
+			Title:       is.Title,
+			Done:        strings.EqualFold(is.State, "closed"),
+			CompletedAt: parseTime(firstNonEmpty(is.ClosedAt, is.ClosedAtCamel)),
+			CreatedAt:   derefTime(parseTime(firstNonEmpty(is.CreatedAt, is.CreatedAtCamel))),
+		}
+		if is.Milestone != nil {
+			tags = append(tags, is.Milestone.Title)
+			t.DueAt = parseTime(firstNonEmpty(is.Milestone.DueOn, is.Milestone.DueOnCamel))
+		}
+		t.Tags = models.NormalizeTags(tags)
+
+		ref := "issue-" + string(is.Number)
+		res.Records = append(res.Records, Record{Ref: ref, Task: t})
+
+		// Task-list items in the body become subtasks; the rest of the body
+		// has nowhere to go. Their text is kept as written: an @mention or
+		// "due:" in an issue is not one of our tokens.
+		for _, item := range parseChecklist(is.Body) {
+			res.Records = append(res.Records, Record{ParentRef: ref, Task: item})
+		}
+		if hasProse(is.Body) {
+			res.Unmapped["issues.body"]++
+		}
+	}
+	return res, nil
+}
+
+// githubLabels accepts labels as objects ({"name": ...}) or plain strings.
+func githubLabels(raw json.RawMessage) ([]string, error) {
+	if isEmptyJSON(raw) {
+		return nil, nil
+	}
+	var objs []githubLabel
+	if err := json.Unmarshal(raw, &objs); err == nil {
+		names := make([]string, 0, len(objs))
+		for _, l := range objs {
+			names = append(names, l.Name)
+		}
+		return names, nil
+	}
+	var names []string
+	if err := json.Unmarshal(raw, &names); err != nil {
+		return nil, err
+	}
+	return names, nil
+}
+
+// hasProse reports whether body has any non-blank line that is not a
+// checklist item.
+func hasProse(body string) bool {
+	for _, line := range strings.Split(body, "\n") {
+		if strings.TrimSpace(line) != "" && !checklistItem.MatchString(line) {
+			return true
+		}
+	}
+	return false
+}
+
+func firstNonEmpty(vals ...string) string {
+	for _, v := range vals {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,94 @@
+package interchange
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"gotasker/internal/models"
+)
+
+const githubIssues = `[
+  {
+    "number": 7,
+    "title": "Ship the importer",
+    "state": "closed",
+    "created_at": "2026-01-02T10:00:00Z",
+    "closed_at": "2026-01-04T16:30:00Z",
+    "labels": [{"name": "backend"}, {"name": "p1"}],
+    "milestone": {"title": "v2", "due_on": "2026-02-01T08:00:00Z"},
+    "body": "Notes first.\r\n\r\n- [x] Ask @octocat for review\r\n- [ ] Update docs due:someday +later\r\n- [ ]   \r\n",
+    "assignee": {"login": "octocat"},
+    "html_url": "https://github.com/acme/app/issues/7"
+  },
+  {
+    "number": 8,
+    "title": "Not an issue",
+    "state": "open",
+    "pull_request": {"url": "https://api.github.com/repos/acme/app/pulls/8"}
+  },
+  {
+    "number": 9,
+    "title": "From the gh CLI",
+    "state": "OPEN",
+    "createdAt": "2026-01-05T09:00:00Z",
+    "labels": ["bug"]
+  }
+]`
+
+func TestImportGitHub(t *testing.T) {
+	res, err := Import(FormatGitHubName, strings.NewReader(githubIssues))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	at := func(s string) time.Time {
+		v, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return v.UTC()
+	}
+	closed := at("2026-01-04T16:30:00Z")
+	due := at("2026-02-01T08:00:00Z")
+
+	want := []Record{
+		{Ref: "issue-7", Task: models.Task{
+			Title:       "Ship the importer",
+			Done:        true,
+			CompletedAt: &closed,
+			CreatedAt:   at("2026-01-02T10:00:00Z"),
+			DueAt:       &due,
+			Tags:        []string{"backend", "p1", "v2"},
+		}},
+		// Checklist text is not read for our inline tokens.
+		{ParentRef: "issue-7", Task: models.Task{Title: "Ask @octocat for review", Done: true, Tags: []string{}}},
+		{ParentRef: "issue-7", Task: models.Task{Title: "Update docs due:someday +later", Tags: []string{}}},
+		{Ref: "issue-9", Task: models.Task{
+			Title:     "From the gh CLI",
+			CreatedAt: at("2026-01-05T09:00:00Z"),
+			Tags:      []string{"bug"},
+		}},
+	}
+	if !reflect.DeepEqual(res.Records, want) {
+		t.Errorf("records differ\ngot:  %+v\nwant: %+v", res.Records, want)
+	}
+
+	wantUnmapped := map[string]int{
+		"issues.pull_request": 1,
+		"issues.body":         1,
+		"issues.assignee":     1,
+	}
+	if !reflect.DeepEqual(res.Unmapped, wantUnmapped) {
+		t.Errorf("unmapped = %v, want %v", res.Unmapped, wantUnmapped)
+	}
+}
+
+func TestImportGitHubRejectsBadJSON(t *testing.T) {
+	for _, in := range []string{`{"number": 1}`, `[{"number": 1, "title": "x", "labels": 5}]`} {
+		if _, err := ImportGitHub(strings.NewReader(in)); err == nil {
+			t.Errorf("%s: expected an error", in)
+		}
+	}
+}
@@ -0,0 +1,186 @@
+package interchange
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"time"
+
+	"gotasker/internal/models"
+)
+
+// Record is one imported task. Ref and ParentRef are IDs local to the source
+// file and are only used to rebuild subtask links; either may be empty.
+type Record struct {
+	Ref       string
+	ParentRef string
+	Task      models.Task
+}
+
+// ImportResult is what an importer extracted from a file. Unmapped counts,
+// per field path (e.g. "cards.desc"), how many source objects carried data
+// in a field that has no place on a task.
+type ImportResult struct {
+	Records  []Record
+	Unmapped map[string]int
+}
+
+// Importer formats accepted by Import in addition to the Parse formats.
+const (
+	FormatTodoistName = "todoist"
+	FormatTrelloName  = "trello"
+	FormatGitHubName  = "github"
+)
+
+type importFunc func(io.Reader) (*ImportResult, error)
+
+var importers = map[string]importFunc{
+	FormatTodoistName: ImportTodoist,
+	FormatTrelloName:  ImportTrello,
+	FormatGitHubName:  ImportGitHub,
+}
+
+// Import reads tasks from r in any supported format. Records are returned
+// with every parent ahead of its children.
+func Import(format string, r io.Reader) (*ImportResult, error) {
+	if imp, ok := importers[format]; ok {
+		res, err := imp(r)
+		if err != nil {
+			return nil, err
+		}
+		res.Records = orderRecords(res.Records)
+		return res, nil
+	}
+
+	tasks, err := Parse(format, r)
+	if err != nil {
+		return nil, err
+	}
+	res := &ImportResult{Unmapped: map[string]int{}}
+	for _, t := range tasks {
+		res.Records = append(res.Records, Record{Task: t})
+	}
+	return res, nil
+}
+
+// orderRecords sorts records so parents precede children. References to
+// unknown parents, and cycles, are dropped so those records import as
+// top-level tasks.
+func orderRecords(records []Record) []Record {
+	byRef := make(map[string]int, len(records))
+	for i, rec := range records {
+		if rec.Ref != "" {
+			byRef[rec.Ref] = i
+		}
+	}
+
+	out := make([]Record, 0, len(records))
+	state := make([]int, len(records)) // 0 new, 1 visiting, 2 done
+	var visit func(i int)
+	visit = func(i int) {
+		if state[i] != 0 {
+			return
+		}
+		state[i] = 1
+		rec := records[i]
+		if p, ok := byRef[rec.ParentRef]; ok && rec.ParentRef != "" {
+			if state[p] == 1 {
+				rec.ParentRef = ""
+			} else {
+				visit(p)
+			}
+		} else {
+			rec.ParentRef = ""
+		}
+		state[i] = 2
+		out = append(out, rec)
+	}
+	for i := range records {
+		visit(i)
+	}
+	return out
+}
+
+// noteUnmapped adds every key of obj that carries data and is not listed in
+// mapped to res.Unmapped under prefix.
+func noteUnmapped(res *ImportResult, prefix string, obj map[string]json.RawMessage, mapped ...string) {
+	known := make(map[string]bool, len(mapped))
+	for _, k := range mapped {
+		known[k] = true
+	}
+	for k, v := range obj {
+		if known[k] || isEmptyJSON(v) {
+			continue
+		}
+		res.Unmapped[prefix+"."+k]++
+	}
+}
+
+func isEmptyJSON(v json.RawMessage) bool {
+	switch string(bytes.TrimSpace(v)) {
+	case "", "null", `""`, "[]", "{}", "false", "0":
+		return true
+	}
+	return false
+}
+
+// decodeBoth unmarshals raw into dst and also returns its top-level keys so
+// unmapped fields can be reported.
+func decodeBoth(raw json.RawMessage, dst any) (map[string]json.RawMessage, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(raw, dst); err != nil {
+		return nil, err
+	}
+	return fields, nil
+}
+
+// flexID accepts IDs encoded as either JSON strings or numbers.
+type flexID string
+
+func (id *flexID) UnmarshalJSON(b []byte) error {
+	if len(b) > 0 && b[0] == '"' {
+		var s string
+		if err := json.Unmarshal(b, &s); err != nil {
+			return err
+		}
+		*id = flexID(s)
+		return nil
+	}
+	var n json.Number
+	if err := json.Unmarshal(b, &n); err != nil {
+		return err
+	}
+	*id = flexID(n.String())
+	return nil
+}
+
+var timeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05",
+	"2006-01-02",
+}
+
+// parseTime accepts RFC 3339 timestamps, floating date-times (read as UTC)
+// and plain dates. Empty or unparsable values yield nil.
+func parseTime(s string) *time.Time {
+	if s == "" {
+		return nil
+	}
+	for _, layout := range timeLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			t = t.UTC()
+			return &t
+		}
+	}
+	return nil
+}
+
+func derefTime(t *time.Time) time.Time {
+	if t == nil {
+		return time.Time{}
+	}
+	return *t
+}
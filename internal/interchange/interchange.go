@@ -0,0 +1,49 @@
+// Package interchange converts tasks to and from plain-text formats used by
+// other todo tools.
+package interchange
+
+import (
+	"errors"
+	"io"
+
+	"gotasker/internal/models"
+)
+
+var ErrUnknownFormat = errors.New("unknown format")
+
+// Format names accepted by Parse and Format.
+const (
+	FormatTodoTxtName  = "todotxt"
+	FormatMarkdownName = "markdown"
+)
+
+type parseFunc func(io.Reader) ([]models.Task, error)
+type formatFunc func(io.Writer, []models.Task) error
+
+var parsers = map[string]parseFunc{
+	FormatTodoTxtName:  ParseTodoTxt,
+	FormatMarkdownName: ParseMarkdown,
+}
+
+var formatters = map[string]formatFunc{
+	FormatTodoTxtName:  FormatTodoTxt,
+	FormatMarkdownName: FormatMarkdown,
+}
+
+// Parse reads tasks from r in the named format.
+func Parse(format string, r io.Reader) ([]models.Task, error) {
+	p, ok := parsers[format]
+	if !ok {
+		return nil, ErrUnknownFormat
+	}
+	return p(r)
+}
+
+// Format writes tasks to w in the named format.
+func Format(format string, w io.Writer, tasks []models.Task) error {
+	f, ok := formatters[format]
+	if !ok {
+		return ErrUnknownFormat
+	}
+	return f(w, tasks)
+}
@@ -0,0 +1,211 @@
+package interchange
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"gotasker/internal/models"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+func day(s string) time.Time {
+	d, err := time.Parse(dateLayout, s)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+func dayPtr(s string) *time.Time {
+	d := day(s)
+	return &d
+}
+
+// goldenTasks covers every field the text formats carry. Dates are whole
+// days in UTC and tags are normalized, which is what a round trip keeps.
+func goldenTasks() []models.Task {
+	return []models.Task{
+		{
+			Title:     "Write report",
+			Priority:  models.PriorityHigh,
+			CreatedAt: day("2026-01-01"),
+			DueAt:     dayPtr("2026-01-05"),
+			Tags:      []string{"work", "@office"},
+		},
+		{
+			Title:       "Call mom",
+			Done:        true,
+			Priority:    models.PriorityMedium,
+			CompletedAt: dayPtr("2026-01-03"),
+			CreatedAt:   day("2026-01-02"),
+			Tags:        []string{"family", "@phone"},
+		},
+		{
+			Title:     "Renew passport",
+			Done:      true,
+			CreatedAt: day("2025-12-20"),
+			Tags:      []string{},
+		},
+		{
+			Title:       "Water plants",
+			Done:        true,
+			CompletedAt: dayPtr("2026-01-04"),
+			Tags:        []string{"home"},
+		},
+		{
+			Title:    "Someday maybe",
+			Priority: models.PriorityLow,
+			Tags:     []string{},
+		},
+	}
+}
+
+// checkGolden compares got with testdata/name, or rewrites the file when
+// the tests run with -update.
+func checkGolden(t *testing.T, name string, got []byte) {
+	t.Helper()
+	path := filepath.Join("testdata", name)
+	if *update {
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("%s mismatch\ngot:\n%s\nwant:\n%s", name, got, want)
+	}
+}
+
+func TestTodoTxtGolden(t *testing.T) {
+	var buf bytes.Buffer
+	if err := FormatTodoTxt(&buf, goldenTasks()); err != nil {
+		t.Fatal(err)
+	}
+	checkGolden(t, "tasks.todo.txt.golden", buf.Bytes())
+}
+
+func TestTodoTxtRoundTrip(t *testing.T) {
+	golden, err := os.ReadFile(filepath.Join("testdata", "tasks.todo.txt.golden"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tasks, err := ParseTodoTxt(bytes.NewReader(golden))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := goldenTasks(); !reflect.DeepEqual(tasks, want) {
+		t.Errorf("parsed tasks differ\ngot:  %+v\nwant: %+v", tasks, want)
+	}
+
+	var buf bytes.Buffer
+	if err := FormatTodoTxt(&buf, tasks); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), golden) {
+		t.Errorf("format(parse(golden)) differs\ngot:\n%s\nwant:\n%s", buf.Bytes(), golden)
+	}
+}
+
+func TestMarkdownGolden(t *testing.T) {
+	var buf bytes.Buffer
+	if err := FormatMarkdown(&buf, goldenTasks()); err != nil {
+		t.Fatal(err)
+	}
+	checkGolden(t, "tasks.md.golden", buf.Bytes())
+}
+
+func TestMarkdownRoundTrip(t *testing.T) {
+	golden, err := os.ReadFile(filepath.Join("testdata", "tasks.md.golden"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tasks, err := ParseMarkdown(bytes.NewReader(golden))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Markdown keeps no creation or completion dates.
+	want := goldenTasks()
+	for i := range want {
+		want[i].CreatedAt = time.Time{}
+		want[i].CompletedAt = nil
+	}
+	if !reflect.DeepEqual(tasks, want) {
+		t.Errorf("parsed tasks differ\ngot:  %+v\nwant: %+v", tasks, want)
+	}
+
+	var buf bytes.Buffer
+	if err := FormatMarkdown(&buf, tasks); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), golden) {
+		t.Errorf("format(parse(golden)) differs\ngot:\n%s\nwant:\n%s", buf.Bytes(), golden)
+	}
+}
+
+func TestParseTodoTxtLine(t *testing.T) {
+	tests := []struct {
+		line string
+		want models.Task
+	}{
+		{
+			line: "x 2026-01-03 Done with one date",
+			want: models.Task{Title: "Done with one date", Done: true, CompletedAt: dayPtr("2026-01-03"), Tags: []string{}},
+		},
+		{
+			line: "(C) 2026-01-01 Low priority +a +a @b",
+			want: models.Task{Title: "Low priority", Priority: models.PriorityLow, CreatedAt: day("2026-01-01"), Tags: []string{"a", "@b"}},
+		},
+		{
+			line: "(Z) Lowest letters map to low",
+			want: models.Task{Title: "Lowest letters map to low", Priority: models.PriorityLow, Tags: []string{}},
+		},
+	}
+	for _, tt := range tests {
+		got, err := parseTodoTxtLine(tt.line)
+		if err != nil {
+			t.Errorf("%q: %v", tt.line, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%q:\ngot:  %+v\nwant: %+v", tt.line, got, tt.want)
+		}
+	}
+
+	for _, line := range []string{"x 2026-01-03", "Pay due:tomorrow", "Old created:someday"} {
+		if _, err := parseTodoTxtLine(line); err == nil {
+			t.Errorf("%q: expected an error", line)
+		}
+	}
+}
+
+func TestParseMarkdownSkipsProse(t *testing.T) {
+	in := strings.Join([]string{
+		"# Groceries",
+		"Some notes.",
+		"- plain bullet",
+		"* [X] Milk",
+		"  - [ ] Eggs +shop",
+	}, "\n")
+	tasks, err := ParseMarkdown(strings.NewReader(in))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []models.Task{
+		{Title: "Milk", Done: true, Tags: []string{}},
+		{Title: "Eggs", Tags: []string{"shop"}},
+	}
+	if !reflect.DeepEqual(tasks, want) {
+		t.Errorf("got %+v, want %+v", tasks, want)
+	}
+}
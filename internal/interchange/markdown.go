@@ -0,0 +1,86 @@
+package interchange
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"gotasker/internal/models"
+)
+
+// GitHub-style Markdown checklists:
+//
+//	- [ ] Write report +work due:2026-01-05
+//	- [x] Call mom @phone pri:A
+//
+// Only checklist items are read; headings, prose and plain bullets are
+// skipped. The item text uses the same inline tokens as todo.txt (+tag,
+// @context, due:, pri:), so title, done flag, priority, tags and due date
+// survive a FormatMarkdown / ParseMarkdown round trip.
+
+var checklistItem = regexp.MustCompile(`^\s*[-*+]\s+\[( |x|X)\]\s+(.*)$`)
+
+// ParseMarkdown reads every checklist item in r as a task.
+func ParseMarkdown(r io.Reader) ([]models.Task, error) {
+	tasks := make([]models.Task, 0)
+	sc := bufio.NewScanner(r)
+	lineNo := 0
+	for sc.Scan() {
+		lineNo++
+		m := checklistItem.FindStringSubmatch(sc.Text())
+		if m == nil {
+			continue
+		}
+		t := models.Task{Done: m[1] != " ", Tags: []string{}}
+		if err := parseBody(strings.Fields(m[2]), &t); err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNo, err)
+		}
+		if t.Title == "" {
+			continue
+		}
+		tasks = append(tasks, t)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return tasks, nil
+}
+
+// parseChecklist reads the checklist items in text without looking for
+// inline tokens, for Markdown written with other tools in mind.
+func parseChecklist(text string) []models.Task {
+	tasks := make([]models.Task, 0)
+	for _, line := range strings.Split(text, "\n") {
+		m := checklistItem.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		title := strings.Join(strings.Fields(m[2]), " ")
+		if title == "" {
+			continue
+		}
+		tasks = append(tasks, models.Task{Title: title, Done: m[1] != " ", Tags: []string{}})
+	}
+	return tasks
+}
+
+// FormatMarkdown writes tasks as a Markdown checklist.
+func FormatMarkdown(w io.Writer, tasks []models.Task) error {
+	for _, t := range tasks {
+		box := "[ ]"
+		if t.Done {
+			box = "[x]"
+		}
+		parts := []string{"-", box, strings.Join(strings.Fields(t.Title), " ")}
+		parts = append(parts, formatTokens(t)...)
+		if l := priorityToLetter(t.Priority); l != 0 {
+			parts = append(parts, "pri:"+string(l))
+		}
+		if _, err := fmt.Fprintln(w, strings.Join(parts, " ")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
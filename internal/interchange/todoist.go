@@ -0,0 +1,144 @@
+package interchange
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"gotasker/internal/models"
+)
+
+// Todoist backup JSON, as produced by the Sync API (v9):
+//
+//	{"projects": [...], "sections": [...], "items": [...]}
+//
+// Mapping onto tasks:
+//   - content               -> Title
+//   - checked               -> Done, completed_at -> CompletedAt
+//   - added_at              -> CreatedAt
+//   - priority 4/3/2/1      -> high/medium/low/none
+//   - due.date              -> DueAt
+//   - labels, project name,
+//     section name          -> Tags
+//   - parent_id             -> subtask link
+
+type todoistBackup struct {
+	Projects []json.RawMessage `json:"projects"`
+	Sections []json.RawMessage `json:"sections"`
+	Items    []json.RawMessage `json:"items"`
+}
+
+type todoistNamed struct {
+	ID   flexID `json:"id"`
+	Name string `json:"name"`
+}
+
+type todoistItem struct {
+	ID          flexID   `json:"id"`
+	ParentID    *flexID  `json:"parent_id"`
+	ProjectID   flexID   `json:"project_id"`
+	SectionID   *flexID  `json:"section_id"`
+	Content     string   `json:"content"`
+	Checked     bool     `json:"checked"`
+	Priority    int      `json:"priority"`
+	Labels      []string `json:"labels"`
+	AddedAt     string   `json:"added_at"`
+	CompletedAt string   `json:"completed_at"`
+	Due         *struct {
+		Date string `json:"date"`
+	} `json:"due"`
+}
+
+var todoistMapped = []string{
+	"id", "parent_id", "project_id", "section_id", "content", "checked",
+	"priority", "labels", "added_at", "completed_at", "due",
+	// Bookkeeping fields with no user-visible meaning.
+	"user_id", "added_by_uid", "child_order", "day_order", "sync_id", "is_deleted", "v2_id",
+	"v2_parent_id", "v2_project_id", "v2_section_id",
+}
+
+// ImportTodoist reads a Todoist backup JSON document.
+func ImportTodoist(r io.Reader) (*ImportResult, error) {
+	var backup todoistBackup
+	if err := json.NewDecoder(r).Decode(&backup); err != nil {
+		return nil, fmt.Errorf("invalid Todoist JSON: %w", err)
+	}
+
+	res := &ImportResult{Unmapped: map[string]int{}}
+
+	projects, err := todoistNames(backup.Projects)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Todoist project: %w", err)
+	}
+	sections, err := todoistNames(backup.Sections)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Todoist section: %w", err)
+	}
+
+	for _, raw := range backup.Items {
+		var item todoistItem
+		fields, err := decodeBoth(raw, &item)
+		if err != nil {
+			return nil, fmt.Errorf("invalid Todoist item: %w", err)
+		}
+		noteUnmapped(res, "items", fields, todoistMapped...)
+
+		if item.Content == "" {
+			continue
+		}
+
+		tags := append([]string{}, item.Labels...)
+		if name := projects[item.ProjectID]; name != "" {
+			tags = append(tags, name)
+		}
+		if item.SectionID != nil {
+			if name := sections[*item.SectionID]; name != "" {
+				tags = append(tags, name)
+			}
+		}
+
+		t := models.Task{
+			Title:       item.Content,
+			Done:        item.Checked,
+			Priority:    todoistPriority(item.Priority),
+			CompletedAt: parseTime(item.CompletedAt),
+			CreatedAt:   derefTime(parseTime(item.AddedAt)),
+			Tags:        models.NormalizeTags(tags),
+		}
+		if item.Due != nil {
+			t.DueAt = parseTime(item.Due.Date)
+		}
+
+		rec := Record{Ref: string(item.ID), Task: t}
+		if item.ParentID != nil {
+			rec.ParentRef = string(*item.ParentID)
+		}
+		res.Records = append(res.Records, rec)
+	}
+	return res, nil
+}
+
+func todoistNames(raws []json.RawMessage) (map[flexID]string, error) {
+	names := make(map[flexID]string, len(raws))
+	for _, raw := range raws {
+		var n todoistNamed
+		if err := json.Unmarshal(raw, &n); err != nil {
+			return nil, err
+		}
+		names[n.ID] = n.Name
+	}
+	return names, nil
+}
+
+// todoistPriority maps Todoist's 4 (urgent) .. 1 (normal) scale.
+func todoistPriority(p int) string {
+	switch p {
+	case 4:
+		return models.PriorityHigh
+	case 3:
+		return models.PriorityMedium
+	case 2:
+		return models.PriorityLow
+	}
+	return models.PriorityNone
+}
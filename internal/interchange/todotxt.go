@@ -0,0 +1,230 @@
+package interchange
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"gotasker/internal/models"
+)
+
+// todo.txt format: https://github.com/todotxt/todo.txt
+//
+//	x 2026-01-03 2026-01-01 Call mom +family @phone due:2026-01-05 pri:A
+//	(B) 2026-01-01 Write report +work
+//
+// Mapping onto tasks:
+//   - "x " prefix           -> Done
+//   - "(A)" / "pri:A"       -> Priority (A high, B medium, C..Z low)
+//   - completion date       -> CompletedAt
+//   - creation date         -> CreatedAt
+//   - "+project"            -> tag "project"
+//   - "@context"            -> tag "@context"
+//   - "due:YYYY-MM-DD"      -> DueAt
+//   - "created:YYYY-MM-DD"  -> CreatedAt of a completed task that has no
+//     completion date; todo.txt only allows a creation date after one
+//
+// Round trip: parsing the output of FormatTodoTxt yields the same title,
+// done flag, priority and tags, and the same dates truncated to the day in
+// UTC. Titles containing words that look like todo.txt tokens ("+x", "@x",
+// "due:...", "created:...") are not preserved, since those words are read
+// back as fields.
+
+const dateLayout = "2006-01-02"
+
+// ParseTodoTxt reads one task per non-blank line.
+func ParseTodoTxt(r io.Reader) ([]models.Task, error) {
+	tasks := make([]models.Task, 0)
+	sc := bufio.NewScanner(r)
+	lineNo := 0
+	for sc.Scan() {
+		lineNo++
+		line := strings.TrimSpace(sc.Text())
+		if line == "" {
+			continue
+		}
+		t, err := parseTodoTxtLine(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNo, err)
+		}
+		tasks = append(tasks, t)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return tasks, nil
+}
+
+func parseTodoTxtLine(line string) (models.Task, error) {
+	t := models.Task{Tags: []string{}}
+	words := strings.Fields(line)
+
+	if len(words) > 0 && words[0] == "x" {
+		t.Done = true
+		words = words[1:]
+		// A completed task may carry a completion date followed by a
+		// creation date; a single date is the completion date.
+		if d, ok := parseDate(words); ok {
+			t.CompletedAt = &d
+			words = words[1:]
+			if c, ok := parseDate(words); ok {
+				t.CreatedAt = c
+				words = words[1:]
+			}
+		}
+	} else {
+		if len(words) > 0 && isPriorityWord(words[0]) {
+			t.Priority = letterToPriority(words[0][1])
+			words = words[1:]
+		}
+		if c, ok := parseDate(words); ok {
+			t.CreatedAt = c
+			words = words[1:]
+		}
+	}
+
+	if err := parseBody(words, &t); err != nil {
+		return models.Task{}, err
+	}
+	if t.Title == "" {
+		return models.Task{}, fmt.Errorf("missing title")
+	}
+	return t, nil
+}
+
+// parseBody extracts inline tokens from words and stores what is left as the
+// title. It is shared by the todo.txt and Markdown formats.
+func parseBody(words []string, t *models.Task) error {
+	title := make([]string, 0, len(words))
+	var tags []string
+	for _, w := range words {
+		switch {
+		case len(w) > 1 && w[0] == '+':
+			tags = append(tags, w[1:])
+		case len(w) > 1 && w[0] == '@':
+			tags = append(tags, w)
+		case strings.HasPrefix(w, "due:"):
+			d, err := time.Parse(dateLayout, strings.TrimPrefix(w, "due:"))
+			if err != nil {
+				return fmt.Errorf("invalid due date %q", w)
+			}
+			t.DueAt = &d
+		case strings.HasPrefix(w, "created:"):
+			d, err := time.Parse(dateLayout, strings.TrimPrefix(w, "created:"))
+			if err != nil {
+				return fmt.Errorf("invalid creation date %q", w)
+			}
+			t.CreatedAt = d
+		case strings.HasPrefix(w, "pri:") && len(w) == 5 && isPriorityLetter(w[4]):
+			t.Priority = letterToPriority(w[4])
+		default:
+			title = append(title, w)
+		}
+	}
+	t.Title = strings.Join(title, " ")
+	t.Tags = models.NormalizeTags(append(t.Tags, tags...))
+	return nil
+}
+
+// FormatTodoTxt writes tasks in todo.txt format, one per line.
+func FormatTodoTxt(w io.Writer, tasks []models.Task) error {
+	for _, t := range tasks {
+		if _, err := fmt.Fprintln(w, formatTodoTxtLine(t)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func formatTodoTxtLine(t models.Task) string {
+	var parts []string
+	if t.Done {
+		parts = append(parts, "x")
+		// The creation date is only unambiguous after a completion date.
+		if t.CompletedAt != nil {
+			parts = append(parts, t.CompletedAt.UTC().Format(dateLayout))
+			if !t.CreatedAt.IsZero() {
+				parts = append(parts, t.CreatedAt.UTC().Format(dateLayout))
+			}
+		}
+	} else {
+		if l := priorityToLetter(t.Priority); l != 0 {
+			parts = append(parts, "("+string(l)+")")
+		}
+		if !t.CreatedAt.IsZero() {
+			parts = append(parts, t.CreatedAt.UTC().Format(dateLayout))
+		}
+	}
+	parts = append(parts, strings.Join(strings.Fields(t.Title), " "))
+	parts = append(parts, formatTokens(t)...)
+	if t.Done {
+		// Completed lines cannot start with a priority, so keep it as a tag.
+		if l := priorityToLetter(t.Priority); l != 0 {
+			parts = append(parts, "pri:"+string(l))
+		}
+		if t.CompletedAt == nil && !t.CreatedAt.IsZero() {
+			parts = append(parts, "created:"+t.CreatedAt.UTC().Format(dateLayout))
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// formatTokens renders tags and the due date as inline tokens.
+func formatTokens(t models.Task) []string {
+	var out []string
+	for _, tag := range models.NormalizeTags(t.Tags) {
+		if strings.HasPrefix(tag, "@") {
+			out = append(out, tag)
+		} else {
+			out = append(out, "+"+tag)
+		}
+	}
+	if t.DueAt != nil {
+		out = append(out, "due:"+t.DueAt.UTC().Format(dateLayout))
+	}
+	return out
+}
+
+func parseDate(words []string) (time.Time, bool) {
+	if len(words) == 0 {
+		return time.Time{}, false
+	}
+	d, err := time.Parse(dateLayout, words[0])
+	if err != nil {
+		return time.Time{}, false
+	}
+	return d, true
+}
+
+func isPriorityWord(w string) bool {
+	return len(w) == 3 && w[0] == '(' && w[2] == ')' && isPriorityLetter(w[1])
+}
+
+func isPriorityLetter(c byte) bool {
+	return c >= 'A' && c <= 'Z'
+}
+
+func letterToPriority(c byte) string {
+	switch c {
+	case 'A':
+		return models.PriorityHigh
+	case 'B':
+		return models.PriorityMedium
+	default:
+		return models.PriorityLow
+	}
+}
+
+func priorityToLetter(p string) byte {
+	switch p {
+	case models.PriorityHigh:
+		return 'A'
+	case models.PriorityMedium:
+		return 'B'
+	case models.PriorityLow:
+		return 'C'
+	}
+	return 0
+}
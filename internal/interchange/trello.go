@@ -0,0 +1,194 @@
+package interchange
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"gotasker/internal/models"
+)
+
+// Trello board export JSON ("Menu > Print, export and share > Export as JSON").
+//
+// Mapping onto tasks:
+//   - card name             -> Title
+//   - card closed,
+//     dueComplete           -> Done
+//   - card due              -> DueAt
+//   - card id timestamp     -> CreatedAt
+//   - list name, labels     -> Tags (unnamed labels use their colour)
+//   - checklist items       -> subtasks of the card
+//
+// Board-level settings, members and the activity log are not imported; they
+// show up in the unmapped report under "board".
+
+type trelloBoard struct {
+	Lists      []json.RawMessage `json:"lists"`
+	Labels     []trelloLabel     `json:"labels"`
+	Cards      []json.RawMessage `json:"cards"`
+	Checklists []json.RawMessage `json:"checklists"`
+}
+
+type trelloList struct {
+	ID     string `json:"id"`
+	Name   string `json:"name"`
+	Closed bool   `json:"closed"`
+}
+
+type trelloLabel struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Color string `json:"color"`
+}
+
+type trelloCard struct {
+	ID          string   `json:"id"`
+	Name        string   `json:"name"`
+	Closed      bool     `json:"closed"`
+	IDList      string   `json:"idList"`
+	IDLabels    []string `json:"idLabels"`
+	Due         string   `json:"due"`
+	DueComplete bool     `json:"dueComplete"`
+}
+
+type trelloChecklist struct {
+	ID         string            `json:"id"`
+	IDCard     string            `json:"idCard"`
+	CheckItems []json.RawMessage `json:"checkItems"`
+}
+
+type trelloCheckItem struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	State string `json:"state"`
+	Due   string `json:"due"`
+}
+
+var trelloCardMapped = []string{
+	"id", "name", "closed", "idList", "idLabels", "labels", "due", "dueComplete", "idChecklists",
+	// Bookkeeping fields with no user-visible meaning.
+	"idBoard", "idShort", "pos", "shortLink", "shortUrl", "url", "dateLastActivity", "badges",
+	"idMembersVoted", "subscribed", "manualCoverAttachment", "cover",
+}
+
+var trelloListMapped = []string{"id", "name", "closed", "idBoard", "pos", "subscribed"}
+
+var trelloChecklistMapped = []string{"id", "idCard", "checkItems", "idBoard", "pos"}
+
+var trelloCheckItemMapped = []string{"id", "name", "state", "due", "idChecklist", "pos", "nameData"}
+
+// ImportTrello reads a Trello board export.
+func ImportTrello(r io.Reader) (*ImportResult, error) {
+	raw, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var board trelloBoard
+	boardFields, err := decodeBoth(raw, &board)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Trello JSON: %w", err)
+	}
+
+	res := &ImportResult{Unmapped: map[string]int{}}
+	noteUnmapped(res, "board", boardFields, "lists", "labels", "cards", "checklists")
+
+	lists := make(map[string]trelloList, len(board.Lists))
+	for _, raw := range board.Lists {
+		var l trelloList
+		fields, err := decodeBoth(raw, &l)
+		if err != nil {
+			return nil, fmt.Errorf("invalid Trello list: %w", err)
+		}
+		noteUnmapped(res, "lists", fields, trelloListMapped...)
+		lists[l.ID] = l
+	}
+
+	labels := make(map[string]string, len(board.Labels))
+	for _, l := range board.Labels {
+		name := l.Name
+		if name == "" {
+			name = l.Color
+		}
+		labels[l.ID] = name
+	}
+
+	for _, raw := range board.Cards {
+		var c trelloCard
+		fields, err := decodeBoth(raw, &c)
+		if err != nil {
+			return nil, fmt.Errorf("invalid Trello card: %w", err)
+		}
+		noteUnmapped(res, "cards", fields, trelloCardMapped...)
+
+		if c.Name == "" {
+			continue
+		}
+
+		list := lists[c.IDList]
+		tags := []string{list.Name}
+		for _, id := range c.IDLabels {
+			tags = append(tags, labels[id])
+		}
+
+		res.Records = append(res.Records, Record{
+			Ref: c.ID,
+			Task: models.Task{
+				Title:     c.Name,
+				Done:      c.Closed || c.DueComplete || list.Closed,
+				DueAt:     parseTime(c.Due),
+				CreatedAt: trelloCreatedAt(c.ID),
+				Tags:      models.NormalizeTags(tags),
+			},
+		})
+	}
+
+	for _, raw := range board.Checklists {
+		var cl trelloChecklist
+		fields, err := decodeBoth(raw, &cl)
+		if err != nil {
+			return nil, fmt.Errorf("invalid Trello checklist: %w", err)
+		}
+		noteUnmapped(res, "checklists", fields, trelloChecklistMapped...)
+
+		for _, rawItem := range cl.CheckItems {
+			var item trelloCheckItem
+			fields, err := decodeBoth(rawItem, &item)
+			if err != nil {
+				return nil, fmt.Errorf("invalid Trello checklist item: %w", err)
+			}
+			noteUnmapped(res, "checkItems", fields, trelloCheckItemMapped...)
+
+			if item.Name == "" {
+				continue
+			}
+			res.Records = append(res.Records, Record{
+				Ref:       item.ID,
+				ParentRef: cl.IDCard,
+				Task: models.Task{
+					Title:     item.Name,
+					Done:      item.State == "complete",
+					DueAt:     parseTime(item.Due),
+					CreatedAt: trelloCreatedAt(item.ID),
+					Tags:      []string{},
+				},
+			})
+		}
+	}
+	return res, nil
+}
+
+// trelloCreatedAt decodes the creation time embedded in the first four bytes
+// of a Trello (MongoDB ObjectId) identifier.
+func trelloCreatedAt(id string) time.Time {
+	if len(id) < 8 {
+		return time.Time{}
+	}
+	secs, err := strconv.ParseInt(id[:8], 16, 64)
+	if err != nil {
+		return time.Time{}
+	}
+	return time.Unix(secs, 0).UTC()
+}
@@ -0,0 +1,61 @@
+// Package mail sends transactional email such as invitations and password
+// resets.
+package mail
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+)
+
+// Message is a plain-text email.
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer delivers messages.
+type Mailer interface {
+	Send(ctx context.Context, m Message) error
+}
+
+// LogMailer writes messages to the standard logger instead of sending them.
+// It is meant for development.
+type LogMailer struct{}
+
+func (LogMailer) Send(_ context.Context, m Message) error {
+	log.Printf("mail to=%s subject=%q\n%s", m.To, m.Subject, m.Body)
+	return nil
+}
+
+// NewFromEnv builds the mailer selected by MAIL_BACKEND: "log" (default),
+// "file" (.eml files in MAIL_DIR) or "smtp" (SMTP_HOST, SMTP_PORT,
+// SMTP_USERNAME, SMTP_PASSWORD). MAIL_FROM is the sender address.
+func NewFromEnv() (Mailer, error) {
+	from := os.Getenv("MAIL_FROM")
+	switch backend := os.Getenv("MAIL_BACKEND"); backend {
+	case "", "log":
+		return LogMailer{}, nil
+	case "file":
+		dir := os.Getenv("MAIL_DIR")
+		if dir == "" {
+			dir = "data/mail"
+		}
+		if from == "" {
+			from = "GoTasker <no-reply@localhost>"
+		}
+		return NewFileMailer(dir, from)
+	case "smtp":
+		return NewSMTPMailer(SMTPConfig{
+			Host:     os.Getenv("SMTP_HOST"),
+			Port:     os.Getenv("SMTP_PORT"),
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+			From:     from,
+		})
+	default:
+		return nil, fmt.Errorf("unknown MAIL_BACKEND %q", backend)
+	}
+}
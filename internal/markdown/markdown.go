@@ -0,0 +1,179 @@
+// Package markdown renders the small Markdown subset used in comments to
+// HTML. All input is HTML-escaped first, so the output is safe to embed:
+// raw HTML in a comment shows up as text.
+//
+// Supported: paragraphs, ATX headings, "-"/"*"/"+" and numbered lists,
+// "> " quotes, fenced code blocks, `code`, **bold**, *emphasis* and
+// [links](https://...) with http, https or mailto targets.
+package markdown
+
+import (
+	"html"
+	"regexp"
+	"strings"
+)
+
+var (
+	headingRe   = regexp.MustCompile(`^(#{1,6})\s+(.*?)\s*#*\s*$`)
+	bulletRe    = regexp.MustCompile(`^\s*[-*+]\s+(.*)$`)
+	numberedRe  = regexp.MustCompile(`^\s*\d+[.)]\s+(.*)$`)
+	quoteRe     = regexp.MustCompile(`^\s*>\s?(.*)$`)
+	linkRe      = regexp.MustCompile(`\[([^\]]+)\]\(([^)\s]+)\)`)
+	strongRe    = regexp.MustCompile(`\*\*([^*]+)\*\*`)
+	emphasisRe  = regexp.MustCompile(`\*([^*\s][^*]*)\*`)
+	mentionRe   = regexp.MustCompile(`(?:^|[^\w.@])@([A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,})`)
+	safeSchemes = []string{"http://", "https://", "mailto:"}
+)
+
+// ToHTML renders src.
+func ToHTML(src string) string {
+	lines := strings.Split(strings.ReplaceAll(src, "\r\n", "\n"), "\n")
+
+	var (
+		out       strings.Builder
+		paragraph []string
+		listTag   string
+		quote     []string
+	)
+	flushParagraph := func() {
+		if len(paragraph) > 0 {
+			out.WriteString("<p>" + inline(strings.Join(paragraph, "\n")) + "</p>\n")
+			paragraph = nil
+		}
+	}
+	closeList := func() {
+		if listTag != "" {
+			out.WriteString("</" + listTag + ">\n")
+			listTag = ""
+		}
+	}
+	flushQuote := func() {
+		if len(quote) > 0 {
+			out.WriteString("<blockquote><p>" + inline(strings.Join(quote, "\n")) + "</p></blockquote>\n")
+			quote = nil
+		}
+	}
+	flush := func() {
+		flushParagraph()
+		closeList()
+		flushQuote()
+	}
+	openList := func(tag string) {
+		if listTag != tag {
+			flush()
+			out.WriteString("<" + tag + ">\n")
+			listTag = tag
+		}
+	}
+
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		trimmed := strings.TrimSpace(line)
+
+		if strings.HasPrefix(trimmed, "```") {
+			flush()
+			var code []string
+			for i++; i < len(lines) && !strings.HasPrefix(strings.TrimSpace(lines[i]), "```"); i++ {
+				code = append(code, lines[i])
+			}
+			out.WriteString("<pre><code>" + html.EscapeString(strings.Join(code, "\n")) + "</code></pre>\n")
+			continue
+		}
+
+		switch {
+		case trimmed == "":
+			flush()
+		case headingRe.MatchString(trimmed):
+			flush()
+			m := headingRe.FindStringSubmatch(trimmed)
+			tag := "h" + string(rune('0'+len(m[1])))
+			out.WriteString("<" + tag + ">" + inline(m[2]) + "</" + tag + ">\n")
+		case bulletRe.MatchString(line):
+			openList("ul")
+			out.WriteString("<li>" + inline(bulletRe.FindStringSubmatch(line)[1]) + "</li>\n")
+		case numberedRe.MatchString(line):
+			openList("ol")
+			out.WriteString("<li>" + inline(numberedRe.FindStringSubmatch(line)[1]) + "</li>\n")
+		case quoteRe.MatchString(line):
+			flushParagraph()
+			closeList()
+			quote = append(quote, quoteRe.FindStringSubmatch(line)[1])
+		default:
+			closeList()
+			flushQuote()
+			paragraph = append(paragraph, trimmed)
+		}
+	}
+	flush()
+
+	return strings.TrimSuffix(out.String(), "\n")
+}
+
+// inline renders spans. Code spans are cut out first so nothing inside
+// them is interpreted.
+func inline(s string) string {
+	parts := strings.Split(s, "`")
+	var out strings.Builder
+	for i, part := range parts {
+		switch {
+		case i%2 == 1 && i < len(parts)-1:
+			out.WriteString("<code>" + html.EscapeString(part) + "</code>")
+		case i%2 == 1:
+			// Unmatched backtick: keep it literally.
+			out.WriteString("`" + spans(part))
+		default:
+			out.WriteString(spans(part))
+		}
+	}
+	return out.String()
+}
+
+func spans(s string) string {
+	s = html.EscapeString(s)
+	s = linkRe.ReplaceAllStringFunc(s, func(m string) string {
+		sub := linkRe.FindStringSubmatch(m)
+		target := html.UnescapeString(sub[2])
+		for _, scheme := range safeSchemes {
+			if strings.HasPrefix(strings.ToLower(target), scheme) {
+				return `<a href="` + html.EscapeString(target) + `" rel="nofollow noopener">` + sub[1] + "</a>"
+			}
+		}
+		return m
+	})
+	s = strongRe.ReplaceAllString(s, "<strong>$1</strong>")
+	s = emphasisRe.ReplaceAllString(s, "<em>$1</em>")
+	return strings.ReplaceAll(s, "\n", "<br>\n")
+}
+
+// Mentions returns the distinct lower-cased addresses written as
+// "@someone@example.com" in src, in order of first appearance. Mentions
+// inside code are ignored.
+func Mentions(src string) []string {
+	var text strings.Builder
+	inFence := false
+	for _, line := range strings.Split(src, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			inFence = !inFence
+			continue
+		}
+		if inFence {
+			continue
+		}
+		parts := strings.Split(line, "`")
+		for i := 0; i < len(parts); i += 2 {
+			text.WriteString(parts[i] + " ")
+		}
+		text.WriteString("\n")
+	}
+
+	seen := map[string]bool{}
+	var emails []string
+	for _, m := range mentionRe.FindAllStringSubmatch(text.String(), -1) {
+		email := strings.ToLower(m[1])
+		if !seen[email] {
+			seen[email] = true
+			emails = append(emails, email)
+		}
+	}
+	return emails
+}
@@ -0,0 +1,46 @@
+package markdown
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestToHTML(t *testing.T) {
+	tests := map[string]string{
+		"hello\nworld":                  "<p>hello<br>\nworld</p>",
+		"# Title #":                     "<h1>Title</h1>",
+		"- a\n- b\n\n1. c":              "<ul>\n<li>a</li>\n<li>b</li>\n</ul>\n<ol>\n<li>c</li>\n</ol>",
+		"> quoted":                      "<blockquote><p>quoted</p></blockquote>",
+		"**bold** and *em*":             "<p><strong>bold</strong> and <em>em</em></p>",
+		"`<b>*x*</b>`":                  "<p><code>&lt;b&gt;*x*&lt;/b&gt;</code></p>",
+		"```\n<script>\n```":            "<pre><code>&lt;script&gt;</code></pre>",
+		"<img src=x onerror=alert(1)>":  "<p>&lt;img src=x onerror=alert(1)&gt;</p>",
+		"[docs](https://example.com/a)": `<p><a href="https://example.com/a" rel="nofollow noopener">docs</a></p>`,
+	}
+	for in, want := range tests {
+		if got := ToHTML(in); got != want {
+			t.Errorf("%q:\n got %q\nwant %q", in, got, want)
+		}
+	}
+}
+
+func TestToHTMLRefusesUnsafeLinks(t *testing.T) {
+	for _, in := range []string{"[x](javascript:alert(1))", "[x](data:text/html,hi)"} {
+		if got, want := ToHTML(in), "<p>"+in+"</p>"; got != want {
+			t.Errorf("%q: got %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestMentions(t *testing.T) {
+	src := "@Ann@Example.com and @bob@example.com, again @ann@example.com\n" +
+		"not me: mail@carol@example.com or `@dave@example.com`\n" +
+		"```\n@erin@example.com\n```"
+	want := []string{"ann@example.com", "bob@example.com"}
+	if got := Mentions(src); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got := Mentions("no mentions"); got != nil {
+		t.Errorf("got %v, want none", got)
+	}
+}
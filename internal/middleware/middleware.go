@@ -3,9 +3,41 @@ package middleware
 import (
 	"log"
 	"net/http"
+	"os"
+	"strings"
 	"time"
+
+	"github.com/go-chi/cors"
 )
 
+// --- CORS ---
+
+// FrontendOrigin is where the web app is served from: APP_BASE_URL, or
+// the local dev server. Links in emails point at its pages.
+var FrontendOrigin = frontendOrigin()
+
+func frontendOrigin() string {
+	if u := strings.TrimRight(os.Getenv("APP_BASE_URL"), "/"); u != "" {
+		return u
+	}
+	return "http://localhost:5175"
+}
+
+// CORS lets the web app call the API. Credentials are allowed so that the
+// browser keeps cookies the API sets on those calls, such as the OIDC
+// state cookie from POST /auth/oidc/link; the app has to send its requests
+// with credentials for that.
+func CORS() func(http.Handler) http.Handler {
+	return cors.Handler(cors.Options{
+		AllowedOrigins:   []string{FrontendOrigin},
+		AllowedMethods:   []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
+		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-API-Key", "X-Workspace-ID"},
+		ExposedHeaders:   []string{"Link"},
+		AllowCredentials: true,
+		MaxAge:           300,
+	})
+}
+
 // --- Logging Logic ---
 
 type statusWriter struct {
@@ -0,0 +1,37 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCORSAllowsCredentialsForTheFrontend(t *testing.T) {
+	h := CORS()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	for _, tt := range []struct {
+		origin string
+		want   bool
+	}{
+		{FrontendOrigin, true},
+		{"http://evil.example", false},
+	} {
+		// The preflight for a bearer POST, then the request itself.
+		pre := httptest.NewRequest(http.MethodOptions, "/auth/oidc/link", nil)
+		pre.Header.Set("Origin", tt.origin)
+		pre.Header.Set("Access-Control-Request-Method", http.MethodPost)
+		pre.Header.Set("Access-Control-Request-Headers", "authorization")
+		req := httptest.NewRequest(http.MethodPost, "/auth/oidc/link", nil)
+		req.Header.Set("Origin", tt.origin)
+
+		for _, r := range []*http.Request{pre, req} {
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, r)
+			allowed := rec.Header().Get("Access-Control-Allow-Origin") == tt.origin &&
+				rec.Header().Get("Access-Control-Allow-Credentials") == "true"
+			if allowed != tt.want {
+				t.Errorf("%s %s: credentials allowed %v, want %v (headers %v)", r.Method, tt.origin, allowed, tt.want, rec.Header())
+			}
+		}
+	}
+}
@@ -1,6 +1,10 @@
 package models
 
-import "time"
+import (
+	"encoding/json"
+	"strings"
+	"time"
+)
 
 type HealthResponse struct {
 	Status        string `json:"status"`
@@ -8,33 +12,254 @@ type HealthResponse struct {
 	TasksCount    int    `json:"tasks_count"`
 }
 
+// Task priorities. An empty priority means "none".
+const (
+	PriorityNone   = ""
+	PriorityLow    = "low"
+	PriorityMedium = "medium"
+	PriorityHigh   = "high"
+)
+
 type Task struct {
-	ID        int       `json:"id"`
-	Title     string    `json:"title"`
-	Done      bool      `json:"done"`
+	ID          int        `json:"id"`
+	WorkspaceID int64      `json:"workspace_id"`
+	ParentID    *int       `json:"parent_id"`
+	Title       string     `json:"title"`
+	Done        bool       `json:"done"`
+	Status      string     `json:"status"`
+	Priority    string     `json:"priority"`
+	DueAt       *time.Time `json:"due_at"`
+	CompletedAt *time.Time `json:"completed_at"`
+	Tags        []string   `json:"tags"`
+	Recurrence  *string    `json:"recurrence"`
+	Position    *string    `json:"position"`
+	// TimeSpentSeconds totals the task's finished time entries.
+	TimeSpentSeconds int64 `json:"time_spent_seconds"`
+	// ArchivedAt is set once the task is moved out of the default list.
+	ArchivedAt *time.Time `json:"archived_at"`
+	AssigneeID *int64     `json:"assignee_id"`
+	// CommentCount is only filled in on list responses.
+	CommentCount int `json:"comment_count"`
+	// Owner is only set on list responses, for tasks shared with the user.
+	Owner     *UserRef  `json:"owner,omitempty"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at,omitempty"`
 	AiSummary *string   `json:"ai_summary"`
 }
 
+// UserRef identifies a user in responses.
+type UserRef struct {
+	ID    int64  `json:"id"`
+	Email string `json:"email"`
+}
+
 type CreateTaskRequest struct {
-	Title string `json:"title"`
-	Done  bool   `json:"done"`
+	ParentID *int       `json:"parent_id"`
+	Title    string     `json:"title"`
+	Done     bool       `json:"done"`
+	Status   string     `json:"status"`
+	Priority string     `json:"priority"`
+	DueAt    *time.Time `json:"due_at"`
+	Tags     []string   `json:"tags"`
 }
 
 type UpdateTaskRequest struct {
-	Title *string `json:"title,omitempty"`
-	Done  *bool   `json:"done,omitempty"`
+	Title    *string    `json:"title,omitempty"`
+	Done     *bool      `json:"done,omitempty"`
+	Status   *string    `json:"status,omitempty"`
+	Priority *string    `json:"priority,omitempty"`
+	DueAt    *time.Time `json:"due_at,omitempty"`
+	Tags     *[]string  `json:"tags,omitempty"`
+}
+
+// AssignTaskRequest sets the task's assignee; a null AssigneeID unassigns
+// it.
+type AssignTaskRequest struct {
+	AssigneeID *int64 `json:"assignee_id"`
+}
+
+// TaskEvent is an entry in a task's history.
+type TaskEvent struct {
+	ID        int64           `json:"id"`
+	TaskID    int             `json:"task_id"`
+	ActorID   *int64          `json:"actor_id"`
+	Type      string          `json:"type"`
+	Data      json.RawMessage `json:"data"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// MoveTaskRequest places a task directly before and/or after another task
+// in the manual order. At least one anchor is required.
+type MoveTaskRequest struct {
+	Before *int `json:"before"`
+	After  *int `json:"after"`
+}
+
+// TimeEntry is a span of work on a task. A nil EndedAt means the timer is
+// still running; DurationSeconds then counts up to now.
+type TimeEntry struct {
+	ID              int64      `json:"id"`
+	TaskID          int        `json:"task_id"`
+	StartedAt       time.Time  `json:"started_at"`
+	EndedAt         *time.Time `json:"ended_at"`
+	Note            string     `json:"note"`
+	DurationSeconds int64      `json:"duration_seconds"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+}
+
+type TimeEntryRequest struct {
+	StartedAt *time.Time `json:"started_at"`
+	EndedAt   *time.Time `json:"ended_at"`
+	Note      *string    `json:"note"`
+}
+
+type TimerRequest struct {
+	Note string `json:"note"`
+}
+
+type TimeReportRow struct {
+	Key     string `json:"key"`
+	Label   string `json:"label"`
+	Seconds int64  `json:"seconds"`
+}
+
+type TimeReport struct {
+	From         time.Time       `json:"from"`
+	To           time.Time       `json:"to"`
+	GroupBy      string          `json:"group_by"`
+	TotalSeconds int64           `json:"total_seconds"`
+	Rows         []TimeReportRow `json:"rows"`
+}
+
+// ParsedTask is what the quick-add parser extracted from a line of text.
+// Recurrence is an RFC 5545 RRULE value such as "FREQ=WEEKLY;BYDAY=MO".
+// HasTime is false when DueAt only carries a date (midnight local time).
+type ParsedTask struct {
+	Title      string     `json:"title"`
+	DueAt      *time.Time `json:"due_at"`
+	HasTime    bool       `json:"has_time"`
+	Recurrence string     `json:"recurrence,omitempty"`
+	Tags       []string   `json:"tags"`
+	Priority   string     `json:"priority"`
+}
+
+// QuickAddRequest is the body of POST /tasksdb/quick. Timezone is an IANA
+// name used to resolve relative dates; it defaults to UTC. With Preview set
+// nothing is stored.
+type QuickAddRequest struct {
+	Text     string `json:"text"`
+	Timezone string `json:"timezone"`
+	Preview  bool   `json:"preview"`
+}
+
+type QuickAddResponse struct {
+	Parsed ParsedTask `json:"parsed"`
+	Task   *Task      `json:"task,omitempty"`
+}
+
+// TemplateTask is one task inside a template. Title may contain {{key}}
+// placeholders; DueOffset ("3d", "1d12h", "-2h") is relative to the moment
+// the template is instantiated.
+type TemplateTask struct {
+	Title     string   `json:"title"`
+	Priority  string   `json:"priority"`
+	Tags      []string `json:"tags"`
+	DueOffset string   `json:"due_offset,omitempty"`
+}
+
+// TaskTemplate is a named blueprint for a task and its subtasks.
+type TaskTemplate struct {
+	ID       int64          `json:"id"`
+	Name     string         `json:"name"`
+	Task     TemplateTask   `json:"task"`
+	Subtasks []TemplateTask `json:"subtasks"`
+	// Variables lists the placeholders used anywhere in the template.
+	Variables []string  `json:"variables"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type TemplateRequest struct {
+	Name     string         `json:"name"`
+	Task     TemplateTask   `json:"task"`
+	Subtasks []TemplateTask `json:"subtasks"`
+}
+
+// InstantiateTemplateRequest supplies placeholder values. {{date}} defaults
+// to the instantiation date in Timezone (UTC if empty).
+type InstantiateTemplateRequest struct {
+	Variables map[string]string `json:"variables"`
+	Timezone  string            `json:"timezone"`
+}
+
+type InstantiateTemplateResponse struct {
+	Task     Task   `json:"task"`
+	Subtasks []Task `json:"subtasks"`
+}
+
+type SaveAsTemplateRequest struct {
+	Name string `json:"name"`
+}
+
+// Workflow status categories. Every workflow needs at least one "todo" and
+// one "done" status so the legacy done flag can be mapped onto it.
+const (
+	CategoryTodo       = "todo"
+	CategoryInProgress = "in_progress"
+	CategoryDone       = "done"
+)
+
+// WorkflowStatus is one column of a user's workflow. Transitions lists the
+// status keys a task may move to from here; null means any status, an empty
+// list means none. A nil WIPLimit means unlimited.
+type WorkflowStatus struct {
+	Key         string   `json:"key"`
+	Name        string   `json:"name"`
+	Category    string   `json:"category"`
+	WIPLimit    *int     `json:"wip_limit"`
+	Transitions []string `json:"transitions"`
+}
+
+type Workflow struct {
+	Statuses []WorkflowStatus `json:"statuses"`
+}
+
+// UpdateWorkflowRequest replaces the user's workflow. Tasks whose status no
+// longer exists must be moved with Remap (old key -> new key).
+type UpdateWorkflowRequest struct {
+	Statuses []WorkflowStatus  `json:"statuses"`
+	Remap    map[string]string `json:"remap"`
+}
+
+type BoardColumn struct {
+	WorkflowStatus
+	Count     int    `json:"count"`
+	OverLimit bool   `json:"over_limit"`
+	Tasks     []Task `json:"tasks"`
+}
+
+type Board struct {
+	Columns []BoardColumn `json:"columns"`
+}
+
+type ImportResponse struct {
+	Imported int            `json:"imported"`
+	Tasks    []Task         `json:"tasks"`
+	Unmapped map[string]int `json:"unmapped"`
 }
 
 type RegisterRequest struct {
 	Email    string `json:"email"`
 	Password string `json:"password"`
+	// InviteToken, if set, is accepted for the new account.
+	InviteToken string `json:"invite_token,omitempty"`
 }
 
 type UserResponse struct {
-	ID    int64  `json:"id"`
-	Email string `json:"email"`
+	ID            int64  `json:"id"`
+	Email         string `json:"email"`
+	EmailVerified bool   `json:"email_verified"`
 }
 
 type LoginRequest struct {
@@ -42,8 +267,377 @@ type LoginRequest struct {
 	Password string `json:"password"`
 }
 
+// TokenResponse is returned by login and refresh. Token is the short-lived
+// access token; RefreshToken can be exchanged once for a new pair.
+type TokenResponse struct {
+	SessionID        int64     `json:"session_id"`
+	Token            string    `json:"token"`
+	ExpiresAt        time.Time `json:"expires_at"`
+	RefreshToken     string    `json:"refresh_token"`
+	RefreshExpiresAt time.Time `json:"refresh_expires_at"`
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+type VerifyEmailRequest struct {
+	Token string `json:"token"`
+}
+
+type ResendVerificationRequest struct {
+	Email string `json:"email"`
+}
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email"`
+}
+
+type ResetPasswordRequest struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+// MFAChallenge is returned by login instead of tokens when the user has
+// 2FA enabled; MFAToken goes to POST /login/mfa together with a code.
+type MFAChallenge struct {
+	MFARequired bool      `json:"mfa_required"`
+	MFAToken    string    `json:"mfa_token"`
+	ExpiresAt   time.Time `json:"expires_at"`
+}
+
+// MFALoginRequest completes a login with either a TOTP code or one of the
+// recovery codes.
+type MFALoginRequest struct {
+	MFAToken     string `json:"mfa_token"`
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recovery_code"`
+}
+
+type MFAStatus struct {
+	Enabled                bool       `json:"enabled"`
+	EnabledAt              *time.Time `json:"enabled_at,omitempty"`
+	RecoveryCodesRemaining int        `json:"recovery_codes_remaining"`
+}
+
+// TOTPEnrollment holds the new secret; OTPAuthURI is the QR code payload.
+type TOTPEnrollment struct {
+	Secret     string `json:"secret"`
+	OTPAuthURI string `json:"otpauth_uri"`
+}
+
+type MFACodeRequest struct {
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recovery_code"`
+}
+
+// MFADisableRequest needs the password and a second factor.
+type MFADisableRequest struct {
+	Password     string `json:"password"`
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recovery_code"`
+}
+
+// RecoveryCodes are shown once; only their hashes are kept.
+type RecoveryCodes struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// APIKey describes a personal API key; the key itself is only returned
+// once, in CreatedAPIKey.
+type APIKey struct {
+	ID         int64      `json:"id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+type CreatedAPIKey struct {
+	APIKey
+	Key string `json:"key"`
+}
+
+type APIKeyRequest struct {
+	Name      string     `json:"name"`
+	Scopes    []string   `json:"scopes"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+// Session is one login of the user, from the device it was started on.
+// Current marks the session the request was made with.
+type Session struct {
+	ID         int64     `json:"id"`
+	UserAgent  string    `json:"user_agent"`
+	IP         string    `json:"ip"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+	Current    bool      `json:"current"`
+}
+
 type LoginAuth struct {
-	ID           int64
-	Email        string
-	PasswordHash string
+	ID            int64
+	Email         string
+	PasswordHash  string
+	Role          string
+	Disabled      bool
+	ResetRequired bool
+	EmailVerified bool
+	MFAEnabled    bool
+}
+
+// ValidPriority reports whether p is one of the known task priorities.
+func ValidPriority(p string) bool {
+	switch p {
+	case PriorityNone, PriorityLow, PriorityMedium, PriorityHigh:
+		return true
+	}
+	return false
+}
+
+// NormalizeTags trims tags, strips a leading '#' or '+', replaces inner
+// whitespace with '-' and drops empty and duplicate entries. The result is
+// never nil so it encodes as [] rather than null.
+func NormalizeTags(tags []string) []string {
+	out := make([]string, 0, len(tags))
+	seen := make(map[string]bool, len(tags))
+	for _, tag := range tags {
+		tag = strings.TrimLeft(strings.TrimSpace(tag), "#+")
+		tag = strings.Join(strings.Fields(tag), "-")
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		out = append(out, tag)
+	}
+	return out
+}
+
+// StatsBucket counts tasks created and completed in one day or week.
+type StatsBucket struct {
+	Start     string `json:"start"`
+	Created   int    `json:"created"`
+	Completed int    `json:"completed"`
+}
+
+// Stats summarises a user's throughput over a date range. Open counts and
+// streaks describe the current state, not just the range.
+type Stats struct {
+	From                        time.Time     `json:"from"`
+	To                          time.Time     `json:"to"`
+	Bucket                      string        `json:"bucket"`
+	Series                      []StatsBucket `json:"series"`
+	CreatedTotal                int           `json:"created_total"`
+	CompletedTotal              int           `json:"completed_total"`
+	MedianTimeToCompleteSeconds *int64        `json:"median_time_to_complete_seconds"`
+	OpenCount                   int           `json:"open_count"`
+	OldestOpen                  []Task        `json:"oldest_open"`
+	CurrentStreakDays           int           `json:"current_streak_days"`
+	LongestStreakDays           int           `json:"longest_streak_days"`
+}
+
+// UserSettings holds per-user preferences. A nil ArchiveDoneAfterDays turns
+// automatic archiving off.
+type UserSettings struct {
+	ArchiveDoneAfterDays *int `json:"archive_done_after_days"`
+}
+
+// Attachment describes a file stored for a task. The content itself lives in
+// a blob store.
+type Attachment struct {
+	ID          int64     `json:"id"`
+	TaskID      int       `json:"task_id"`
+	Filename    string    `json:"filename"`
+	ContentType string    `json:"content_type"`
+	SizeBytes   int64     `json:"size_bytes"`
+	SHA256      string    `json:"sha256"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// AttachmentURL is a time-limited download link.
+type AttachmentURL struct {
+	URL       string    `json:"url"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// CommentMention is a user resolved from an "@email" mention.
+type CommentMention struct {
+	UserID int64  `json:"user_id"`
+	Email  string `json:"email"`
+}
+
+// Comment is a Markdown note on a task. BodyHTML is the rendered, escaped
+// form of Body.
+type Comment struct {
+	ID          int64            `json:"id"`
+	TaskID      int              `json:"task_id"`
+	AuthorID    int64            `json:"author_id"`
+	AuthorEmail string           `json:"author_email"`
+	Body        string           `json:"body"`
+	BodyHTML    string           `json:"body_html"`
+	Mentions    []CommentMention `json:"mentions"`
+	Edited      bool             `json:"edited"`
+	EditedAt    *time.Time       `json:"edited_at"`
+	CreatedAt   time.Time        `json:"created_at"`
+	UpdatedAt   time.Time        `json:"updated_at"`
+}
+
+type CommentRequest struct {
+	Body string `json:"body"`
+}
+
+// Notification tells a user about something that concerns them. Data holds
+// type-specific details such as the comment ID of a mention.
+type Notification struct {
+	ID        int64           `json:"id"`
+	UserID    int64           `json:"-"`
+	Type      string          `json:"type"`
+	TaskID    *int            `json:"task_id"`
+	ActorID   *int64          `json:"actor_id"`
+	Title     string          `json:"title"`
+	Body      string          `json:"body"`
+	Data      json.RawMessage `json:"data"`
+	ReadAt    *time.Time      `json:"read_at"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// UnreadNotifications counts unread notifications in total and per type.
+type UnreadNotifications struct {
+	Total  int            `json:"total"`
+	ByType map[string]int `json:"by_type"`
+}
+
+// Workspace groups tasks shared by its members. Role is the requesting
+// user's role in it.
+type Workspace struct {
+	ID        int64     `json:"id"`
+	Name      string    `json:"name"`
+	Personal  bool      `json:"personal"`
+	Role      string    `json:"role"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type WorkspaceRequest struct {
+	Name string `json:"name"`
+}
+
+type WorkspaceMember struct {
+	UserID    int64     `json:"user_id"`
+	Email     string    `json:"email"`
+	Role      string    `json:"role"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// AddMemberRequest adds an existing user, found by email, to a workspace.
+type AddMemberRequest struct {
+	Email string `json:"email"`
+	Role  string `json:"role"`
+}
+
+type UpdateMemberRequest struct {
+	Role string `json:"role"`
+}
+
+// Share types: a whole personal task list or a single task with its
+// subtasks.
+const (
+	ShareList = "list"
+	ShareTask = "task"
+)
+
+// ShareGrant gives Grantee access to Owner's list or to TaskID.
+type ShareGrant struct {
+	ID         int64     `json:"id"`
+	Type       string    `json:"type"`
+	TaskID     *int      `json:"task_id"`
+	Owner      UserRef   `json:"owner"`
+	Grantee    UserRef   `json:"grantee"`
+	Permission string    `json:"permission"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// ShareRequest grants the user with Email access; TaskID is required for
+// type "task".
+type ShareRequest struct {
+	Type       string `json:"type"`
+	TaskID     *int   `json:"task_id"`
+	Email      string `json:"email"`
+	Permission string `json:"permission"`
+}
+
+// Invite types.
+const (
+	InviteWorkspace = "workspace"
+	InviteList      = "list"
+)
+
+// Invite statuses, derived from the timestamps.
+const (
+	InvitePending  = "pending"
+	InviteAccepted = "accepted"
+	InviteRevoked  = "revoked"
+	InviteExpired  = "expired"
+)
+
+// Invite asks Email to join a workspace or to take a share of the inviter's
+// list. Role is the workspace role or the share permission.
+type Invite struct {
+	ID          int64      `json:"id"`
+	Type        string     `json:"type"`
+	Email       string     `json:"email"`
+	WorkspaceID int64      `json:"workspace_id"`
+	Role        string     `json:"role"`
+	Inviter     UserRef    `json:"inviter"`
+	Status      string     `json:"status"`
+	ExpiresAt   time.Time  `json:"expires_at"`
+	AcceptedAt  *time.Time `json:"accepted_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+// InviteRequest creates an invite; WorkspaceID is required for type
+// "workspace".
+type InviteRequest struct {
+	Email       string `json:"email"`
+	Type        string `json:"type"`
+	WorkspaceID *int64 `json:"workspace_id"`
+	Role        string `json:"role"`
+}
+
+// AdminUser is a user as listed to admins.
+type AdminUser struct {
+	ID                    int64          `json:"id"`
+	Email                 string         `json:"email"`
+	Role                  string         `json:"role"`
+	DisabledAt            *time.Time     `json:"disabled_at"`
+	PasswordResetRequired bool           `json:"password_reset_required"`
+	Tasks                 UserTaskCounts `json:"tasks"`
+	CreatedAt             time.Time      `json:"created_at"`
+}
+
+// UserTaskCounts counts the tasks a user created.
+type UserTaskCounts struct {
+	Total    int `json:"total"`
+	Open     int `json:"open"`
+	Done     int `json:"done"`
+	Archived int `json:"archived"`
+}
+
+// ImpersonateRequest explains why an admin acts as another user; the
+// reason goes to the audit log.
+type ImpersonateRequest struct {
+	Reason string `json:"reason"`
+}
+
+// AdminAuditEntry is one administrative action.
+type AdminAuditEntry struct {
+	ID           int64           `json:"id"`
+	AdminID      *int64          `json:"admin_id"`
+	TargetUserID *int64          `json:"target_user_id"`
+	Action       string          `json:"action"`
+	Data         json.RawMessage `json:"data"`
+	IP           string          `json:"ip"`
+	CreatedAt    time.Time       `json:"created_at"`
 }
@@ -0,0 +1,150 @@
+// Package notifications records events that concern a user and runs the
+// background jobs that produce and expire them.
+package notifications
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"time"
+
+	"gotasker/internal/models"
+)
+
+// Notification types.
+const (
+	TypeReminder   = "reminder"
+	TypeMention    = "mention"
+	TypeAssignment = "assignment"
+	TypeShare      = "share"
+)
+
+// Types lists every notification type, for validating filters.
+var Types = []string{TypeReminder, TypeMention, TypeAssignment, TypeShare}
+
+const (
+	// ReminderLead is how long before its due date a task's reminder fires.
+	ReminderLead = 15 * time.Minute
+	// ReadRetention and UnreadRetention bound how long notifications are
+	// kept after they were created.
+	ReadRetention   = 30 * 24 * time.Hour
+	UnreadRetention = 90 * 24 * time.Hour
+
+	reminderBatch    = 500
+	reminderInterval = time.Minute
+	cleanupInterval  = time.Hour
+)
+
+// execer is satisfied by both *sql.DB and *sql.Tx.
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// Notify stores notifications. Empty Data is stored as an empty object.
+func Notify(ctx context.Context, q execer, ns ...models.Notification) error {
+	for _, n := range ns {
+		data := string(n.Data)
+		if data == "" {
+			data = "{}"
+		}
+		if _, err := q.ExecContext(ctx, `
+			INSERT INTO notifications (user_id, type, task_id, actor_id, title, body, data)
+			VALUES ($1, $2, $3, $4, $5, $6, $7::jsonb)`,
+			n.UserID, n.Type, n.TaskID, n.ActorID, n.Title, n.Body, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// FireReminders creates a reminder for every open task that falls due within
+// ReminderLead and has not been reminded yet. It returns how many fired.
+func FireReminders(ctx context.Context, db *sql.DB) (int, error) {
+	res, err := db.ExecContext(ctx, `
+		WITH due AS (
+			UPDATE tasks SET reminded_at = NOW()
+			WHERE id IN (
+				SELECT id FROM tasks
+				WHERE reminded_at IS NULL AND done = FALSE AND archived_at IS NULL
+					AND due_at <= NOW() + $1 * INTERVAL '1 second'
+				ORDER BY due_at
+				LIMIT $2
+				FOR UPDATE SKIP LOCKED
+			)
+			RETURNING id, user_id, title, due_at
+		)
+		INSERT INTO notifications (user_id, type, task_id, title, body, data)
+		SELECT user_id, $3, id, title,
+			CASE WHEN due_at <= NOW() THEN 'Task is due' ELSE 'Task is due soon' END,
+			jsonb_build_object('due_at', due_at)
+		FROM due`,
+		int64(ReminderLead/time.Second), reminderBatch, TypeReminder)
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	return int(n), err
+}
+
+// Cleanup deletes read notifications older than ReadRetention and any older
+// than UnreadRetention.
+func Cleanup(ctx context.Context, db *sql.DB) (int64, error) {
+	res, err := db.ExecContext(ctx, `
+		DELETE FROM notifications
+		WHERE (read_at IS NOT NULL AND created_at < NOW() - $1 * INTERVAL '1 second')
+			OR created_at < NOW() - $2 * INTERVAL '1 second'`,
+		int64(ReadRetention/time.Second), int64(UnreadRetention/time.Second))
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// Worker fires reminders every minute and applies retention every hour.
+type Worker struct {
+	db *sql.DB
+}
+
+func NewWorker(db *sql.DB) *Worker {
+	return &Worker{db: db}
+}
+
+// Run blocks until ctx is cancelled.
+func (wk *Worker) Run(ctx context.Context) {
+	reminders := time.NewTicker(reminderInterval)
+	defer reminders.Stop()
+	cleanup := time.NewTicker(cleanupInterval)
+	defer cleanup.Stop()
+
+	wk.cleanup(ctx)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-reminders.C:
+			for {
+				n, err := FireReminders(ctx, wk.db)
+				if err != nil {
+					log.Printf("firing reminders failed: %v", err)
+					break
+				}
+				if n < reminderBatch {
+					break
+				}
+			}
+		case <-cleanup.C:
+			wk.cleanup(ctx)
+		}
+	}
+}
+
+func (wk *Worker) cleanup(ctx context.Context) {
+	n, err := Cleanup(ctx, wk.db)
+	if err != nil {
+		log.Printf("notification cleanup failed: %v", err)
+		return
+	}
+	if n > 0 {
+		log.Printf("deleted %d old notifications", n)
+	}
+}
@@ -0,0 +1,146 @@
+package oidc_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"gotasker/internal/oidc"
+	"gotasker/internal/oidc/oidctest"
+)
+
+const redirectURL = "http://app.test/auth/oidc/callback"
+
+// newProvider starts a mock provider and a client registered with it.
+func newProvider(t *testing.T, secret string) (*oidctest.Provider, *oidc.Client) {
+	t.Helper()
+	var p *oidctest.Provider
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p.Handler().ServeHTTP(w, r)
+	}))
+	t.Cleanup(srv.Close)
+
+	p, err := oidctest.NewProvider(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.ClientSecret = secret
+	c, err := oidc.NewClient(oidc.Config{
+		Issuer:       srv.URL,
+		ClientID:     "gotasker",
+		ClientSecret: secret,
+		RedirectURL:  redirectURL,
+	}, srv.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p, c
+}
+
+// authorize visits the provider's authorization URL and returns the code
+// it redirects back with, checking the state comes back unchanged.
+func authorize(t *testing.T, c *oidc.Client, state, nonce, verifier, loginHint string) string {
+	t.Helper()
+	u, err := c.AuthCodeURL(context.Background(), state, nonce, verifier, loginHint)
+	if err != nil {
+		t.Fatal(err)
+	}
+	noFollow := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	resp, err := noFollow.Get(u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusFound {
+		t.Fatalf("authorize: status %d", resp.StatusCode)
+	}
+	back, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(back.String(), redirectURL+"?") {
+		t.Fatalf("redirected to %s, want %s", back, redirectURL)
+	}
+	if got := back.Query().Get("state"); got != state {
+		t.Fatalf("state %q, want %q", got, state)
+	}
+	return back.Query().Get("code")
+}
+
+func TestAuthCodeURLUsesPKCE(t *testing.T) {
+	_, c := newProvider(t, "")
+	u, err := c.AuthCodeURL(context.Background(), "st", "nc", "verifier", "bob@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := url.Parse(u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := parsed.Query()
+	for key, want := range map[string]string{
+		"response_type":         "code",
+		"client_id":             "gotasker",
+		"redirect_uri":          redirectURL,
+		"state":                 "st",
+		"nonce":                 "nc",
+		"code_challenge":        oidc.CodeChallenge("verifier"),
+		"code_challenge_method": "S256",
+		"login_hint":            "bob@example.com",
+	} {
+		if got := q.Get(key); got != want {
+			t.Errorf("%s = %q, want %q", key, got, want)
+		}
+	}
+	if q.Get("code_challenge") == "verifier" {
+		t.Error("the verifier itself was sent")
+	}
+}
+
+func TestExchange(t *testing.T) {
+	_, c := newProvider(t, "s3cret")
+	ctx := context.Background()
+
+	code := authorize(t, c, "st", "nc", "verifier", "Bob@Example.com")
+	claims, err := c.Exchange(ctx, code, "verifier", "nc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.Email != "bob@example.com" || !bool(claims.EmailVerified) || claims.Subject != "mock|bob@example.com" {
+		t.Errorf("claims %+v", claims)
+	}
+
+	// A code works once.
+	if _, err := c.Exchange(ctx, code, "verifier", "nc"); err == nil {
+		t.Error("redeeming a code twice: want error")
+	}
+}
+
+func TestExchangeChecksPKCEAndNonce(t *testing.T) {
+	_, c := newProvider(t, "")
+	ctx := context.Background()
+
+	code := authorize(t, c, "st", "nc", "verifier", "")
+	if _, err := c.Exchange(ctx, code, "another-verifier", "nc"); err == nil {
+		t.Error("wrong code_verifier: want error")
+	}
+
+	code = authorize(t, c, "st", "nc", "verifier", "")
+	if _, err := c.Exchange(ctx, code, "verifier", "another-nonce"); err == nil || !strings.Contains(err.Error(), "nonce") {
+		t.Errorf("wrong nonce: %v, want a nonce mismatch", err)
+	}
+}
+
+func TestExchangeChecksClientSecret(t *testing.T) {
+	p, c := newProvider(t, "s3cret")
+	p.ClientSecret = "rotated"
+	code := authorize(t, c, "st", "nc", "verifier", "")
+	if _, err := c.Exchange(context.Background(), code, "verifier", "nc"); err == nil {
+		t.Error("wrong client secret: want error")
+	}
+}
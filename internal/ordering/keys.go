@@ -0,0 +1,302 @@
+// Package ordering generates fractional-index position keys: strings that
+// sort by plain byte comparison, where a new key can always be made between
+// any two existing ones. Moving an item therefore only rewrites that item.
+//
+// Keys have an integer part whose first character encodes its length
+// ('a'..'z' for positive, 'A'..'Z' for negative integers) followed by an
+// optional base-62 fraction without trailing zeros, e.g. "a0", "a1V", "b12".
+// Appending or prepending bumps the integer part, so keys stay short under
+// the common "add to the end" pattern; repeated inserts into the same gap
+// grow the fraction, which Rebalancer later compacts.
+package ordering
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const digits = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+// smallestInteger cannot be decremented, so it is not a valid key on its own.
+var smallestInteger = "A" + strings.Repeat(string(digits[0]), 26)
+
+var ErrInvalidKey = errors.New("invalid position key")
+
+// KeyBetween returns a key that sorts strictly after a and before b. An
+// empty a means "before everything", an empty b "after everything".
+func KeyBetween(a, b string) (string, error) {
+	if a != "" {
+		if err := validateKey(a); err != nil {
+			return "", err
+		}
+	}
+	if b != "" {
+		if err := validateKey(b); err != nil {
+			return "", err
+		}
+	}
+	if a != "" && b != "" && a >= b {
+		return "", fmt.Errorf("%w: %q >= %q", ErrInvalidKey, a, b)
+	}
+
+	if a == "" {
+		if b == "" {
+			return "a" + string(digits[0]), nil
+		}
+		ib, _ := integerPart(b)
+		fb := b[len(ib):]
+		if ib == smallestInteger {
+			m, err := midpoint("", fb, false)
+			return ib + m, err
+		}
+		if ib < b {
+			return ib, nil
+		}
+		res, ok := decrementInteger(ib)
+		if !ok {
+			return "", errors.New("cannot decrement position key")
+		}
+		return res, nil
+	}
+
+	if b == "" {
+		ia, _ := integerPart(a)
+		fa := a[len(ia):]
+		if i, ok := incrementInteger(ia); ok {
+			return i, nil
+		}
+		m, err := midpoint(fa, "", true)
+		return ia + m, err
+	}
+
+	ia, _ := integerPart(a)
+	fa := a[len(ia):]
+	ib, _ := integerPart(b)
+	fb := b[len(ib):]
+	if ia == ib {
+		m, err := midpoint(fa, fb, false)
+		return ia + m, err
+	}
+	i, ok := incrementInteger(ia)
+	if !ok {
+		return "", errors.New("cannot increment position key")
+	}
+	if i < b {
+		return i, nil
+	}
+	m, err := midpoint(fa, "", true)
+	return ia + m, err
+}
+
+// NKeysBetween returns n ascending keys between a and b, spread evenly so a
+// rebalanced list gets short keys.
+func NKeysBetween(a, b string, n int) ([]string, error) {
+	switch {
+	case n <= 0:
+		return nil, nil
+	case n == 1:
+		k, err := KeyBetween(a, b)
+		if err != nil {
+			return nil, err
+		}
+		return []string{k}, nil
+	case b == "":
+		out := make([]string, 0, n)
+		c := a
+		for i := 0; i < n; i++ {
+			k, err := KeyBetween(c, "")
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, k)
+			c = k
+		}
+		return out, nil
+	case a == "":
+		out := make([]string, n)
+		c := b
+		for i := n - 1; i >= 0; i-- {
+			k, err := KeyBetween("", c)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = k
+			c = k
+		}
+		return out, nil
+	}
+
+	mid := n / 2
+	c, err := KeyBetween(a, b)
+	if err != nil {
+		return nil, err
+	}
+	left, err := NKeysBetween(a, c, mid)
+	if err != nil {
+		return nil, err
+	}
+	right, err := NKeysBetween(c, b, n-mid-1)
+	if err != nil {
+		return nil, err
+	}
+	return append(append(left, c), right...), nil
+}
+
+// midpoint returns a fraction strictly between a and b. With open set, b is
+// unbounded and ignored.
+func midpoint(a, b string, open bool) (string, error) {
+	zero := digits[0]
+	if !open && a >= b {
+		return "", fmt.Errorf("%w: %q >= %q", ErrInvalidKey, a, b)
+	}
+	if (a != "" && a[len(a)-1] == zero) || (!open && b != "" && b[len(b)-1] == zero) {
+		return "", fmt.Errorf("%w: trailing zero", ErrInvalidKey)
+	}
+
+	if !open {
+		// Skip the common prefix, padding a with zeros.
+		n := 0
+		for n < len(b) {
+			ca := zero
+			if n < len(a) {
+				ca = a[n]
+			}
+			if ca != b[n] {
+				break
+			}
+			n++
+		}
+		if n > 0 {
+			rest := ""
+			if n < len(a) {
+				rest = a[n:]
+			}
+			m, err := midpoint(rest, b[n:], false)
+			return b[:n] + m, err
+		}
+	}
+
+	digitA := 0
+	if a != "" {
+		digitA = strings.IndexByte(digits, a[0])
+	}
+	digitB := len(digits)
+	if !open {
+		digitB = strings.IndexByte(digits, b[0])
+	}
+
+	if digitB-digitA > 1 {
+		return string(digits[(digitA+digitB+1)/2]), nil
+	}
+	if !open && len(b) > 1 {
+		return b[:1], nil
+	}
+	rest := ""
+	if len(a) > 1 {
+		rest = a[1:]
+	}
+	m, err := midpoint(rest, "", true)
+	return string(digits[digitA]) + m, err
+}
+
+func integerLength(head byte) (int, bool) {
+	switch {
+	case head >= 'a' && head <= 'z':
+		return int(head-'a') + 2, true
+	case head >= 'A' && head <= 'Z':
+		return int('Z'-head) + 2, true
+	}
+	return 0, false
+}
+
+func integerPart(key string) (string, error) {
+	if key == "" {
+		return "", ErrInvalidKey
+	}
+	n, ok := integerLength(key[0])
+	if !ok || n > len(key) {
+		return "", ErrInvalidKey
+	}
+	return key[:n], nil
+}
+
+func validateKey(key string) error {
+	if key == smallestInteger {
+		return ErrInvalidKey
+	}
+	i, err := integerPart(key)
+	if err != nil {
+		return err
+	}
+	for j := 1; j < len(key); j++ {
+		if strings.IndexByte(digits, key[j]) < 0 {
+			return ErrInvalidKey
+		}
+	}
+	if f := key[len(i):]; f != "" && f[len(f)-1] == digits[0] {
+		return ErrInvalidKey
+	}
+	return nil
+}
+
+func incrementInteger(x string) (string, bool) {
+	head, digs := x[0], []byte(x[1:])
+	carry := true
+	for i := len(digs) - 1; carry && i >= 0; i-- {
+		d := strings.IndexByte(digits, digs[i]) + 1
+		if d == len(digits) {
+			digs[i] = digits[0]
+		} else {
+			digs[i] = digits[d]
+			carry = false
+		}
+	}
+	if !carry {
+		return string(head) + string(digs), true
+	}
+	switch head {
+	case 'Z':
+		return "a" + string(digits[0]), true
+	case 'z':
+		return "", false
+	}
+	h := head + 1
+	if h > 'a' {
+		digs = append(digs, digits[0])
+	} else {
+		digs = digs[:len(digs)-1]
+	}
+	return string(h) + string(digs), true
+}
+
+func decrementInteger(x string) (string, bool) {
+	head, digs := x[0], []byte(x[1:])
+	borrow := true
+	last := digits[len(digits)-1]
+	for i := len(digs) - 1; borrow && i >= 0; i-- {
+		d := strings.IndexByte(digits, digs[i]) - 1
+		if d == -1 {
+			digs[i] = last
+		} else {
+			digs[i] = digits[d]
+			borrow = false
+		}
+	}
+	if !borrow {
+		return string(head) + string(digs), true
+	}
+	switch head {
+	case 'a':
+		return "Z" + string(last), true
+	case 'A':
+		return "", false
+	}
+	h := head - 1
+	if h < 'Z' {
+		digs = append(digs, last)
+	} else {
+		digs = digs[:len(digs)-1]
+	}
+	return string(h) + string(digs), true
+}
@@ -0,0 +1,124 @@
+package ordering
+
+import (
+	"context"
+	"database/sql"
+	"log"
+
+	cache "gotasker/internal/redis"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// MaxKeyLength is the position length past which a workspace's list is
+// queued for rebalancing.
+const MaxKeyLength = 24
+
+// queryExecer is satisfied by both *sql.DB and *sql.Tx.
+type queryExecer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+// Rebalance rewrites every position in the workspace with evenly spaced
+// short keys, keeping the current order. Tasks without a position keep their
+// place after positioned ones, newest first. Run it inside a transaction.
+func Rebalance(ctx context.Context, q queryExecer, workspaceID int64) error {
+	rows, err := q.QueryContext(ctx, `
+		SELECT id FROM tasks
+		WHERE workspace_id = $1
+		ORDER BY position NULLS LAST, created_at DESC, id DESC
+		FOR UPDATE`, workspaceID)
+	if err != nil {
+		return err
+	}
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	keys, err := NKeysBetween("", "", len(ids))
+	if err != nil {
+		return err
+	}
+
+	_, err = q.ExecContext(ctx, `
+		UPDATE tasks AS t
+		SET position = v.position
+		FROM unnest($2::bigint[], $3::text[]) AS v(id, position)
+		WHERE t.id = v.id AND t.workspace_id = $1`, workspaceID, ids, keys)
+	return err
+}
+
+// Rebalancer compacts position keys in the background. Handlers call
+// Enqueue when they produce a key longer than MaxKeyLength.
+type Rebalancer struct {
+	db    *sql.DB
+	rdb   *redis.Client
+	queue chan int64
+}
+
+func NewRebalancer(db *sql.DB, rdb *redis.Client) *Rebalancer {
+	return &Rebalancer{
+		db:    db,
+		rdb:   rdb,
+		queue: make(chan int64, 64),
+	}
+}
+
+// Enqueue schedules a rebalance for workspaceID without blocking. If the
+// queue is full the request is dropped; the next long key will enqueue it
+// again.
+func (rb *Rebalancer) Enqueue(workspaceID int64) {
+	select {
+	case rb.queue <- workspaceID:
+	default:
+		log.Printf("rebalance queue full, skipping workspace %d", workspaceID)
+	}
+}
+
+// Run processes queued workspaces until ctx is cancelled.
+func (rb *Rebalancer) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case workspaceID := <-rb.queue:
+			if err := rb.rebalanceWorkspace(ctx, workspaceID); err != nil {
+				log.Printf("rebalance workspace %d failed: %v", workspaceID, err)
+			}
+		}
+	}
+}
+
+func (rb *Rebalancer) rebalanceWorkspace(ctx context.Context, workspaceID int64) error {
+	tx, err := rb.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := Rebalance(ctx, tx, workspaceID); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	if err := cache.DeleteWorkspaceTasks(ctx, rb.rdb, workspaceID); err != nil {
+		log.Printf("Redis DEL failed: %v", err)
+	}
+	return nil
+}
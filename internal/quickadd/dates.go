@@ -0,0 +1,235 @@
+package quickadd
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+var weekdays = map[string]time.Weekday{
+	"sunday": time.Sunday, "sun": time.Sunday,
+	"monday": time.Monday, "mon": time.Monday,
+	"tuesday": time.Tuesday, "tue": time.Tuesday, "tues": time.Tuesday,
+	"wednesday": time.Wednesday, "wed": time.Wednesday,
+	"thursday": time.Thursday, "thu": time.Thursday, "thur": time.Thursday, "thurs": time.Thursday,
+	"friday": time.Friday, "fri": time.Friday,
+	"saturday": time.Saturday, "sat": time.Saturday,
+}
+
+var months = map[string]time.Month{
+	"january": time.January, "jan": time.January,
+	"february": time.February, "feb": time.February,
+	"march": time.March, "mar": time.March,
+	"april": time.April, "apr": time.April,
+	"may":  time.May,
+	"june": time.June, "jun": time.June,
+	"july": time.July, "jul": time.July,
+	"august": time.August, "aug": time.August,
+	"september": time.September, "sep": time.September, "sept": time.September,
+	"october": time.October, "oct": time.October,
+	"november": time.November, "nov": time.November,
+	"december": time.December, "dec": time.December,
+}
+
+// dateExpr parses a date expression starting at i, stores it on p and returns
+// the number of words used.
+func (p *parser) dateExpr(i int) int {
+	today := civilDate{p.now.Year(), p.now.Month(), p.now.Day()}
+	w := p.word(i)
+
+	switch w {
+	case "today", "tod", "tonight":
+		p.date = &today
+		if w == "tonight" && p.clock == nil {
+			p.clock = &clockTime{hour: 20}
+		}
+		return 1
+	case "tomorrow", "tmr", "tmrw":
+		d := today.addDays(1)
+		p.date = &d
+		return 1
+	}
+
+	// "friday", "next friday", "this friday": the next such day after today.
+	if w == "next" || w == "this" {
+		if wd, ok := weekdays[p.word(i+1)]; ok {
+			d := nextWeekday(today, wd)
+			p.date = &d
+			return 2
+		}
+		if w == "next" {
+			switch p.word(i + 1) {
+			case "week":
+				d := today.addDays(7)
+				p.date = &d
+				return 2
+			case "month":
+				t := time.Date(today.year, today.month+1, 1, 0, 0, 0, 0, time.UTC)
+				d := civilDate{t.Year(), t.Month(), min(today.day, daysIn(t.Year(), t.Month()))}
+				p.date = &d
+				return 2
+			}
+		}
+		return 0
+	}
+	if wd, ok := weekdays[w]; ok {
+		d := nextWeekday(today, wd)
+		p.date = &d
+		return 1
+	}
+
+	// "in 3 days", "in 2 hours".
+	if w == "in" {
+		n, err := strconv.Atoi(p.word(i + 1))
+		if err != nil || n <= 0 {
+			return 0
+		}
+		unit := strings.TrimSuffix(p.word(i+2), "s")
+		switch unit {
+		case "minute", "min":
+			t := p.now.Add(time.Duration(n) * time.Minute)
+			p.instant = &t
+		case "hour", "hr":
+			t := p.now.Add(time.Duration(n) * time.Hour)
+			p.instant = &t
+		case "day":
+			d := today.addDays(n)
+			p.date = &d
+		case "week":
+			d := today.addDays(7 * n)
+			p.date = &d
+		case "month":
+			t := time.Date(today.year, today.month+time.Month(n), 1, 0, 0, 0, 0, time.UTC)
+			d := civilDate{t.Year(), t.Month(), min(today.day, daysIn(t.Year(), t.Month()))}
+			p.date = &d
+		default:
+			return 0
+		}
+		return 3
+	}
+
+	// 2026-03-01
+	if t, err := time.Parse("2006-01-02", w); err == nil {
+		p.date = &civilDate{t.Year(), t.Month(), t.Day()}
+		return 1
+	}
+
+	// "mar 5", "march 5th", "5 mar", "5th of march".
+	if m, ok := months[w]; ok {
+		if day, ok := parseOrdinal(p.word(i + 1)); ok {
+			p.date = upcoming(today, m, day)
+			return 2
+		}
+		return 0
+	}
+	if day, ok := parseOrdinal(w); ok {
+		if m, ok := months[p.word(i+1)]; ok {
+			p.date = upcoming(today, m, day)
+			return 2
+		}
+		if p.word(i+1) == "of" {
+			if m, ok := months[p.word(i+2)]; ok {
+				p.date = upcoming(today, m, day)
+				return 3
+			}
+		}
+	}
+	return 0
+}
+
+// nextWeekday returns the first wd strictly after today.
+func nextWeekday(today civilDate, wd time.Weekday) civilDate {
+	diff := (int(wd) - int(today.weekday()) + 7) % 7
+	if diff == 0 {
+		diff = 7
+	}
+	return today.addDays(diff)
+}
+
+// upcoming returns month/day in this year, or next year if that is already
+// past. Days beyond the month's length are clamped.
+func upcoming(today civilDate, m time.Month, day int) *civilDate {
+	d := civilDate{today.year, m, min(day, daysIn(today.year, m))}
+	if d.before(today) {
+		d = civilDate{today.year + 1, m, min(day, daysIn(today.year+1, m))}
+	}
+	return &d
+}
+
+func daysIn(year int, m time.Month) int {
+	return time.Date(year, m+1, 0, 0, 0, 0, 0, time.UTC).Day()
+}
+
+// parseOrdinal accepts "5", "5th", "1st", "22nd", "3rd" in 1..31.
+func parseOrdinal(w string) (int, bool) {
+	for _, suffix := range []string{"st", "nd", "rd", "th"} {
+		if strings.HasSuffix(w, suffix) {
+			w = strings.TrimSuffix(w, suffix)
+			break
+		}
+	}
+	n, err := strconv.Atoi(w)
+	if err != nil || n < 1 || n > 31 {
+		return 0, false
+	}
+	return n, true
+}
+
+// parseClock reads a time of day from w (and, for "9 am", next). Bare
+// numbers like "9" only count when afterAt is set ("at 9"). It returns the
+// number of words used.
+func parseClock(w, next string, afterAt bool) (clockTime, int) {
+	switch w {
+	case "noon":
+		return clockTime{hour: 12}, 1
+	case "midnight":
+		return clockTime{}, 1
+	}
+
+	used := 1
+	suffix := ""
+	switch {
+	case strings.HasSuffix(w, "am"), strings.HasSuffix(w, "pm"):
+		suffix = w[len(w)-2:]
+		w = w[:len(w)-2]
+	case next == "am" || next == "pm":
+		suffix = next
+		used = 2
+	}
+
+	hourStr, minStr, hasColon := strings.Cut(w, ":")
+	if suffix == "" && !hasColon && !afterAt {
+		return clockTime{}, 0
+	}
+
+	hour, err := strconv.Atoi(hourStr)
+	if err != nil {
+		return clockTime{}, 0
+	}
+	minute := 0
+	if hasColon {
+		if len(minStr) != 2 {
+			return clockTime{}, 0
+		}
+		minute, err = strconv.Atoi(minStr)
+		if err != nil || minute > 59 {
+			return clockTime{}, 0
+		}
+	}
+
+	switch suffix {
+	case "am", "pm":
+		if hour < 1 || hour > 12 {
+			return clockTime{}, 0
+		}
+		hour %= 12
+		if suffix == "pm" {
+			hour += 12
+		}
+	default:
+		if hour > 23 {
+			return clockTime{}, 0
+		}
+	}
+	return clockTime{hour: hour, minute: minute}, used
+}
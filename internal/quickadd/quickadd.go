@@ -0,0 +1,249 @@
+// Package quickadd turns a one-line task description such as
+//
+//	Pay rent every 1st at 9am #home !high tomorrow
+//
+// into task fields. Parsing is rule-based and depends only on the input,
+// the reference time and the user's location, so the same input always
+// yields the same result.
+package quickadd
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"gotasker/internal/models"
+)
+
+// Parse extracts the title, due date/time, recurrence, tags and priority from
+// input. Relative expressions ("tomorrow", "in 2 hours", "friday") are
+// resolved against now in loc. Words that are not part of a recognised
+// expression make up the title, in their original order and spelling.
+func Parse(input string, now time.Time, loc *time.Location) models.ParsedTask {
+	if loc == nil {
+		loc = time.UTC
+	}
+	p := &parser{
+		words: strings.Fields(input),
+		now:   now.In(loc),
+		loc:   loc,
+	}
+	return p.run()
+}
+
+type parser struct {
+	words []string
+	now   time.Time
+	loc   *time.Location
+
+	title    []string
+	tags     []string
+	priority string
+
+	date    *civilDate
+	clock   *clockTime
+	instant *time.Time // absolute due time from "in N hours"
+	rule    *recurrence
+}
+
+type civilDate struct {
+	year  int
+	month time.Month
+	day   int
+}
+
+type clockTime struct {
+	hour, minute int
+}
+
+func (p *parser) run() models.ParsedTask {
+	for i := 0; i < len(p.words); {
+		if n := p.match(i); n > 0 {
+			i += n
+			continue
+		}
+		p.title = append(p.title, p.words[i])
+		i++
+	}
+
+	out := models.ParsedTask{
+		Title:    strings.Join(p.title, " "),
+		Tags:     models.NormalizeTags(p.tags),
+		Priority: p.priority,
+	}
+	if p.rule != nil {
+		out.Recurrence = p.rule.String()
+	}
+	if due := p.resolveDue(); due != nil {
+		utc := due.UTC()
+		out.DueAt = &utc
+		out.HasTime = p.clock != nil || p.instant != nil
+	}
+	return out
+}
+
+// match tries every rule at position i and returns how many words the first
+// matching rule consumed, or 0.
+func (p *parser) match(i int) int {
+	rules := []func(int) int{
+		p.matchTag,
+		p.matchPriority,
+		p.matchRecurrence,
+		p.matchDate,
+		p.matchTime,
+	}
+	for _, rule := range rules {
+		if n := rule(i); n > 0 {
+			return n
+		}
+	}
+	return 0
+}
+
+// word returns the normalised word at i, or "" past the end.
+func (p *parser) word(i int) string {
+	if i < 0 || i >= len(p.words) {
+		return ""
+	}
+	return strings.ToLower(strings.TrimRight(p.words[i], ",.;"))
+}
+
+func (p *parser) matchTag(i int) int {
+	w := p.words[i]
+	if len(w) > 1 && w[0] == '#' {
+		p.tags = append(p.tags, w[1:])
+		return 1
+	}
+	return 0
+}
+
+var priorityWords = map[string]string{
+	"!high":   models.PriorityHigh,
+	"!h":      models.PriorityHigh,
+	"!1":      models.PriorityHigh,
+	"!!!":     models.PriorityHigh,
+	"!medium": models.PriorityMedium,
+	"!med":    models.PriorityMedium,
+	"!m":      models.PriorityMedium,
+	"!2":      models.PriorityMedium,
+	"!!":      models.PriorityMedium,
+	"!low":    models.PriorityLow,
+	"!l":      models.PriorityLow,
+	"!3":      models.PriorityLow,
+}
+
+func (p *parser) matchPriority(i int) int {
+	if pr, ok := priorityWords[p.word(i)]; ok {
+		p.priority = pr
+		return 1
+	}
+	return 0
+}
+
+func (p *parser) matchDate(i int) int {
+	// Optional leading preposition, only consumed with a date after it.
+	start := i
+	switch p.word(i) {
+	case "on", "by", "due":
+		i++
+	}
+	n := p.dateExpr(i)
+	if n == 0 {
+		return 0
+	}
+	return i - start + n
+}
+
+func (p *parser) matchTime(i int) int {
+	start := i
+	if p.word(i) == "at" {
+		i++
+	}
+	c, n := parseClock(p.word(i), p.word(i+1), start != i)
+	if n == 0 {
+		return 0
+	}
+	if _, err := strconv.Atoi(p.word(i)); err == nil && n == 1 && !p.endsClause(i) {
+		return 0
+	}
+	p.clock = &c
+	return i - start + n
+}
+
+// endsClause reports whether the bare number at i ("at 3") ends what is
+// being said about time: it is the last word, ends with punctuation, or is
+// followed by a tag, a priority or a date or recurrence expression.
+// Otherwise the number is read as part of the title, as in "Meet at 3
+// people".
+func (p *parser) endsClause(i int) bool {
+	if i+1 >= len(p.words) || strings.ContainsAny(p.words[i][len(p.words[i])-1:], ",.;") {
+		return true
+	}
+	if next := p.words[i+1]; len(next) > 1 && next[0] == '#' {
+		return true
+	}
+	if _, ok := priorityWords[p.word(i+1)]; ok {
+		return true
+	}
+	// Try the next word on a copy so a match leaves p untouched.
+	trial := *p
+	return trial.matchDate(i+1) > 0 || trial.matchRecurrence(i+1) > 0
+}
+
+// resolveDue combines the parsed date, time and recurrence into one instant.
+func (p *parser) resolveDue() *time.Time {
+	if p.instant != nil {
+		return p.instant
+	}
+
+	today := civilDate{p.now.Year(), p.now.Month(), p.now.Day()}
+
+	var d civilDate
+	switch {
+	case p.date != nil:
+		d = *p.date
+	case p.rule != nil:
+		d = p.rule.firstOnOrAfter(today)
+		if p.clock != nil && d == today && !p.at(d, *p.clock).After(p.now) {
+			d = p.rule.firstOnOrAfter(today.addDays(1))
+		}
+	case p.clock != nil:
+		// A bare time means the next time the clock shows it.
+		d = today
+		if !p.at(d, *p.clock).After(p.now) {
+			d = today.addDays(1)
+		}
+	default:
+		return nil
+	}
+
+	c := clockTime{}
+	if p.clock != nil {
+		c = *p.clock
+	}
+	t := p.at(d, c)
+	return &t
+}
+
+func (p *parser) at(d civilDate, c clockTime) time.Time {
+	return time.Date(d.year, d.month, d.day, c.hour, c.minute, 0, 0, p.loc)
+}
+
+func (d civilDate) addDays(n int) civilDate {
+	t := time.Date(d.year, d.month, d.day+n, 0, 0, 0, 0, time.UTC)
+	return civilDate{t.Year(), t.Month(), t.Day()}
+}
+
+func (d civilDate) weekday() time.Weekday {
+	return time.Date(d.year, d.month, d.day, 0, 0, 0, 0, time.UTC).Weekday()
+}
+
+func (d civilDate) before(o civilDate) bool {
+	if d.year != o.year {
+		return d.year < o.year
+	}
+	if d.month != o.month {
+		return d.month < o.month
+	}
+	return d.day < o.day
+}
@@ -0,0 +1,125 @@
+package quickadd
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"gotasker/internal/models"
+)
+
+func TestParse(t *testing.T) {
+	// Wednesday 4 March 2026, 10:00 in a zone five hours behind UTC.
+	loc := time.FixedZone("UTC-5", -5*60*60)
+	now := time.Date(2026, time.March, 4, 10, 0, 0, 0, loc)
+
+	at := func(month time.Month, day, hour, minute int) *time.Time {
+		t := time.Date(2026, month, day, hour, minute, 0, 0, loc).UTC()
+		return &t
+	}
+
+	tests := []struct {
+		input string
+		want  models.ParsedTask
+	}{
+		// The example from the request: an explicit date wins over the
+		// recurrence for the first due date.
+		{"Pay rent every 1st at 9am #home !high tomorrow", models.ParsedTask{
+			Title: "Pay rent", DueAt: at(time.March, 5, 9, 0), HasTime: true,
+			Recurrence: "FREQ=MONTHLY;BYMONTHDAY=1", Tags: []string{"home"}, Priority: models.PriorityHigh,
+		}},
+
+		// Relative dates.
+		{"Call mom tomorrow", models.ParsedTask{Title: "Call mom", DueAt: at(time.March, 5, 0, 0), Tags: []string{}}},
+		{"Call mom tmrw", models.ParsedTask{Title: "Call mom", DueAt: at(time.March, 5, 0, 0), Tags: []string{}}},
+		{"File taxes today", models.ParsedTask{Title: "File taxes", DueAt: at(time.March, 4, 0, 0), Tags: []string{}}},
+		{"Take out bins tonight", models.ParsedTask{Title: "Take out bins", DueAt: at(time.March, 4, 20, 0), HasTime: true, Tags: []string{}}},
+		{"Party next week", models.ParsedTask{Title: "Party", DueAt: at(time.March, 11, 0, 0), Tags: []string{}}},
+		{"Renew lease next month", models.ParsedTask{Title: "Renew lease", DueAt: at(time.April, 4, 0, 0), Tags: []string{}}},
+		{"Follow up in 3 days", models.ParsedTask{Title: "Follow up", DueAt: at(time.March, 7, 0, 0), Tags: []string{}}},
+		{"Report in 2 hours", models.ParsedTask{Title: "Report", DueAt: at(time.March, 4, 12, 0), HasTime: true, Tags: []string{}}},
+		{"Check oven in 45 minutes", models.ParsedTask{Title: "Check oven", DueAt: at(time.March, 4, 10, 45), HasTime: true, Tags: []string{}}},
+
+		// Absolute dates; past month/day combinations roll to next year.
+		{"Read 2026-03-10", models.ParsedTask{Title: "Read", DueAt: at(time.March, 10, 0, 0), Tags: []string{}}},
+		{"Taxes 15th of april", models.ParsedTask{Title: "Taxes", DueAt: at(time.April, 15, 0, 0), Tags: []string{}}},
+		{"Taxes by apr 15", models.ParsedTask{Title: "Taxes", DueAt: at(time.April, 15, 0, 0), Tags: []string{}}},
+		{"Book flights mar 1", models.ParsedTask{Title: "Book flights", DueAt: func() *time.Time {
+			t := time.Date(2027, time.March, 1, 0, 0, 0, 0, loc).UTC()
+			return &t
+		}(), Tags: []string{}}},
+
+		// Weekdays are the next such day, never today.
+		{"Dentist friday at 3:30pm", models.ParsedTask{Title: "Dentist", DueAt: at(time.March, 6, 15, 30), HasTime: true, Tags: []string{}}},
+		{"Review on wed", models.ParsedTask{Title: "Review", DueAt: at(time.March, 11, 0, 0), Tags: []string{}}},
+		{"Review next monday", models.ParsedTask{Title: "Review", DueAt: at(time.March, 9, 0, 0), Tags: []string{}}},
+
+		// Times alone mean the next time the clock shows them.
+		{"Lunch at noon", models.ParsedTask{Title: "Lunch", DueAt: at(time.March, 4, 12, 0), HasTime: true, Tags: []string{}}},
+		{"Call at 5 pm", models.ParsedTask{Title: "Call", DueAt: at(time.March, 4, 17, 0), HasTime: true, Tags: []string{}}},
+		{"Backup 9am", models.ParsedTask{Title: "Backup", DueAt: at(time.March, 5, 9, 0), HasTime: true, Tags: []string{}}},
+		{"Meet at 3", models.ParsedTask{Title: "Meet", DueAt: at(time.March, 5, 3, 0), HasTime: true, Tags: []string{}}},
+		{"Meet at 14 #work", models.ParsedTask{Title: "Meet", DueAt: at(time.March, 4, 14, 0), HasTime: true, Tags: []string{"work"}}},
+		{"Meet at 3, bring slides", models.ParsedTask{Title: "Meet bring slides", DueAt: at(time.March, 5, 3, 0), HasTime: true, Tags: []string{}}},
+		{"Meet at 11 tomorrow", models.ParsedTask{Title: "Meet", DueAt: at(time.March, 5, 11, 0), HasTime: true, Tags: []string{}}},
+
+		// Recurrence.
+		{"Standup every weekday at 9:15", models.ParsedTask{
+			Title: "Standup", DueAt: at(time.March, 5, 9, 15), HasTime: true,
+			Recurrence: "FREQ=WEEKLY;BYDAY=MO,TU,WE,TH,FR", Tags: []string{},
+		}},
+		{"Gym every mon and thu", models.ParsedTask{
+			Title: "Gym", DueAt: at(time.March, 5, 0, 0), Recurrence: "FREQ=WEEKLY;BYDAY=MO,TH", Tags: []string{},
+		}},
+		{"Run daily at 7am", models.ParsedTask{
+			Title: "Run", DueAt: at(time.March, 5, 7, 0), HasTime: true, Recurrence: "FREQ=DAILY", Tags: []string{},
+		}},
+		{"Water plants every 2 weeks !low", models.ParsedTask{
+			Title: "Water plants", DueAt: at(time.March, 4, 0, 0), Recurrence: "FREQ=WEEKLY;INTERVAL=2",
+			Tags: []string{}, Priority: models.PriorityLow,
+		}},
+		{"Invoice every 31st of the month", models.ParsedTask{
+			Title: "Invoice", DueAt: at(time.March, 31, 0, 0), Recurrence: "FREQ=MONTHLY;BYMONTHDAY=31", Tags: []string{},
+		}},
+
+		// Tags and priorities.
+		{"Buy milk #shop #errands !!", models.ParsedTask{Title: "Buy milk", Tags: []string{"shop", "errands"}, Priority: models.PriorityMedium}},
+		{"Fix prod !1 #ops #ops", models.ParsedTask{Title: "Fix prod", Tags: []string{"ops"}, Priority: models.PriorityHigh}},
+
+		// Ambiguous input stays in the title.
+		{"Meet at 3 people", models.ParsedTask{Title: "Meet at 3 people", Tags: []string{}}},
+		{"Pay bills on the way", models.ParsedTask{Title: "Pay bills on the way", Tags: []string{}}},
+		{"Read chapter 12", models.ParsedTask{Title: "Read chapter 12", Tags: []string{}}},
+		{"Plan every detail", models.ParsedTask{Title: "Plan every detail", Tags: []string{}}},
+		{"Say hi in person", models.ParsedTask{Title: "Say hi in person", Tags: []string{}}},
+		{"Email C# team", models.ParsedTask{Title: "Email C# team", Tags: []string{}}},
+	}
+
+	for _, tt := range tests {
+		got := Parse(tt.input, now, loc)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Parse(%q)\ngot:  %s\nwant: %s", tt.input, show(got), show(tt.want))
+		}
+	}
+}
+
+func TestParseIsDeterministic(t *testing.T) {
+	now := time.Date(2026, time.March, 4, 10, 0, 0, 0, time.UTC)
+	const input = "Pay rent every 1st at 9am #home !high"
+	first := Parse(input, now, nil)
+	for i := 0; i < 10; i++ {
+		if got := Parse(input, now, nil); !reflect.DeepEqual(got, first) {
+			t.Fatalf("run %d: got %s, want %s", i, show(got), show(first))
+		}
+	}
+}
+
+func show(p models.ParsedTask) string {
+	due := "<nil>"
+	if p.DueAt != nil {
+		due = p.DueAt.Format(time.RFC3339)
+	}
+	return fmt.Sprintf("{title=%q due=%s has_time=%t rrule=%q tags=%q priority=%q}",
+		p.Title, due, p.HasTime, p.Recurrence, p.Tags, p.Priority)
+}
@@ -0,0 +1,152 @@
+package quickadd
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// recurrence is the subset of RFC 5545 RRULE that quick-add can express.
+type recurrence struct {
+	freq       string // DAILY, WEEKLY, MONTHLY, YEARLY
+	interval   int
+	byDay      []time.Weekday
+	byMonthDay int
+}
+
+var rruleDays = [...]string{"SU", "MO", "TU", "WE", "TH", "FR", "SA"}
+
+// String renders the rule as an RRULE value, e.g. "FREQ=MONTHLY;BYMONTHDAY=1".
+func (r recurrence) String() string {
+	parts := []string{"FREQ=" + r.freq}
+	if r.interval > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(r.interval))
+	}
+	if len(r.byDay) > 0 {
+		days := make([]string, len(r.byDay))
+		for i, d := range r.byDay {
+			days[i] = rruleDays[d]
+		}
+		parts = append(parts, "BYDAY="+strings.Join(days, ","))
+	}
+	if r.byMonthDay > 0 {
+		parts = append(parts, "BYMONTHDAY="+strconv.Itoa(r.byMonthDay))
+	}
+	return strings.Join(parts, ";")
+}
+
+// firstOnOrAfter returns the first date on or after from that the rule
+// fires on.
+func (r recurrence) firstOnOrAfter(from civilDate) civilDate {
+	switch {
+	case len(r.byDay) > 0:
+		for i := 0; i < 7; i++ {
+			d := from.addDays(i)
+			for _, wd := range r.byDay {
+				if d.weekday() == wd {
+					return d
+				}
+			}
+		}
+	case r.byMonthDay > 0:
+		// Skip months too short for the day, as RRULE does.
+		y, m := from.year, from.month
+		for i := 0; i < 13; i++ {
+			if r.byMonthDay <= daysIn(y, m) {
+				d := civilDate{y, m, r.byMonthDay}
+				if !d.before(from) {
+					return d
+				}
+			}
+			m++
+			if m > time.December {
+				m, y = time.January, y+1
+			}
+		}
+	}
+	return from
+}
+
+var everyUnits = map[string]string{
+	"day":   "DAILY",
+	"week":  "WEEKLY",
+	"month": "MONTHLY",
+	"year":  "YEARLY",
+}
+
+var adverbs = map[string]string{
+	"daily":    "DAILY",
+	"weekly":   "WEEKLY",
+	"monthly":  "MONTHLY",
+	"yearly":   "YEARLY",
+	"annually": "YEARLY",
+}
+
+func (p *parser) matchRecurrence(i int) int {
+	w := p.word(i)
+	if freq, ok := adverbs[w]; ok {
+		p.rule = &recurrence{freq: freq}
+		return 1
+	}
+	if w != "every" {
+		return 0
+	}
+
+	next := p.word(i + 1)
+
+	// every day / week / month / year
+	if freq, ok := everyUnits[next]; ok {
+		p.rule = &recurrence{freq: freq}
+		return 2
+	}
+
+	// every weekday
+	if next == "weekday" {
+		p.rule = &recurrence{freq: "WEEKLY", byDay: []time.Weekday{
+			time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday,
+		}}
+		return 2
+	}
+
+	// every monday, every mon and thu
+	if _, ok := weekdays[next]; ok {
+		var days []time.Weekday
+		n := 1
+		for {
+			wd, ok := weekdays[p.word(i+n)]
+			if !ok {
+				break
+			}
+			days = append(days, wd)
+			n++
+			if p.word(i+n) == "and" {
+				if _, ok := weekdays[p.word(i+n+1)]; ok {
+					n++
+				}
+			}
+		}
+		p.rule = &recurrence{freq: "WEEKLY", byDay: days}
+		return n
+	}
+
+	// every 3 days, every 2 weeks
+	if n, err := strconv.Atoi(next); err == nil && n > 0 {
+		if freq, ok := everyUnits[strings.TrimSuffix(p.word(i+2), "s")]; ok {
+			p.rule = &recurrence{freq: freq, interval: n}
+			return 3
+		}
+	}
+
+	// every 1st, every 15th (of the month)
+	if strings.ContainsAny(next, "snrt") {
+		if day, ok := parseOrdinal(next); ok {
+			n := 2
+			if p.word(i+2) == "of" && p.word(i+3) == "the" && p.word(i+4) == "month" {
+				n = 5
+			}
+			p.rule = &recurrence{freq: "MONTHLY", byMonthDay: day}
+			return n
+		}
+	}
+	return 0
+}
@@ -13,19 +13,30 @@ import (
 
 const taskksETL = 60 * time.Second
 
-// Key - Value pair
-func TasksCacheKey(userID int64) string {
-	return fmt.Sprintf("tasks:user:%d", userID)
+// Key - Value pair. A user's task list differs per workspace, so the key
+// carries both.
+func TasksCacheKey(workspaceID, userID int64) string {
+	return fmt.Sprintf("tasks:workspace:%d:user:%d", workspaceID, userID)
+}
+
+// userTasksKeys and workspaceTasksKeys are sets of the list keys written
+// for a user or a workspace, so either can be invalidated without SCAN.
+func userTasksKeys(userID int64) string {
+	return fmt.Sprintf("tasks:user:%d:keys", userID)
+}
+
+func workspaceTasksKeys(workspaceID int64) string {
+	return fmt.Sprintf("tasks:workspace:%d:keys", workspaceID)
 }
 
 //Checking Redis first
 
-func GetTasks(ctx context.Context, rdb *redis.Client, userID int64) ([]models.Task, bool, error) {
+func GetTasks(ctx context.Context, rdb *redis.Client, workspaceID, userID int64) ([]models.Task, bool, error) {
 	if rdb == nil {
 		return nil, false, nil
 	}
 
-	val, err := rdb.Get(ctx, TasksCacheKey(userID)).Result()
+	val, err := rdb.Get(ctx, TasksCacheKey(workspaceID, userID)).Result()
 	if err == redis.Nil {
 		return nil, false, nil
 	}
@@ -44,7 +55,7 @@ func GetTasks(ctx context.Context, rdb *redis.Client, userID int64) ([]models.Ta
 
 // Setting up new Redis
 
-func SetTasks(ctx context.Context, rdb *redis.Client, userID int64, tasks []models.Task) error {
+func SetTasks(ctx context.Context, rdb *redis.Client, workspaceID, userID int64, tasks []models.Task) error {
 	if rdb == nil {
 		return nil
 	}
@@ -54,20 +65,47 @@ func SetTasks(ctx context.Context, rdb *redis.Client, userID int64, tasks []mode
 		return err
 	}
 
-	return rdb.Set(ctx, TasksCacheKey(userID), b, taskksETL).Err()
+	key := TasksCacheKey(workspaceID, userID)
+	pipe := rdb.TxPipeline()
+	pipe.Set(ctx, key, b, taskksETL)
+	pipe.SAdd(ctx, userTasksKeys(userID), key)
+	pipe.Expire(ctx, userTasksKeys(userID), taskksETL)
+	pipe.SAdd(ctx, workspaceTasksKeys(workspaceID), key)
+	pipe.Expire(ctx, workspaceTasksKeys(workspaceID), taskksETL)
+	_, err = pipe.Exec(ctx)
+	return err
 }
 
 // Delete Redis
 
+// DeletTaks drops every cached task list of the user, in all workspaces,
+// along with their cached stats.
 func DeletTaks(ctx context.Context, rdb *redis.Client, userID int64) error {
 	if rdb == nil {
 		return nil
 	}
 
-	err := rdb.Del(ctx, TasksCacheKey(userID)).Err()
+	keys, err := rdb.SMembers(ctx, userTasksKeys(userID)).Result()
 	if err != nil {
+		return err
+	}
+	keys = append(keys, userTasksKeys(userID), StatsCacheKey(userID))
+
+	return rdb.Del(ctx, keys...).Err()
+}
+
+// DeleteWorkspaceTasks drops the cached task lists of every member of the
+// workspace.
+func DeleteWorkspaceTasks(ctx context.Context, rdb *redis.Client, workspaceID int64) error {
+	if rdb == nil {
 		return nil
 	}
 
-	return err
+	keys, err := rdb.SMembers(ctx, workspaceTasksKeys(workspaceID)).Result()
+	if err != nil {
+		return err
+	}
+	keys = append(keys, workspaceTasksKeys(workspaceID))
+
+	return rdb.Del(ctx, keys...).Err()
 }
@@ -0,0 +1,43 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ErrNoClient is returned by the denylist when Redis is not configured.
+// Unlike the caches, the denylist cannot quietly do nothing.
+var ErrNoClient = errors.New("redis not configured")
+
+// DeniedTokenKey marks a revoked access token by its jti.
+func DeniedTokenKey(jti string) string {
+	return fmt.Sprintf("auth:denied:%s", jti)
+}
+
+// DenyToken revokes the token with the given jti for ttl, which should be
+// the token's remaining lifetime; after that it is expired anyway.
+func DenyToken(ctx context.Context, rdb *redis.Client, jti string, ttl time.Duration) error {
+	if rdb == nil {
+		return ErrNoClient
+	}
+	if ttl <= 0 {
+		return nil
+	}
+	return rdb.Set(ctx, DeniedTokenKey(jti), 1, ttl).Err()
+}
+
+// IsTokenDenied reports whether the token with the given jti was revoked.
+func IsTokenDenied(ctx context.Context, rdb *redis.Client, jti string) (bool, error) {
+	if rdb == nil {
+		return false, ErrNoClient
+	}
+	n, err := rdb.Exists(ctx, DeniedTokenKey(jti)).Result()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
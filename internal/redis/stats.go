@@ -0,0 +1,58 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"gotasker/internal/models"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const statsTTL = 5 * time.Minute
+
+// StatsCacheKey is a hash of the user's cached stats, one field per query.
+// DeletTaks drops the whole hash, so any task change invalidates every range.
+func StatsCacheKey(userID int64) string {
+	return fmt.Sprintf("stats:user:%d", userID)
+}
+
+func GetStats(ctx context.Context, rdb *redis.Client, userID int64, field string) (models.Stats, bool, error) {
+	if rdb == nil {
+		return models.Stats{}, false, nil
+	}
+
+	val, err := rdb.HGet(ctx, StatsCacheKey(userID), field).Result()
+	if err == redis.Nil {
+		return models.Stats{}, false, nil
+	}
+	if err != nil {
+		return models.Stats{}, false, err
+	}
+
+	var stats models.Stats
+	if err := json.Unmarshal([]byte(val), &stats); err != nil {
+		return models.Stats{}, false, err
+	}
+	return stats, true, nil
+}
+
+func SetStats(ctx context.Context, rdb *redis.Client, userID int64, field string, stats models.Stats) error {
+	if rdb == nil {
+		return nil
+	}
+
+	b, err := json.Marshal(stats)
+	if err != nil {
+		return err
+	}
+
+	key := StatsCacheKey(userID)
+	pipe := rdb.TxPipeline()
+	pipe.HSet(ctx, key, field, b)
+	pipe.Expire(ctx, key, statsTTL)
+	_, err = pipe.Exec(ctx)
+	return err
+}
@@ -0,0 +1,117 @@
+// Package templates holds the placeholder and due-offset rules used when a
+// task template is instantiated.
+package templates
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var placeholder = regexp.MustCompile(`\{\{\s*([A-Za-z0-9_]+)\s*\}\}`)
+
+// Render replaces {{key}} placeholders in s with vars[key]. Keys that have no
+// value are returned, sorted, in missing and left in place.
+func Render(s string, vars map[string]string) (out string, missing []string) {
+	seen := map[string]bool{}
+	out = placeholder.ReplaceAllStringFunc(s, func(m string) string {
+		key := placeholder.FindStringSubmatch(m)[1]
+		if v, ok := vars[key]; ok {
+			return v
+		}
+		if !seen[key] {
+			seen[key] = true
+			missing = append(missing, key)
+		}
+		return m
+	})
+	sort.Strings(missing)
+	return out, missing
+}
+
+// Placeholders lists the distinct placeholder keys used in s.
+func Placeholders(s string) []string {
+	var keys []string
+	seen := map[string]bool{}
+	for _, m := range placeholder.FindAllStringSubmatch(s, -1) {
+		if !seen[m[1]] {
+			seen[m[1]] = true
+			keys = append(keys, m[1])
+		}
+	}
+	return keys
+}
+
+var offsetPart = regexp.MustCompile(`^(\d+)([dhm])`)
+
+// ParseOffset reads a relative due offset such as "3d", "1d12h", "90m" or
+// "-2h". An empty string is a zero offset.
+func ParseOffset(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, nil
+	}
+
+	sign := time.Duration(1)
+	rest := s
+	switch rest[0] {
+	case '-':
+		sign, rest = -1, rest[1:]
+	case '+':
+		rest = rest[1:]
+	}
+	if rest == "" {
+		return 0, fmt.Errorf("invalid offset %q", s)
+	}
+
+	var total time.Duration
+	for rest != "" {
+		m := offsetPart.FindStringSubmatch(rest)
+		if m == nil {
+			return 0, fmt.Errorf("invalid offset %q", s)
+		}
+		n, err := strconv.Atoi(m[1])
+		if err != nil {
+			return 0, fmt.Errorf("invalid offset %q", s)
+		}
+		switch m[2] {
+		case "d":
+			total += time.Duration(n) * 24 * time.Hour
+		case "h":
+			total += time.Duration(n) * time.Hour
+		case "m":
+			total += time.Duration(n) * time.Minute
+		}
+		rest = rest[len(m[0]):]
+	}
+	return sign * total, nil
+}
+
+// FormatOffset is the inverse of ParseOffset, rounded to whole minutes.
+func FormatOffset(d time.Duration) string {
+	d = d.Round(time.Minute)
+	if d == 0 {
+		return ""
+	}
+
+	var b strings.Builder
+	if d < 0 {
+		b.WriteByte('-')
+		d = -d
+	}
+	if days := d / (24 * time.Hour); days > 0 {
+		fmt.Fprintf(&b, "%dd", days)
+		d -= days * 24 * time.Hour
+	}
+	if hours := d / time.Hour; hours > 0 {
+		fmt.Fprintf(&b, "%dh", hours)
+		d -= hours * time.Hour
+	}
+	if mins := d / time.Minute; mins > 0 {
+		fmt.Fprintf(&b, "%dm", mins)
+	}
+	return b.String()
+}
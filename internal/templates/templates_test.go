@@ -0,0 +1,63 @@
+package templates
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRender(t *testing.T) {
+	out, missing := Render("{{ client }}: {{x}} review on {{date}} for {{client}}", map[string]string{"client": "Acme"})
+	if want := "Acme: {{x}} review on {{date}} for Acme"; out != want {
+		t.Errorf("out %q, want %q", out, want)
+	}
+	if want := []string{"date", "x"}; !reflect.DeepEqual(missing, want) {
+		t.Errorf("missing %v, want %v", missing, want)
+	}
+
+	if out, missing := Render("no {placeholders} here", nil); out != "no {placeholders} here" || missing != nil {
+		t.Errorf("got %q, %v", out, missing)
+	}
+}
+
+func TestPlaceholders(t *testing.T) {
+	got := Placeholders("{{b}} {{ a }} {{b}} {{not valid}}")
+	if want := []string{"b", "a"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestParseOffset(t *testing.T) {
+	tests := map[string]time.Duration{
+		"":       0,
+		" 3d ":   72 * time.Hour,
+		"1d12h":  36 * time.Hour,
+		"90m":    90 * time.Minute,
+		"-2h":    -2 * time.Hour,
+		"+1h30m": 90 * time.Minute,
+	}
+	for in, want := range tests {
+		got, err := ParseOffset(in)
+		if err != nil || got != want {
+			t.Errorf("%q: %v, %v; want %v", in, got, err, want)
+		}
+	}
+	for _, in := range []string{"-", "3", "d", "3w", "1h-2h", "1.5h"} {
+		if _, err := ParseOffset(in); err == nil {
+			t.Errorf("%q: want error", in)
+		}
+	}
+}
+
+func TestFormatOffsetRoundTrip(t *testing.T) {
+	for _, d := range []time.Duration{0, time.Minute, 36 * time.Hour, -(26*time.Hour + 5*time.Minute)} {
+		s := FormatOffset(d)
+		got, err := ParseOffset(s)
+		if err != nil || got != d {
+			t.Errorf("%v: formatted %q, parsed back %v, %v", d, s, got, err)
+		}
+	}
+	if got := FormatOffset(89*time.Second + 36*time.Hour); got != "1d12h1m" {
+		t.Errorf("rounding: got %q, want 1d12h1m", got)
+	}
+}
@@ -0,0 +1,126 @@
+// Package workflow holds the rules for per-user task statuses: the default
+// workflow, validation of custom ones and allowed transitions.
+package workflow
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"gotasker/internal/models"
+)
+
+var keyPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,39}$`)
+
+// Default is the workflow used until a user defines their own. Every
+// transition is allowed.
+func Default() models.Workflow {
+	return models.Workflow{Statuses: []models.WorkflowStatus{
+		{Key: "todo", Name: "To do", Category: models.CategoryTodo},
+		{Key: "in_progress", Name: "In progress", Category: models.CategoryInProgress},
+		{Key: "done", Name: "Done", Category: models.CategoryDone},
+	}}
+}
+
+// Validate normalises wf in place and checks that it is usable.
+func Validate(wf *models.Workflow) error {
+	if len(wf.Statuses) == 0 {
+		return errors.New("a workflow needs at least one status")
+	}
+
+	keys := make(map[string]bool, len(wf.Statuses))
+	hasTodo, hasDone := false, false
+	for i := range wf.Statuses {
+		s := &wf.Statuses[i]
+		s.Key = strings.TrimSpace(strings.ToLower(s.Key))
+		s.Name = strings.TrimSpace(s.Name)
+		if !keyPattern.MatchString(s.Key) {
+			return fmt.Errorf("invalid status key %q", s.Key)
+		}
+		if keys[s.Key] {
+			return fmt.Errorf("duplicate status key %q", s.Key)
+		}
+		keys[s.Key] = true
+		if s.Name == "" {
+			s.Name = s.Key
+		}
+		switch s.Category {
+		case models.CategoryTodo:
+			hasTodo = true
+		case models.CategoryDone:
+			hasDone = true
+		case models.CategoryInProgress:
+		default:
+			return fmt.Errorf("status %q: category must be todo, in_progress or done", s.Key)
+		}
+		if s.WIPLimit != nil && *s.WIPLimit <= 0 {
+			return fmt.Errorf("status %q: wip_limit must be positive", s.Key)
+		}
+	}
+	if !hasTodo || !hasDone {
+		return errors.New("a workflow needs at least one todo and one done status")
+	}
+
+	for _, s := range wf.Statuses {
+		for _, to := range s.Transitions {
+			if !keys[to] {
+				return fmt.Errorf("status %q: unknown transition target %q", s.Key, to)
+			}
+		}
+	}
+	return nil
+}
+
+// Find returns the status with the given key.
+func Find(wf models.Workflow, key string) (models.WorkflowStatus, bool) {
+	for _, s := range wf.Statuses {
+		if s.Key == key {
+			return s, true
+		}
+	}
+	return models.WorkflowStatus{}, false
+}
+
+// IsDone reports whether key belongs to the done category.
+func IsDone(wf models.Workflow, key string) bool {
+	s, ok := Find(wf, key)
+	return ok && s.Category == models.CategoryDone
+}
+
+// CanTransition reports whether a task may move from one status to another.
+// Staying in the same status is always allowed.
+func CanTransition(wf models.Workflow, from, to string) bool {
+	if from == to {
+		return true
+	}
+	if _, ok := Find(wf, to); !ok {
+		return false
+	}
+	s, ok := Find(wf, from)
+	if !ok || s.Transitions == nil {
+		// Tasks in an unknown status may be moved anywhere to recover.
+		return true
+	}
+	for _, t := range s.Transitions {
+		if t == to {
+			return true
+		}
+	}
+	return false
+}
+
+// StatusForDone picks the status a legacy done flag maps to: the first done
+// status for true, the first todo status for false.
+func StatusForDone(wf models.Workflow, done bool) string {
+	want := models.CategoryTodo
+	if done {
+		want = models.CategoryDone
+	}
+	for _, s := range wf.Statuses {
+		if s.Category == want {
+			return s.Key
+		}
+	}
+	return ""
+}
@@ -0,0 +1,123 @@
+package workflow
+
+import (
+	"strings"
+	"testing"
+
+	"gotasker/internal/models"
+)
+
+// restricted is todo -> doing -> done, with done able to reopen.
+func restricted() models.Workflow {
+	return models.Workflow{Statuses: []models.WorkflowStatus{
+		{Key: "todo", Category: models.CategoryTodo, Transitions: []string{"doing"}},
+		{Key: "doing", Category: models.CategoryInProgress, Transitions: []string{"done"}},
+		{Key: "done", Category: models.CategoryDone, Transitions: []string{"todo"}},
+	}}
+}
+
+func TestValidateNormalises(t *testing.T) {
+	wf := models.Workflow{Statuses: []models.WorkflowStatus{
+		{Key: " ToDo ", Name: "  ", Category: models.CategoryTodo},
+		{Key: "done", Name: " Done ", Category: models.CategoryDone},
+	}}
+	if err := Validate(&wf); err != nil {
+		t.Fatal(err)
+	}
+	if s := wf.Statuses[0]; s.Key != "todo" || s.Name != "todo" {
+		t.Errorf("first status %+v, want key and name todo", s)
+	}
+	if s := wf.Statuses[1]; s.Name != "Done" {
+		t.Errorf("second status name %q, want Done", s.Name)
+	}
+
+	def := Default()
+	if err := Validate(&def); err != nil {
+		t.Errorf("default workflow: %v", err)
+	}
+}
+
+func TestValidateErrors(t *testing.T) {
+	zero := 0
+	tests := map[string]struct {
+		statuses []models.WorkflowStatus
+		want     string
+	}{
+		"empty": {nil, "at least one status"},
+		"bad key": {[]models.WorkflowStatus{
+			{Key: "to do", Category: models.CategoryTodo},
+		}, "invalid status key"},
+		"duplicate": {[]models.WorkflowStatus{
+			{Key: "todo", Category: models.CategoryTodo},
+			{Key: "TODO", Category: models.CategoryDone},
+		}, "duplicate status key"},
+		"bad category": {[]models.WorkflowStatus{
+			{Key: "todo", Category: "later"},
+		}, "category must be"},
+		"no done": {[]models.WorkflowStatus{
+			{Key: "todo", Category: models.CategoryTodo},
+			{Key: "doing", Category: models.CategoryInProgress},
+		}, "one todo and one done"},
+		"wip limit": {[]models.WorkflowStatus{
+			{Key: "todo", Category: models.CategoryTodo, WIPLimit: &zero},
+			{Key: "done", Category: models.CategoryDone},
+		}, "wip_limit must be positive"},
+		"unknown target": {[]models.WorkflowStatus{
+			{Key: "todo", Category: models.CategoryTodo, Transitions: []string{"review"}},
+			{Key: "done", Category: models.CategoryDone},
+		}, "unknown transition target"},
+	}
+	for name, tc := range tests {
+		wf := models.Workflow{Statuses: tc.statuses}
+		err := Validate(&wf)
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%s: got %v, want error containing %q", name, err, tc.want)
+		}
+	}
+}
+
+func TestCanTransition(t *testing.T) {
+	wf := restricted()
+	tests := []struct {
+		from, to string
+		want     bool
+	}{
+		{"todo", "todo", true},
+		{"todo", "doing", true},
+		{"todo", "done", false},
+		{"doing", "todo", false},
+		{"done", "todo", true},
+		{"todo", "missing", false},
+		// Tasks stranded in a status the workflow no longer has can go
+		// anywhere that exists.
+		{"legacy", "done", true},
+	}
+	for _, tc := range tests {
+		if got := CanTransition(wf, tc.from, tc.to); got != tc.want {
+			t.Errorf("%s -> %s: got %v, want %v", tc.from, tc.to, got, tc.want)
+		}
+	}
+
+	// Without transition lists every move is allowed.
+	if !CanTransition(Default(), "done", "todo") {
+		t.Error("default workflow: done -> todo refused")
+	}
+}
+
+func TestStatusForDone(t *testing.T) {
+	wf := models.Workflow{Statuses: []models.WorkflowStatus{
+		{Key: "backlog", Category: models.CategoryInProgress},
+		{Key: "inbox", Category: models.CategoryTodo},
+		{Key: "shipped", Category: models.CategoryDone},
+		{Key: "wontfix", Category: models.CategoryDone},
+	}}
+	if got := StatusForDone(wf, false); got != "inbox" {
+		t.Errorf("not done maps to %q, want inbox", got)
+	}
+	if got := StatusForDone(wf, true); got != "shipped" {
+		t.Errorf("done maps to %q, want shipped", got)
+	}
+	if !IsDone(wf, "wontfix") || IsDone(wf, "backlog") || IsDone(wf, "missing") {
+		t.Error("IsDone disagrees with the categories")
+	}
+}
@@ -0,0 +1,60 @@
+// Package workspace defines membership roles and what each may do.
+package workspace
+
+// Roles, from least to most privileged.
+const (
+	RoleViewer = "viewer"
+	RoleMember = "member"
+	RoleAdmin  = "admin"
+	RoleOwner  = "owner"
+)
+
+var rank = map[string]int{
+	RoleViewer: 1,
+	RoleMember: 2,
+	RoleAdmin:  3,
+	RoleOwner:  4,
+}
+
+// ValidRole reports whether role is one of the known roles.
+func ValidRole(role string) bool {
+	return rank[role] > 0
+}
+
+// AtLeast reports whether role grants everything min does. An empty role
+// (no membership) never does.
+func AtLeast(role, min string) bool {
+	return rank[role] > 0 && rank[role] >= rank[min]
+}
+
+// CanRead, CanWrite and CanManage are the checks handlers use: viewers
+// read tasks, members also change them, admins also manage the workspace
+// and its members.
+func CanRead(role string) bool   { return AtLeast(role, RoleViewer) }
+func CanWrite(role string) bool  { return AtLeast(role, RoleMember) }
+func CanManage(role string) bool { return AtLeast(role, RoleAdmin) }
+
+// CanAssign reports whether a member with role may give someone newRole or
+// change the role of someone who has current. Only owners hand out or take
+// away owner and admin.
+func CanAssign(role, current, newRole string) bool {
+	if !CanManage(role) {
+		return false
+	}
+	if role == RoleOwner {
+		return true
+	}
+	return !AtLeast(current, RoleAdmin) && !AtLeast(newRole, RoleAdmin)
+}
+
+// Share grant permissions. A view grant acts like the viewer role on the
+// shared items, an edit grant like the member role.
+const (
+	PermissionView = "view"
+	PermissionEdit = "edit"
+)
+
+// ValidPermission reports whether p is a known share permission.
+func ValidPermission(p string) bool {
+	return p == PermissionView || p == PermissionEdit
+}
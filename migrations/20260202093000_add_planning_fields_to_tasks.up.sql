@@ -0,0 +1,6 @@
+ALTER TABLE tasks
+ADD COLUMN priority TEXT NOT NULL DEFAULT '',
+ADD COLUMN due_at TIMESTAMPTZ,
+ADD COLUMN tags TEXT[] NOT NULL DEFAULT '{}';
+
+CREATE INDEX idx_tasks_user_due_at ON tasks(user_id, due_at);
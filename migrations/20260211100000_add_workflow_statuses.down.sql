@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_tasks_user_status;
+
+ALTER TABLE tasks
+DROP COLUMN IF EXISTS status;
+
+DROP TABLE IF EXISTS user_workflows;
@@ -0,0 +1,13 @@
+CREATE TABLE user_workflows (
+    user_id BIGINT PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    statuses JSONB NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+ALTER TABLE tasks
+ADD COLUMN status TEXT NOT NULL DEFAULT 'todo';
+
+UPDATE tasks SET status = 'done' WHERE done;
+
+CREATE INDEX idx_tasks_user_status ON tasks(user_id, status);
@@ -0,0 +1,7 @@
+-- Position keys are compared byte-wise, so the column must use the C
+-- collation. Existing rows stay NULL until the user's first move, which
+-- assigns keys to the whole list.
+ALTER TABLE tasks
+ADD COLUMN position TEXT COLLATE "C";
+
+CREATE INDEX idx_tasks_user_position ON tasks(user_id, position);
@@ -0,0 +1,4 @@
+ALTER TABLE tasks
+DROP COLUMN IF EXISTS time_spent_seconds;
+
+DROP TABLE IF EXISTS time_entries;
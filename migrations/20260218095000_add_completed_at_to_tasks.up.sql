@@ -0,0 +1,6 @@
+ALTER TABLE tasks
+ADD COLUMN completed_at TIMESTAMPTZ;
+
+-- Tasks finished before this migration get their last update as the best
+-- available completion time.
+UPDATE tasks SET completed_at = updated_at WHERE done;
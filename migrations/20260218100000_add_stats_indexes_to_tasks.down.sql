@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_tasks_user_open;
+DROP INDEX IF EXISTS idx_tasks_user_completed_at;
+DROP INDEX IF EXISTS idx_tasks_user_created_at;
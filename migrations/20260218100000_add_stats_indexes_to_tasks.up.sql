@@ -0,0 +1,3 @@
+CREATE INDEX idx_tasks_user_created_at ON tasks(user_id, created_at);
+CREATE INDEX idx_tasks_user_completed_at ON tasks(user_id, completed_at) WHERE completed_at IS NOT NULL;
+CREATE INDEX idx_tasks_user_open ON tasks(user_id, created_at) WHERE done = FALSE;
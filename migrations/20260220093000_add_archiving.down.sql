@@ -0,0 +1,7 @@
+DROP TABLE IF EXISTS user_settings;
+
+DROP INDEX IF EXISTS idx_tasks_user_archived_at;
+DROP INDEX IF EXISTS idx_tasks_user_active;
+
+ALTER TABLE tasks
+DROP COLUMN IF EXISTS archived_at;
@@ -0,0 +1,12 @@
+ALTER TABLE tasks
+ADD COLUMN archived_at TIMESTAMPTZ;
+
+CREATE INDEX idx_tasks_user_active ON tasks(user_id, created_at DESC) WHERE archived_at IS NULL;
+CREATE INDEX idx_tasks_user_archived_at ON tasks(user_id, archived_at) WHERE archived_at IS NOT NULL;
+
+CREATE TABLE user_settings (
+    user_id BIGINT PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    archive_done_after_days INT CHECK (archive_done_after_days > 0),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_tasks_pending_reminders;
+
+ALTER TABLE tasks
+DROP COLUMN IF EXISTS reminded_at;
+
+DROP TABLE IF EXISTS notifications;
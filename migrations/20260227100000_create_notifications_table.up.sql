@@ -0,0 +1,25 @@
+CREATE TABLE notifications (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    type TEXT NOT NULL,
+    task_id BIGINT REFERENCES tasks(id) ON DELETE CASCADE,
+    actor_id BIGINT REFERENCES users(id) ON DELETE SET NULL,
+    title TEXT NOT NULL,
+    body TEXT NOT NULL DEFAULT '',
+    data JSONB NOT NULL DEFAULT '{}',
+    read_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_notifications_user_created ON notifications(user_id, created_at DESC);
+CREATE INDEX idx_notifications_user_unread ON notifications(user_id, type) WHERE read_at IS NULL;
+CREATE INDEX idx_notifications_created_at ON notifications(created_at);
+
+-- Reminders fire once per due date. Tasks already past due when this runs
+-- are treated as reminded so the first run does not flood users.
+ALTER TABLE tasks
+ADD COLUMN reminded_at TIMESTAMPTZ;
+
+UPDATE tasks SET reminded_at = NOW() WHERE due_at < NOW();
+
+CREATE INDEX idx_tasks_pending_reminders ON tasks(due_at) WHERE reminded_at IS NULL AND done = FALSE;
@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS idx_tasks_workspace_active;
+
+ALTER TABLE tasks
+DROP COLUMN IF EXISTS workspace_id;
+
+DROP TABLE IF EXISTS workspace_members;
+DROP TABLE IF EXISTS workspaces;
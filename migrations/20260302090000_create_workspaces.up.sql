@@ -0,0 +1,43 @@
+CREATE TABLE workspaces (
+    id BIGSERIAL PRIMARY KEY,
+    name TEXT NOT NULL,
+    -- Every user has exactly one personal workspace; it cannot be shared
+    -- or deleted.
+    personal BOOLEAN NOT NULL DEFAULT FALSE,
+    created_by BIGINT REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX idx_workspaces_personal ON workspaces(created_by) WHERE personal;
+
+CREATE TABLE workspace_members (
+    workspace_id BIGINT NOT NULL REFERENCES workspaces(id) ON DELETE CASCADE,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    role TEXT NOT NULL CHECK (role IN ('owner', 'admin', 'member', 'viewer')),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (workspace_id, user_id)
+);
+
+CREATE INDEX idx_workspace_members_user_id ON workspace_members(user_id);
+
+INSERT INTO workspaces (name, personal, created_by)
+SELECT 'Personal', TRUE, id FROM users;
+
+INSERT INTO workspace_members (workspace_id, user_id, role)
+SELECT id, created_by, 'owner' FROM workspaces WHERE personal;
+
+-- tasks.user_id stays as the task's creator; access is decided by the
+-- workspace.
+ALTER TABLE tasks
+ADD COLUMN workspace_id BIGINT REFERENCES workspaces(id) ON DELETE CASCADE;
+
+UPDATE tasks t
+SET workspace_id = w.id
+FROM workspaces w
+WHERE w.personal AND w.created_by = t.user_id;
+
+ALTER TABLE tasks
+ALTER COLUMN workspace_id SET NOT NULL;
+
+CREATE INDEX idx_tasks_workspace_active ON tasks(workspace_id, created_at DESC) WHERE archived_at IS NULL;
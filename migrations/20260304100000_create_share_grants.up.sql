@@ -0,0 +1,18 @@
+-- A share grant gives one user access to another user's personal task list
+-- (workspace_id set) or to a single task and its subtasks (task_id set).
+CREATE TABLE share_grants (
+    id BIGSERIAL PRIMARY KEY,
+    owner_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    grantee_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    workspace_id BIGINT REFERENCES workspaces(id) ON DELETE CASCADE,
+    task_id BIGINT REFERENCES tasks(id) ON DELETE CASCADE,
+    permission TEXT NOT NULL CHECK (permission IN ('view', 'edit')),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CHECK ((workspace_id IS NULL) <> (task_id IS NULL)),
+    CHECK (owner_id <> grantee_id)
+);
+
+CREATE UNIQUE INDEX idx_share_grants_list ON share_grants(workspace_id, grantee_id) WHERE workspace_id IS NOT NULL;
+CREATE UNIQUE INDEX idx_share_grants_task ON share_grants(task_id, grantee_id) WHERE task_id IS NOT NULL;
+CREATE INDEX idx_share_grants_grantee_id ON share_grants(grantee_id);
+CREATE INDEX idx_share_grants_owner_id ON share_grants(owner_id);
@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS task_history;
+
+DROP INDEX IF EXISTS idx_tasks_assignee_active;
+
+ALTER TABLE tasks
+DROP COLUMN IF EXISTS assignee_id;
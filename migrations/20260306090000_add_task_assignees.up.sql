@@ -0,0 +1,16 @@
+ALTER TABLE tasks
+ADD COLUMN assignee_id BIGINT REFERENCES users(id) ON DELETE SET NULL;
+
+CREATE INDEX idx_tasks_assignee_active ON tasks(assignee_id, created_at DESC) WHERE archived_at IS NULL;
+
+-- task_history records changes to a task, newest last.
+CREATE TABLE task_history (
+    id BIGSERIAL PRIMARY KEY,
+    task_id BIGINT NOT NULL REFERENCES tasks(id) ON DELETE CASCADE,
+    actor_id BIGINT REFERENCES users(id) ON DELETE SET NULL,
+    type TEXT NOT NULL,
+    data JSONB NOT NULL DEFAULT '{}',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_task_history_task_created ON task_history(task_id, created_at DESC);
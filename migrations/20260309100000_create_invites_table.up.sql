@@ -0,0 +1,22 @@
+-- An invite lets whoever holds its token join a workspace (type
+-- 'workspace', role is the workspace role) or get a share of the inviter's
+-- list (type 'list', role is the share permission). Only a hash of the
+-- token is stored.
+CREATE TABLE invites (
+    id BIGSERIAL PRIMARY KEY,
+    inviter_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    email TEXT NOT NULL,
+    type TEXT NOT NULL CHECK (type IN ('workspace', 'list')),
+    workspace_id BIGINT NOT NULL REFERENCES workspaces(id) ON DELETE CASCADE,
+    role TEXT NOT NULL,
+    token_hash TEXT NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    accepted_at TIMESTAMPTZ,
+    accepted_by BIGINT REFERENCES users(id) ON DELETE SET NULL,
+    revoked_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX idx_invites_token_hash ON invites(token_hash);
+CREATE INDEX idx_invites_inviter_id ON invites(inviter_id, created_at DESC);
+CREATE INDEX idx_invites_workspace_id ON invites(workspace_id, created_at DESC);
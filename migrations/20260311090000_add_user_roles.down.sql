@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS admin_audit;
+
+ALTER TABLE users
+DROP COLUMN IF EXISTS password_reset_required_at,
+DROP COLUMN IF EXISTS disabled_at,
+DROP COLUMN IF EXISTS role;
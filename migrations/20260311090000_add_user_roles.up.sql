@@ -0,0 +1,20 @@
+ALTER TABLE users
+ADD COLUMN role TEXT NOT NULL DEFAULT 'user' CHECK (role IN ('user', 'admin')),
+ADD COLUMN disabled_at TIMESTAMPTZ,
+-- Set by an admin; tokens issued before it stop working and password
+-- login is refused until the password is reset.
+ADD COLUMN password_reset_required_at TIMESTAMPTZ;
+
+-- admin_audit records every administrative action.
+CREATE TABLE admin_audit (
+    id BIGSERIAL PRIMARY KEY,
+    admin_id BIGINT REFERENCES users(id) ON DELETE SET NULL,
+    target_user_id BIGINT REFERENCES users(id) ON DELETE SET NULL,
+    action TEXT NOT NULL,
+    data JSONB NOT NULL DEFAULT '{}',
+    ip TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_admin_audit_created_at ON admin_audit(created_at DESC);
+CREATE INDEX idx_admin_audit_target ON admin_audit(target_user_id, created_at DESC);
@@ -0,0 +1,4 @@
+-- Access tokens carry the epoch they were issued in; logging out
+-- everywhere bumps it so every older token stops working.
+ALTER TABLE users
+ADD COLUMN token_epoch BIGINT NOT NULL DEFAULT 0;
@@ -0,0 +1,10 @@
+ALTER INDEX idx_refresh_tokens_session_id RENAME TO idx_refresh_tokens_family_id;
+ALTER TABLE refresh_tokens RENAME COLUMN session_id TO family_id;
+
+ALTER TABLE sessions
+DROP COLUMN IF EXISTS last_seen_at,
+DROP COLUMN IF EXISTS ip,
+DROP COLUMN IF EXISTS user_agent;
+
+ALTER INDEX idx_sessions_user_id RENAME TO idx_token_families_user_id;
+ALTER TABLE sessions RENAME TO token_families;
@@ -0,0 +1,13 @@
+-- Every login is a session: the token family from 20260313090000 plus
+-- the device it was started from. Access tokens carry the session id.
+ALTER TABLE token_families RENAME TO sessions;
+ALTER INDEX idx_token_families_user_id RENAME TO idx_sessions_user_id;
+
+ALTER TABLE sessions
+ADD COLUMN user_agent TEXT NOT NULL DEFAULT '',
+ADD COLUMN ip TEXT NOT NULL DEFAULT '',
+-- Updated at most every few minutes by the auth middleware.
+ADD COLUMN last_seen_at TIMESTAMPTZ NOT NULL DEFAULT NOW();
+
+ALTER TABLE refresh_tokens RENAME COLUMN family_id TO session_id;
+ALTER INDEX idx_refresh_tokens_family_id RENAME TO idx_refresh_tokens_session_id;
@@ -0,0 +1,7 @@
+ALTER TABLE users
+ADD COLUMN email_verified_at TIMESTAMPTZ,
+-- When the last verification email went out; resends are rate limited.
+ADD COLUMN verification_sent_at TIMESTAMPTZ;
+
+-- Accounts that predate verification keep working as before.
+UPDATE users SET email_verified_at = created_at;
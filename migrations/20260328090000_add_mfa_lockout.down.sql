@@ -0,0 +1,3 @@
+ALTER TABLE users
+DROP COLUMN IF EXISTS mfa_locked_until,
+DROP COLUMN IF EXISTS mfa_failed_attempts;
@@ -0,0 +1,6 @@
+ALTER TABLE users
+-- Wrong 2FA codes in a row, across login challenges and the 2FA settings
+-- routes; reset by an accepted code.
+ADD COLUMN mfa_failed_attempts INT NOT NULL DEFAULT 0,
+-- No code is checked before this time.
+ADD COLUMN mfa_locked_until TIMESTAMPTZ;